@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	authfile "github.com/rgynn/subscription-api/pkg/auth/repo/file"
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+const apikeyUsage = `usage: subscription-api apikey <command> [flags]
+
+commands:
+  create -name NAME -role reader|agent|admin   issue a key, printed once
+  list                                         list keys
+  delete -id ID                                revoke a key
+
+Keys are stored hashed in API_KEYS_FILE, or the file given with -file.
+A running server picks up changes without restarting.
+`
+
+// apikey manages the api keys in the keys file of the server
+func apikey(args []string, out io.Writer) error {
+
+	if len(args) == 0 {
+		return errors.New(apikeyUsage)
+	}
+
+	// the file is usually configured in the same .env as the server
+	godotenv.Load()
+
+	command := args[0]
+	flags := flag.NewFlagSet("apikey "+command, flag.ContinueOnError)
+	file := flags.String("file", os.Getenv("API_KEYS_FILE"), "api keys file")
+	name := flags.String("name", "", "name of the key, for telling keys apart, changes made with it are recorded as apikey:<id>")
+	role := flags.String("role", "", "role of the key: reader, agent or admin")
+	id := flags.String("id", "", "id of the key")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return errors.New("no api keys file, set API_KEYS_FILE or pass -file")
+	}
+
+	repo, err := authfile.NewRepository(*file)
+	if err != nil {
+		return err
+	}
+
+	keys := auth.NewKeys(repo, clock.New())
+	ctx := context.Background()
+
+	switch command {
+	case "create":
+		r := auth.Role(*role)
+		k, err := keys.Create(ctx, &auth.Key{Name: name, Role: &r})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "id:   %s\nkey:  %s\n\nThe key is not stored and can not be shown again.\n", *k.ID, *k.Key)
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tROLE\tCREATED")
+		for _, k := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", *k.ID, *k.Name, *k.Role, k.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	case "delete":
+		if err := keys.Delete(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "revoked %s\n", *id)
+	default:
+		return errors.New(apikeyUsage)
+	}
+
+	return nil
+}
@@ -1,26 +1,80 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
 
 	"github.com/rgynn/subscription-api/pkg/api"
 	"github.com/rgynn/subscription-api/pkg/config"
+	"github.com/rgynn/subscription-api/pkg/logging"
 )
 
+// ExitForcedShutdown is the exit code when in flight requests had to be cut off on shutdown
+const ExitForcedShutdown = 2
+
 func main() {
 
-	cfg, err := config.NewFromEnv()
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := apikey(os.Args[2:], os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "numberplan" {
+		if err := numberplanCommand(os.Args[2:], os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		cfg, err := config.Load(os.Args[2:])
+		if err != nil {
+			fatal(err)
+		}
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	// log json from the start, the level is known once the config is
+	level := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stdout, level))
+
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
+	}
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
+	level.Set(cfg.LogLevel)
+
 	srv, err := api.NewServerFromConfig(cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	log.Printf("Listening on: %s\n", cfg.Port)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatal(err)
+	slog.Info("listening", "addr", cfg.Port, "grpc_addr", cfg.GRPCPort, "admin_addr", cfg.AdminPort)
+	err = srv.Run()
+
+	switch {
+	case err == nil, errors.Is(err, http.ErrServerClosed):
+		slog.Info("shut down")
+	case errors.Is(err, api.ErrForcedShutdown):
+		slog.Error("shut down", "error", err)
+		os.Exit(ExitForcedShutdown)
+	default:
+		fatal(err)
 	}
 }
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator/numberplan"
+)
+
+const numberplanUsage = `usage: subscription-api numberplan <command> [flags]
+
+commands:
+  import -file ranges.csv [-version VERSION]   import a number plan, the latest version is used
+  list                                         list imported versions
+  lookup -msisdn MSISDN                        look up the operator of a number in the latest version
+
+A number plan is a csv file with a header row and the columns prefix and operator.
+Plans are stored in NUMBER_PLAN_DIR, or the directory given with -dir. A running
+server picks up new versions within NUMBER_PLAN_RELOAD_INTERVAL.
+`
+
+// numberplanCommand manages the number plans operators are looked up from offline
+func numberplanCommand(args []string, out io.Writer) error {
+
+	if len(args) == 0 {
+		return errors.New(numberplanUsage)
+	}
+
+	// the directory is usually configured in the same .env as the server
+	godotenv.Load()
+
+	command := args[0]
+	flags := flag.NewFlagSet("numberplan "+command, flag.ContinueOnError)
+	dir := flags.String("dir", os.Getenv("NUMBER_PLAN_DIR"), "number plan directory")
+	file := flags.String("file", "", "csv file to import, - reads stdin")
+	version := flags.String("version", numberplan.NewVersion(time.Now()), "version to import as, defaults to the current time")
+	msisdn := flags.String("msisdn", "", "msisdn to look up")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return errors.New("no number plan directory, set NUMBER_PLAN_DIR or pass -dir")
+	}
+
+	store, err := numberplan.NewStore(*dir)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "import":
+		if *file == "" {
+			return errors.New("no file to import, pass -file")
+		}
+		var r io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		p, err := store.Import(*version, r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "imported %d ranges as version %s\n", p.Len(), p.Version)
+	case "list":
+		versions, err := store.Versions()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tRANGES\tIN USE")
+		for i, v := range versions {
+			p, err := store.Load(v)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%d\t%t\n", v, p.Len(), i == len(versions)-1)
+		}
+		return w.Flush()
+	case "lookup":
+		if *msisdn == "" {
+			return errors.New("no msisdn to look up, pass -msisdn")
+		}
+		repo, err := numberplan.NewRepository(store, 0, clock.New())
+		if err != nil {
+			return err
+		}
+		name, err := repo.Get(context.Background(), msisdn)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", *name)
+	default:
+		return errors.New(numberplanUsage)
+	}
+
+	return nil
+}
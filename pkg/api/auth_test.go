@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/auth/oidc"
+	authmem "github.com/rgynn/subscription-api/pkg/auth/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+
+	repo, err := authmem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := auth.NewKeys(repo, clock.New())
+
+	issue := func(name string, role auth.Role) string {
+		k, err := keys.Create(context.Background(), &auth.Key{Name: &name, Role: &role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *k.Key
+	}
+
+	admin := issue("ops", auth.RoleAdmin)
+	reader := issue("dashboard", auth.RoleReader)
+
+	router, _ := newTestServer(t, func(srv *Server) { srv.authn.Keys = keys })
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(ActorHeader, "spoofed")
+		if key != "" {
+			r.Header.Set(APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	expectProblem := func(w *httptest.ResponseRecorder, status int, code Code) {
+		t.Helper()
+
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != status || p.Code != code {
+			t.Fatalf("expected %d %s, got: %d %s", status, code, w.Code, w.Body.String())
+		}
+	}
+
+	w := do(http.MethodGet, "/api/0.1/subscriptions", "", "")
+	expectProblem(w, http.StatusUnauthorized, CodeUnauthenticated)
+
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatal("expected client to be challenged to authenticate")
+	}
+
+	expectProblem(do(http.MethodGet, "/api/0.1/subscriptions", reader+"x", ""), http.StatusUnauthorized, CodeUnauthenticated)
+
+	if w := do(http.MethodGet, "/api/0.1/docs", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected docs to be public, got: %d", w.Code)
+	}
+
+	if w := do(http.MethodGet, "/api/0.1/subscriptions", reader, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected reader to list subscriptions, got: %d %s", w.Code, w.Body.String())
+	}
+
+	subscription := `{"msisdn": "8-6785500", "activate_at": "2021-05-21T00:00:00Z", "type": "PBX"}`
+
+	expectProblem(do(http.MethodPost, "/api/0.1/subscriptions", reader, subscription), http.StatusForbidden, CodeForbidden)
+
+	w = do(http.MethodPost, "/api/0.1/api_keys", admin, `{"name": "support", "role": "agent"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected admin to issue api key, got: %d %s", w.Code, w.Body.String())
+	}
+
+	var issued auth.Key
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(http.MethodPost, "/api/0.1/subscriptions", *issued.Key, subscription); w.Code != http.StatusOK {
+		t.Fatalf("expected agent to create subscription, got: %d %s", w.Code, w.Body.String())
+	}
+
+	expectProblem(do(http.MethodPost, "/api/0.1/subscriptions/8-6785500/cancel", *issued.Key, ""), http.StatusForbidden, CodeForbidden)
+
+	// the principal is recorded instead of the actor header
+	w = do(http.MethodGet, "/api/0.1/subscriptions/8-6785500/history", reader, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"actor":"apikey:`+*issued.ID+`"`) {
+		t.Fatalf("expected change recorded by apikey:%s, got: %d %s", *issued.ID, w.Code, w.Body.String())
+	}
+
+	w = do(http.MethodPost, "/graphql", reader, `{"query": "mutation { pauseSubscription(msisdn: \"8-6785500\") { status } }"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code":"forbidden"`) {
+		t.Fatalf("expected reader to be forbidden to pause over graphql, got: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/api/0.1/api_keys/"+*issued.ID, admin, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected admin to revoke api key, got: %d %s", w.Code, w.Body.String())
+	}
+
+	expectProblem(do(http.MethodGet, "/api/0.1/subscriptions", *issued.Key, ""), http.StatusUnauthorized, CodeUnauthenticated)
+}
+
+func TestBearerToken(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	jwks := fmt.Sprintf(`{"keys": [{"kty": "EC", "kid": "1", "crv": "P-256", "x": "%s", "y": "%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(point[1:33]), base64.RawURLEncoding.EncodeToString(point[33:]))
+	if err := os.WriteFile(path, []byte(jwks), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := oidc.NewJWKSFromFile(context.Background(), path, oidc.DefaultRefresh, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := oidc.NewVerifier(keys, oidc.Options{Issuer: "https://id.example.com/", Audience: "subscription-api"}, clock.New())
+
+	issue := func(msisdns ...string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":     "https://id.example.com/",
+			"aud":     "subscription-api",
+			"sub":     "customer-1",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+			"role":    "agent",
+			"msisdns": msisdns,
+		})
+		token.Header["kid"] = "1"
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	customer := issue("8-6785500")
+
+	router, _ := newTestServer(t, func(srv *Server) { srv.authn.Tokens = tokens })
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/0.1/subscriptions", "", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("expected client to be challenged for a bearer token, got: %d %v", w.Code, w.Header())
+	}
+
+	if w := do(http.MethodGet, "/api/0.1/subscriptions", customer+"x", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected tampered token to be rejected, got: %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/api/0.1/subscriptions", customer, `{"msisdn": "8-6785500", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected customer to create subscription of its msisdn, got: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodPost, "/api/0.1/subscriptions", customer, `{"msisdn": "8-6785501", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected customer to be forbidden to create subscription of another msisdn, got: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodPost, "/api/0.1/subscriptions", issue("8-6785501"), `{"msisdn": "8-6785501", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected other customer to create subscription of its msisdn, got: %d %s", w.Code, w.Body.String())
+	}
+
+	w = do(http.MethodGet, "/api/0.1/subscriptions", customer, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "8-6785500") || strings.Contains(w.Body.String(), "8-6785501") {
+		t.Fatalf("expected customer to list only subscriptions of its msisdns, got: %d %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/0.1/subscriptions/8-6785501", "/api/0.1/subscriptions/8-6785501/history"} {
+		if w := do(http.MethodGet, path, customer, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("expected %s to be not found for customer, got: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	w = do(http.MethodPost, "/graphql", customer, `{"query": "{ subscriptions { nodes { msisdn } } }"}`)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "8-6785501") {
+		t.Fatalf("expected customer to query only subscriptions of its msisdns, got: %d %s", w.Code, w.Body.String())
+	}
+
+	// tokens are limited to the agent role, admin routes are not scoped
+	if w := do(http.MethodGet, "/api/0.1/webhooks", customer, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected customer to be forbidden to list webhooks, got: %d", w.Code)
+	}
+}
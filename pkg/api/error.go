@@ -2,33 +2,136 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/idempotency"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/validate"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+)
+
+// ProblemContentType of error responses, see RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// ErrBadRequest returned if a request could not be parsed
+var ErrBadRequest = errors.New("request could not be parsed")
+
+// Code identifying the kind of error, stable for clients to act on
+type Code string
+
+const (
+	CodeBadRequest          Code = "bad_request"
+	CodeValidationFailed    Code = "validation_failed"
+	CodeUnauthenticated     Code = "unauthenticated"
+	CodeForbidden           Code = "forbidden"
+	CodeNotFound            Code = "not_found"
+	CodeAlreadyExists       Code = "already_exists"
+	CodeInvalidTransition   Code = "invalid_transition"
+	CodeVersionConflict     Code = "version_conflict"
+	CodeIdempotencyKeyReuse Code = "idempotency_key_reused"
+	CodeOperatorNotFound    Code = "operator_not_found"
+	CodeOperatorUnavailable Code = "operator_unavailable"
+	CodeInternal            Code = "internal"
 )
 
-// ErrorResponse for api
-type ErrorResponse struct {
-	Path    string `json:"path"`
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// problem mapping errors matching err to a status and code
+type problem struct {
+	err    error
+	status int
+	code   Code
+	title  string
 }
 
-func NewErrorResponse(w http.ResponseWriter, r *http.Request, status int, err error) {
+// problems checked in order with errors.Is, the first match decides the response
+var problems = []problem{
+	{ErrBadRequest, http.StatusBadRequest, CodeBadRequest, "Bad request"},
+	{ErrRequestNotValid, http.StatusBadRequest, CodeValidationFailed, "Validation failed"},
+	{subscription.ErrNotValid, http.StatusBadRequest, CodeValidationFailed, "Validation failed"},
+	{webhook.ErrNotValid, http.StatusBadRequest, CodeValidationFailed, "Validation failed"},
+	{auth.ErrNotValid, http.StatusBadRequest, CodeValidationFailed, "Validation failed"},
+	{auth.ErrUnauthenticated, http.StatusUnauthorized, CodeUnauthenticated, "Unauthenticated"},
+	{auth.ErrForbidden, http.StatusForbidden, CodeForbidden, "Forbidden"},
+	{subscription.ErrNotFound, http.StatusNotFound, CodeNotFound, "Not found"},
+	{webhook.ErrNotFound, http.StatusNotFound, CodeNotFound, "Not found"},
+	{auth.ErrNotFound, http.StatusNotFound, CodeNotFound, "Not found"},
+	{subscription.ErrAlreadyExists, http.StatusConflict, CodeAlreadyExists, "Already exists"},
+	{subscription.ErrInvalidTransition, http.StatusConflict, CodeInvalidTransition, "Invalid status transition"},
+	{subscription.ErrVersionConflict, http.StatusPreconditionFailed, CodeVersionConflict, "Version conflict"},
+	{idempotency.ErrFingerprintMismatch, http.StatusUnprocessableEntity, CodeIdempotencyKeyReuse, "Idempotency key reused"},
+	{operator.ErrNotFound, http.StatusUnprocessableEntity, CodeOperatorNotFound, "Operator not found"},
+	{operator.ErrUnavailable, http.StatusServiceUnavailable, CodeOperatorUnavailable, "Operator lookup unavailable"},
+}
 
-	body, err := json.Marshal(ErrorResponse{
-		Path:    r.URL.RawPath,
-		Code:    status,
-		Message: err.Error(),
-	})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-		return
+// Problem details of an error response, see RFC 7807
+type Problem struct {
+	Type       string               `json:"type"`
+	Title      string               `json:"title"`
+	Status     int                  `json:"status"`
+	Detail     string               `json:"detail,omitempty"`
+	Instance   string               `json:"instance,omitempty"`
+	Code       Code                 `json:"code"`
+	RequestID  string               `json:"request_id,omitempty"`
+	Violations []validate.Violation `json:"violations,omitempty"`
+}
+
+// classify err by the first of problems it matches
+func classify(err error) problem {
+
+	for _, candidate := range problems {
+		if errors.Is(err, candidate.err) {
+			return candidate
+		}
+	}
+
+	return problem{nil, http.StatusInternalServerError, CodeInternal, "Internal server error"}
+}
+
+// NewProblem describing err for request r
+func NewProblem(r *http.Request, err error) *Problem {
+
+	p := classify(err)
+
+	result := &Problem{
+		Type:      "urn:subscription-api:problem:" + string(p.code),
+		Title:     p.title,
+		Status:    p.status,
+		Detail:    err.Error(),
+		Instance:  r.URL.Path,
+		Code:      p.code,
+		RequestID: reqctx.RequestID(r.Context()),
+	}
+
+	var invalid *validate.Error
+	if errors.As(err, &invalid) {
+		result.Violations = invalid.Violations
 	}
 
-	w.WriteHeader(status)
-	if _, err := w.Write(body); err != nil {
+	return result
+}
+
+// NewErrorResponse writes err as problem details, the status is decided by what err wraps
+func NewErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+
+	p := NewProblem(r, err)
+
+	// the cause of client errors is in the response, server errors leave a trace in the log
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "code", p.Code, "error", err)
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 		return
 	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
 }
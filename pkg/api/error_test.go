@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+func TestNewErrorResponse(t *testing.T) {
+
+	msisdn := "8-6785500"
+	subType := "SATELLITE"
+	invalid := (&subscription.Model{MSISDN: &msisdn, Type: &subType}).ValidForSave()
+
+	tests := []struct {
+		err        error
+		status     int
+		code       Code
+		violations int
+	}{
+		{fmt.Errorf("wrapped: %w", subscription.ErrNotFound), http.StatusNotFound, CodeNotFound, 0},
+		{fmt.Errorf("wrapped: %w", subscription.ErrInvalidTransition), http.StatusConflict, CodeInvalidTransition, 0},
+		{fmt.Errorf("wrapped: %w", operator.ErrNotFound), http.StatusUnprocessableEntity, CodeOperatorNotFound, 0},
+		{fmt.Errorf("wrapped: %w", operator.ErrUnavailable), http.StatusServiceUnavailable, CodeOperatorUnavailable, 0},
+		{fmt.Errorf("failed to create: %w", invalid), http.StatusBadRequest, CodeValidationFailed, 2},
+		{fmt.Errorf("unexpected"), http.StatusInternalServerError, CodeInternal, 0},
+	}
+
+	for _, test := range tests {
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/0.1/subscriptions", nil)
+
+		NewErrorResponse(w, r, test.err)
+
+		if w.Code != test.status || w.Header().Get("Content-Type") != ProblemContentType {
+			t.Fatalf("expected status %d with %s for %q, got: %d with %s", test.status, ProblemContentType, test.err, w.Code, w.Header().Get("Content-Type"))
+		}
+
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Code != test.code || p.Status != test.status || p.Instance != "/api/0.1/subscriptions" || len(p.Violations) != test.violations {
+			t.Fatalf("unexpected problem for %q: %+v", test.err, p)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/operator/loader"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/validate"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+//go:embed graphiql.html
+var graphiqlPage []byte
+
+// graphqlMaxDepth of fields nested in a query, deep enough for the introspection query of GraphiQL
+const graphqlMaxDepth = 15
+
+// graphqlMaxParallelism of resolvers run at once per request, as many as the operators looked up at once
+const graphqlMaxParallelism = loader.DefaultConcurrency
+
+// NewGraphQLSchema resolving queries and mutations through the subscription repository of srv
+func NewGraphQLSchema(srv *Server) (*graphql.Schema, error) {
+
+	schema, err := graphql.ParseSchema(graphqlSchema, &graphqlResolver{srv: srv},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(graphqlMaxDepth),
+		graphql.MaxParallelism(graphqlMaxParallelism),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse graphql schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+// GraphQLHandler for api, operator lookups made while resolving a request are deduplicated
+func (srv *Server) GraphQLHandler(schema *graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			NewErrorResponse(w, r, err)
+			return
+		}
+		defer r.Body.Close()
+
+		var params struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+
+		if err := json.Unmarshal(body, &params); err != nil {
+			NewErrorResponse(w, r, fmt.Errorf("failed to parse request body: %s: %w", err, ErrBadRequest))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), loaderKey{}, loader.New(srv.operators, loader.DefaultWait, loader.DefaultConcurrency))
+
+		resp, err := json.Marshal(schema.Exec(ctx, params.Query, params.OperationName, params.Variables))
+		if err != nil {
+			NewErrorResponse(w, r, err)
+			return
+		}
+
+		if _, err := w.Write(resp); err != nil {
+			NewErrorResponse(w, r, err)
+			return
+		}
+	}
+}
+
+// GraphiQLHandler for api, only routed in dev mode
+func (srv *Server) GraphiQLHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(graphiqlPage)
+}
+
+type loaderKey struct{}
+
+// operatorsFrom the loader of the request resolved
+func operatorsFrom(ctx context.Context) operator.Repository {
+	return ctx.Value(loaderKey{}).(operator.Repository)
+}
+
+// graphqlError carrying the problem code of err, and its violations, as extensions
+type graphqlError struct {
+	err error
+}
+
+func (e *graphqlError) Error() string {
+	return e.err.Error()
+}
+
+func (e *graphqlError) Unwrap() error {
+	return e.err
+}
+
+func (e *graphqlError) Extensions() map[string]interface{} {
+
+	extensions := map[string]interface{}{"code": classify(e.err).code}
+
+	var invalid *validate.Error
+	if errors.As(e.err, &invalid) {
+		extensions["violations"] = invalid.Violations
+	}
+
+	return extensions
+}
+
+func newGraphQLError(err error) error {
+	return &graphqlError{err: err}
+}
+
+// graphqlResolver of the schema, split by operation so the query subscription does not
+// clash with the resolver graphql-go looks for to serve graphql subscriptions
+type graphqlResolver struct {
+	srv *Server
+}
+
+func (res *graphqlResolver) Query() *graphqlQuery {
+	return &graphqlQuery{res.srv}
+}
+
+func (res *graphqlResolver) Mutation() *graphqlMutation {
+	return &graphqlMutation{res.srv}
+}
+
+type graphqlQuery struct {
+	srv *Server
+}
+
+type graphqlMutation struct {
+	srv *Server
+}
+
+func (res *graphqlQuery) Subscription(ctx context.Context, args struct{ MSISDN string }) (*subscriptionResolver, error) {
+
+	m, err := res.srv.subscriptions.Get(ctx, &args.MSISDN)
+	switch {
+	case errors.Is(err, subscription.ErrNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+type subscriptionsArgs struct {
+	First          int32
+	After          *string
+	Status         *string
+	Type           *string
+	Operator       *string
+	ActivateAfter  *graphql.Time
+	ActivateBefore *graphql.Time
+	// OperatorChangedSince matches subscriptions whose number was ported at or after it
+	OperatorChangedSince *graphql.Time
+	Sort                 string
+}
+
+// graphqlSorts by enum value of Sort
+var graphqlSorts = map[string]subscription.Sort{
+	"MSISDN":           subscription.SortMSISDN,
+	"MSISDN_DESC":      subscription.SortMSISDNDesc,
+	"ACTIVATE_AT":      subscription.SortActivateAt,
+	"ACTIVATE_AT_DESC": subscription.SortActivateAtDesc,
+}
+
+func (res *graphqlQuery) Subscriptions(ctx context.Context, args subscriptionsArgs) (*subscriptionConnection, error) {
+
+	// every node may look up its operator, so the page is bounded before anything is read
+	if args.First < 1 || args.First > subscription.MaxLimit {
+		return nil, newGraphQLError(fmt.Errorf("first needs to be between 1 and %d: %w", subscription.MaxLimit, subscription.ErrNotValid))
+	}
+
+	q := &subscription.Query{
+		Cursor:   args.After,
+		Limit:    int(args.First),
+		Type:     args.Type,
+		Operator: args.Operator,
+		Sort:     graphqlSorts[args.Sort],
+	}
+
+	if args.Status != nil {
+		status := subscription.Status(strings.ToLower(*args.Status))
+		q.Status = &status
+	}
+
+	if args.ActivateAfter != nil {
+		q.ActivateAfter = &args.ActivateAfter.Time
+	}
+
+	if args.ActivateBefore != nil {
+		q.ActivateBefore = &args.ActivateBefore.Time
+	}
+
+	if args.OperatorChangedSince != nil {
+		q.OperatorChangedSince = &args.OperatorChangedSince.Time
+	}
+
+	page, err := res.srv.subscriptions.List(ctx, q)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionConnection{page}, nil
+}
+
+type createSubscriptionArgs struct {
+	Input struct {
+		MSISDN     string
+		ActivateAt graphql.Time
+		Type       string
+	}
+}
+
+func (res *graphqlMutation) CreateSubscription(ctx context.Context, args createSubscriptionArgs) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAgent); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	m, err := res.srv.subscriptions.Create(ctx, &subscription.Model{
+		MSISDN:     &args.Input.MSISDN,
+		ActivateAt: &args.Input.ActivateAt.Time,
+		Type:       &args.Input.Type,
+	})
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+type updateActivationDateArgs struct {
+	MSISDN     string
+	ActivateAt graphql.Time
+	Version    *Int64
+}
+
+func (res *graphqlMutation) UpdateActivationDate(ctx context.Context, args updateActivationDateArgs) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAgent); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	current, err := res.srv.subscriptions.Get(ctx, &args.MSISDN)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	// keep the type read, the version makes sure it was not changed in between
+	version := current.Version
+	if args.Version != nil {
+		v := int64(*args.Version)
+		version = &v
+	}
+
+	m, err := res.srv.subscriptions.Update(ctx, &subscription.Model{
+		MSISDN:     &args.MSISDN,
+		ActivateAt: &args.ActivateAt.Time,
+		Type:       current.Type,
+		Version:    version,
+	})
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+func (res *graphqlMutation) PauseSubscription(ctx context.Context, args struct{ MSISDN string }) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAgent); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	m, err := res.srv.pauser.Pause(ctx, &args.MSISDN, nil)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+func (res *graphqlMutation) ResumeSubscription(ctx context.Context, args struct{ MSISDN string }) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAgent); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	m, err := res.srv.pauser.Resume(ctx, &args.MSISDN, nil)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+func (res *graphqlMutation) CancelSubscription(ctx context.Context, args struct{ MSISDN string }) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAdmin); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	m, err := res.srv.subscriptions.Cancel(ctx, &args.MSISDN, nil)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+func (res *graphqlMutation) RefreshOperator(ctx context.Context, args struct{ MSISDN string }) (*subscriptionResolver, error) {
+
+	if err := auth.Require(ctx, auth.RoleAgent); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	m, err := res.srv.refresher.RefreshOperator(ctx, &args.MSISDN)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return &subscriptionResolver{m}, nil
+}
+
+type subscriptionConnection struct {
+	page *subscription.Page
+}
+
+func (c *subscriptionConnection) Nodes() []*subscriptionResolver {
+
+	nodes := make([]*subscriptionResolver, 0, len(c.page.Subscriptions))
+	for _, m := range c.page.Subscriptions {
+		nodes = append(nodes, &subscriptionResolver{m})
+	}
+
+	return nodes
+}
+
+func (c *subscriptionConnection) NextCursor() *string {
+	return c.page.NextCursor
+}
+
+type subscriptionResolver struct {
+	m *subscription.Model
+}
+
+func (r *subscriptionResolver) MSISDN() string {
+	return *r.m.MSISDN
+}
+
+func (r *subscriptionResolver) ActivateAt() graphql.Time {
+	return graphql.Time{Time: *r.m.ActivateAt}
+}
+
+func (r *subscriptionResolver) Type() string {
+	return *r.m.Type
+}
+
+func (r *subscriptionResolver) Status() string {
+	return strings.ToUpper(string(*r.m.Status))
+}
+
+// Operator stored with the subscription, looked up once per request for subscriptions whose
+// operator was never checked
+func (r *subscriptionResolver) Operator(ctx context.Context) (*string, error) {
+
+	if r.m.OperatorCheckedAt != nil {
+		return r.m.Operator, nil
+	}
+
+	name, err := operatorsFrom(ctx).Get(ctx, r.m.MSISDN)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return name, nil
+}
+
+func (r *subscriptionResolver) OperatorCheckedAt() *graphql.Time {
+
+	if r.m.OperatorCheckedAt == nil {
+		return nil
+	}
+
+	return &graphql.Time{Time: *r.m.OperatorCheckedAt}
+}
+
+func (r *subscriptionResolver) OperatorChangedAt() *graphql.Time {
+
+	if r.m.OperatorChangedAt == nil {
+		return nil
+	}
+
+	return &graphql.Time{Time: *r.m.OperatorChangedAt}
+}
+
+func (r *subscriptionResolver) Version() Int64 {
+
+	if r.m.Version == nil {
+		return 0
+	}
+
+	return Int64(*r.m.Version)
+}
+
+// Int64 graphql scalar, the versions of subscriptions do not fit the 32 bits of Int
+type Int64 int64
+
+// maxExactFloat is the largest integer every integer up to is exact as a float64, as JSON
+// numbers of variables are decoded
+const maxExactFloat = 1 << 53
+
+func (Int64) ImplementsGraphQLType(name string) bool {
+	return name == "Int64"
+}
+
+func (i *Int64) UnmarshalGraphQL(input interface{}) error {
+
+	switch v := input.(type) {
+	case int32:
+		*i = Int64(v)
+	case int64:
+		*i = Int64(v)
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > maxExactFloat {
+			return fmt.Errorf("Int64 cannot represent %v exactly, pass it as a string", v)
+		}
+		*i = Int64(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Int64 cannot represent %q: %w", v, err)
+		}
+		*i = Int64(n)
+	default:
+		return fmt.Errorf("Int64 cannot represent %T", input)
+	}
+
+	return nil
+}
+
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(i), 10), nil
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQL(t *testing.T) {
+
+	router, operators := newTestServer(t)
+
+	do := func(query string, variables map[string]interface{}) *graphqlResponse {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got: %d %s", w.Code, w.Body.String())
+		}
+		var resp graphqlResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+
+	create := `mutation($msisdn: String!) { createSubscription(input: {msisdn: $msisdn, activateAt: "2021-05-21T00:00:00Z", type: PBX}) { status version } }`
+	for i := 0; i < 5; i++ {
+		resp := do(create, map[string]interface{}{"msisdn": fmt.Sprintf("8-%d", i)})
+		if len(resp.Errors) > 0 || string(resp.Data["createSubscription"]) != `{"status":"ACTIVATED","version":1}` {
+			t.Fatalf("unexpected create response: %+v %s", resp.Errors, resp.Data["createSubscription"])
+		}
+	}
+
+	// operators are served as stored when the subscriptions were created
+	before := operators.calls
+
+	resp := do(`{ subscriptions(sort: MSISDN_DESC) { nodes { msisdn operator } } }`, nil)
+	if len(resp.Errors) > 0 || !strings.Contains(string(resp.Data["subscriptions"]), `{"msisdn":"8-4","operator":"Tele2 Sverige AB"}`) {
+		t.Fatalf("unexpected list response: %+v %s", resp.Errors, resp.Data["subscriptions"])
+	}
+
+	if operators.calls != before {
+		t.Fatalf("expected no operator lookups, got: %d", operators.calls-before)
+	}
+
+	resp = do(`mutation { refreshOperator(msisdn: "8-0") { operator version } }`, nil)
+	if len(resp.Errors) > 0 || string(resp.Data["refreshOperator"]) != `{"operator":"Tele2 Sverige AB","version":1}` || operators.calls != before+1 {
+		t.Fatalf("unexpected refresh response: %+v %s", resp.Errors, resp.Data["refreshOperator"])
+	}
+
+	resp = do(`mutation { pauseSubscription(msisdn: "8-1") { status } }`, nil)
+	if len(resp.Errors) > 0 || string(resp.Data["pauseSubscription"]) != `{"status":"PAUSED"}` {
+		t.Fatalf("unexpected pause response: %+v %s", resp.Errors, resp.Data["pauseSubscription"])
+	}
+
+	// a retried pause does not resume the subscription
+	resp = do(`mutation { pauseSubscription(msisdn: "8-1") { status } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != string(CodeInvalidTransition) {
+		t.Fatalf("expected invalid transition, got: %+v", resp.Errors)
+	}
+
+	resp = do(`mutation { updateActivationDate(msisdn: "8-2", activateAt: "2031-05-21T00:00:00Z", version: 7) { version } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != string(CodeVersionConflict) {
+		t.Fatalf("expected version conflict, got: %+v", resp.Errors)
+	}
+
+	// versions beyond 32 bits are not truncated to the current version of 1
+	update := `mutation($version: Int64) { updateActivationDate(msisdn: "8-2", activateAt: "2031-05-21T00:00:00Z", version: $version) { version } }`
+	for _, version := range []interface{}{"4294967297", float64(1 << 32)} {
+		resp = do(update, map[string]interface{}{"version": version})
+		if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != string(CodeVersionConflict) {
+			t.Fatalf("expected version conflict for %v, got: %+v", version, resp.Errors)
+		}
+	}
+
+	resp = do(update, map[string]interface{}{"version": 1.5})
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] == string(CodeVersionConflict) {
+		t.Fatalf("expected a version that is not an integer to be invalid, got: %+v", resp.Errors)
+	}
+
+	resp = do(`{ subscriptions(first: 1001) { nodes { msisdn } } }`, nil)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != string(CodeValidationFailed) {
+		t.Fatalf("expected first beyond the max limit to be invalid, got: %+v", resp.Errors)
+	}
+
+	deep := "{ __schema { types { fields { type { " + strings.Repeat("ofType { ", 11) + "name" + strings.Repeat(" }", 16)
+	resp = do(deep, nil)
+	if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, "exceeds max depth") {
+		t.Fatalf("expected query exceeding the max depth to be rejected, got: %+v", resp.Errors)
+	}
+
+	resp = do(`{ subscription(msisdn: "8-404") { msisdn } }`, nil)
+	if len(resp.Errors) > 0 || string(resp.Data["subscription"]) != "null" {
+		t.Fatalf("expected unknown subscription to be null, got: %+v %s", resp.Errors, resp.Data["subscription"])
+	}
+}
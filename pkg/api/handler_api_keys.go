@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rgynn/subscription-api/pkg/auth"
+)
+
+// ErrAPIKeysDisabled returned when managing api keys while no api key file is configured
+var ErrAPIKeysDisabled = fmt.Errorf("api keys are disabled, they can not be managed: %w", ErrBadRequest)
+
+// APIKeysListHandler for api
+func (srv *Server) APIKeysListHandler(w http.ResponseWriter, r *http.Request) {
+
+	if srv.authn.Keys == nil {
+		NewErrorResponse(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	result, err := srv.authn.Keys.List(r.Context())
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	for i, k := range result {
+		result[i] = k.Redacted()
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// APIKeysCreateHandler for api, the response is the only time the key is returned
+func (srv *Server) APIKeysCreateHandler(w http.ResponseWriter, r *http.Request) {
+
+	if srv.authn.Keys == nil {
+		NewErrorResponse(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+
+	var k *auth.Key
+	if err := json.Unmarshal(body, &k); err != nil {
+		NewErrorResponse(w, r, fmt.Errorf("failed to parse request body: %s: %w", err, ErrBadRequest))
+		return
+	}
+
+	result, err := srv.authn.Keys.Create(r.Context(), k)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write(resp); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// APIKeysDeleteHandler for api, requests authenticated by the key are rejected from then on
+func (srv *Server) APIKeysDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	if srv.authn.Keys == nil {
+		NewErrorResponse(w, r, ErrAPIKeysDisabled)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	if err := srv.authn.Keys.Delete(r.Context(), &id); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
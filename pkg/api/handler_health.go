@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthzHandler for api, reporting the process is alive without checking its dependencies
+func (srv *Server) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// ReadyzHandler for api, responding 503 with the failing checks if the server should not get traffic
+func (srv *Server) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+
+	report := srv.health.Check(r.Context())
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !report.OK() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	w.Write(body)
+}
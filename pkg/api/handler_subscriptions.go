@@ -2,30 +2,132 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rgynn/subscription-api/pkg/subscription"
 )
 
+// NewQueryFromRequest parses filters, sorting and pagination of a list request
+func NewQueryFromRequest(r *http.Request) (*subscription.Query, error) {
+
+	values := r.URL.Query()
+	q := &subscription.Query{}
+
+	optional := func(name string) *string {
+		if v := values.Get(name); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	timestamp := func(name string) (*time.Time, error) {
+		v := optional(name)
+		if v == nil {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, *v)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs to be a RFC3339 timestamp: %w", name, subscription.ErrNotValid)
+		}
+		return &t, nil
+	}
+
+	if v := optional("limit"); v != nil {
+		n, err := strconv.Atoi(*v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("limit needs to be a positive number: %w", subscription.ErrNotValid)
+		}
+		q.Limit = n
+	}
+
+	if v := optional("status"); v != nil {
+		status := subscription.Status(*v)
+		q.Status = &status
+	}
+
+	q.Cursor = optional("cursor")
+	q.Type = optional("type")
+	q.Operator = optional("operator")
+	q.Sort = subscription.Sort(values.Get("sort"))
+
+	var err error
+
+	if q.ActivateAfter, err = timestamp("activate_after"); err != nil {
+		return nil, err
+	}
+
+	if q.ActivateBefore, err = timestamp("activate_before"); err != nil {
+		return nil, err
+	}
+
+	if q.OperatorChangedSince, err = timestamp("operator_changed_since"); err != nil {
+		return nil, err
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// ETag of the version of a subscription
+func ETag(m *subscription.Model) string {
+
+	if m.Version == nil {
+		return ""
+	}
+
+	return `"` + strconv.FormatInt(*m.Version, 10) + `"`
+}
+
+// IfMatch parses the version expected by the If-Match header, nil if not provided or *
+func IfMatch(r *http.Request) (*int64, error) {
+
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return nil, nil
+	}
+
+	unquoted := strings.TrimSuffix(strings.TrimPrefix(v, `"`), `"`)
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || len(unquoted) != len(v)-2 {
+		return nil, fmt.Errorf("If-Match needs to be a single strong ETag from a previous response: %w", ErrBadRequest)
+	}
+
+	return &version, nil
+}
+
 // SubscriptionsListHandler for api
 func (srv *Server) SubscriptionsListHandler(w http.ResponseWriter, r *http.Request) {
 
-	result, err := srv.subscriptions.List(r.Context())
+	q, err := NewQueryFromRequest(r)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := srv.subscriptions.List(r.Context(), q)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	body, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	if _, err := w.Write(body); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -37,23 +139,19 @@ func (srv *Server) SubscriptionsGetHandler(w http.ResponseWriter, r *http.Reques
 
 	result, err := srv.subscriptions.Get(r.Context(), &msisdn)
 	if err != nil {
-		switch err {
-		case subscription.ErrNotFound:
-			NewErrorResponse(w, r, http.StatusNotFound, err)
-		default:
-			NewErrorResponse(w, r, http.StatusInternalServerError, err)
-		}
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	body, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ETag(result))
 	if _, err := w.Write(body); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -63,38 +161,32 @@ func (srv *Server) SubscriptionsCreateHandler(w http.ResponseWriter, r *http.Req
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 	defer r.Body.Close()
 
 	var m *subscription.Model
 	if err := json.Unmarshal(body, &m); err != nil {
-		NewErrorResponse(w, r, http.StatusBadRequest, err)
+		NewErrorResponse(w, r, fmt.Errorf("failed to parse request body: %s: %w", err, ErrBadRequest))
 		return
 	}
 
 	result, err := srv.subscriptions.Create(r.Context(), m)
 	if err != nil {
-		switch err {
-		case subscription.ErrAlreadyExists:
-			NewErrorResponse(w, r, http.StatusConflict, err)
-		case subscription.ErrNotFound:
-			NewErrorResponse(w, r, http.StatusNotFound, err)
-		default:
-			NewErrorResponse(w, r, http.StatusInternalServerError, err)
-		}
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	resp, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ETag(result))
 	if _, err := w.Write(resp); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -104,36 +196,42 @@ func (srv *Server) SubscriptionsUpdateHandler(w http.ResponseWriter, r *http.Req
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 	defer r.Body.Close()
 
 	var m *subscription.Model
 	if err := json.Unmarshal(body, &m); err != nil {
-		NewErrorResponse(w, r, http.StatusBadRequest, err)
+		NewErrorResponse(w, r, fmt.Errorf("failed to parse request body: %s: %w", err, ErrBadRequest))
+		return
+	}
+
+	expected, err := IfMatch(r)
+	if err != nil {
+		NewErrorResponse(w, r, err)
 		return
 	}
 
+	if m != nil && expected != nil {
+		m.Version = expected
+	}
+
 	result, err := srv.subscriptions.Update(r.Context(), m)
 	if err != nil {
-		switch err {
-		case subscription.ErrNotFound:
-			NewErrorResponse(w, r, http.StatusNotFound, err)
-		default:
-			NewErrorResponse(w, r, http.StatusInternalServerError, err)
-		}
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	resp, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ETag(result))
 	if _, err := w.Write(resp); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -143,25 +241,27 @@ func (srv *Server) SubscriptionsTogglePausedHandler(w http.ResponseWriter, r *ht
 
 	msisdn := mux.Vars(r)["msisdn"]
 
-	result, err := srv.subscriptions.TogglePaused(r.Context(), &msisdn)
+	expected, err := IfMatch(r)
 	if err != nil {
-		switch err {
-		case subscription.ErrNotFound:
-			NewErrorResponse(w, r, http.StatusNotFound, err)
-		default:
-			NewErrorResponse(w, r, http.StatusInternalServerError, err)
-		}
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := srv.subscriptions.TogglePaused(r.Context(), &msisdn, expected)
+	if err != nil {
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	resp, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", ETag(result))
 	if _, err := w.Write(resp); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -171,25 +271,96 @@ func (srv *Server) SubscriptionsCancelHandler(w http.ResponseWriter, r *http.Req
 
 	msisdn := mux.Vars(r)["msisdn"]
 
-	result, err := srv.subscriptions.Cancel(r.Context(), &msisdn)
+	expected, err := IfMatch(r)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := srv.subscriptions.Cancel(r.Context(), &msisdn, expected)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", ETag(result))
+	if _, err := w.Write(resp); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// SubscriptionsRefreshOperatorHandler for api
+func (srv *Server) SubscriptionsRefreshOperatorHandler(w http.ResponseWriter, r *http.Request) {
+
+	msisdn := mux.Vars(r)["msisdn"]
+
+	result, err := srv.refresher.RefreshOperator(r.Context(), &msisdn)
 	if err != nil {
-		switch err {
-		case subscription.ErrNotFound:
-			NewErrorResponse(w, r, http.StatusNotFound, err)
-		default:
-			NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", ETag(result))
+	if _, err := w.Write(resp); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// DefaultHistoryLimit of entries returned per page of history
+const DefaultHistoryLimit = 50
+
+// MaxHistoryLimit of entries a client may request per page of history
+const MaxHistoryLimit = 500
+
+// SubscriptionsHistoryHandler for api
+func (srv *Server) SubscriptionsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	msisdn := mux.Vars(r)["msisdn"]
+	query := r.URL.Query()
+
+	limit := DefaultHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxHistoryLimit {
+			NewErrorResponse(w, r, fmt.Errorf("limit needs to be between 1 and %d: %w", MaxHistoryLimit, ErrBadRequest))
+			return
 		}
+		limit = n
+	}
+
+	var cursor *string
+	if v := query.Get("cursor"); v != "" {
+		cursor = &v
+	}
+
+	result, err := srv.history.History(r.Context(), &msisdn, cursor, limit)
+	if err != nil {
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	resp, err := json.Marshal(result)
 	if err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 
 	if _, err := w.Write(resp); err != nil {
-		NewErrorResponse(w, r, http.StatusInternalServerError, err)
+		NewErrorResponse(w, r, err)
 		return
 	}
 }
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+)
+
+// WebhooksListHandler for api
+func (srv *Server) WebhooksListHandler(w http.ResponseWriter, r *http.Request) {
+
+	result, err := srv.webhooks.List(r.Context())
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	for i, m := range result {
+		result[i] = m.Redacted()
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// WebhooksCreateHandler for api, the response is the only time the secret is returned
+func (srv *Server) WebhooksCreateHandler(w http.ResponseWriter, r *http.Request) {
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+
+	var m *webhook.Model
+	if err := json.Unmarshal(body, &m); err != nil {
+		NewErrorResponse(w, r, fmt.Errorf("failed to parse request body: %s: %w", err, ErrBadRequest))
+		return
+	}
+
+	result, err := srv.webhooks.Create(r.Context(), m)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write(resp); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
+
+// WebhooksDeleteHandler for api
+func (srv *Server) WebhooksDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	id := mux.Vars(r)["id"]
+
+	if err := srv.webhooks.Delete(r.Context(), &id); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhooksDeliveriesHandler for api
+func (srv *Server) WebhooksDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	limit := DefaultHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxHistoryLimit {
+			NewErrorResponse(w, r, fmt.Errorf("limit needs to be between 1 and %d: %w", MaxHistoryLimit, ErrBadRequest))
+			return
+		}
+		limit = n
+	}
+
+	var cursor *string
+	if v := query.Get("cursor"); v != "" {
+		cursor = &v
+	}
+
+	result, err := srv.webhooks.Deliveries(r.Context(), &id, cursor, limit)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+
+	if _, err := w.Write(resp); err != nil {
+		NewErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/idempotency"
+	"github.com/rgynn/subscription-api/pkg/metrics"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+)
+
+// RequestIDHeader carrying the id of a request, generated if not provided by the client or not valid
+const RequestIDHeader = reqctx.RequestIDHeader
+
+// ActorHeader naming who performs a change, recorded in the subscription history
+const ActorHeader = "X-Actor"
+
+// RequestContextMiddleware puts request id and actor in the request context
+func (srv *Server) RequestContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// echoed, logged and passed on to pts, so only a bounded token is taken from the client
+		id := r.Header.Get(RequestIDHeader)
+		if !reqctx.ValidRequestID(id) {
+			id = reqctx.NewRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := reqctx.WithRequestID(r.Context(), id)
+
+		if actor := r.Header.Get(ActorHeader); actor != "" {
+			ctx = reqctx.WithActor(ctx, actor)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AccessLogMiddleware logs every request once served, with its route rather than its path so
+// msisdns in paths do not end up in the log
+func (srv *Server) AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		rec := &accessRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.Log(r.Context(), level, "request served",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"status", rec.status,
+			"duration", time.Since(start),
+			"bytes", rec.bytes,
+		)
+	})
+}
+
+// MetricsMiddleware counts requests and observes their duration by route, see package metrics
+func (srv *Server) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		rec := &accessRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := routeTemplate(r)
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate of the route r matched, its path if it matched none
+func routeTemplate(r *http.Request) string {
+
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return r.URL.Path
+}
+
+// accessRecorder of the status and size of a response written through to the client
+type accessRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rec *accessRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *accessRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// APIKeyHeader carrying the api key authenticating a request
+const APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware authenticates the api key or bearer token of a request, making its principal the
+// actor of changes, and rejects requests to routes whose role the principal does not have. With
+// authentication disabled every request is made by an admin named by the actor header.
+func (srv *Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		creds := auth.NewCredentials(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+
+		ctx, err := srv.authn.Authenticate(r.Context(), creds)
+		if err != nil {
+			srv.unauthorized(w, r, err)
+			return
+		}
+
+		r = r.WithContext(ctx)
+
+		if role, ok := srv.roles[mux.CurrentRoute(r)]; ok {
+			if err := auth.Require(ctx, role); err != nil {
+				srv.unauthorized(w, r, err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unauthorized responds with err, challenging the client to authenticate if it did not
+func (srv *Server) unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		if srv.authn.Keys != nil {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`APIKey header="%s"`, APIKeyHeader))
+		}
+		if srv.authn.Tokens != nil {
+			w.Header().Add("WWW-Authenticate", "Bearer")
+		}
+	}
+
+	NewErrorResponse(w, r, err)
+}
+
+// JSONMiddleware defaults the content type of responses to json, handlers writing anything else set their own
+func (srv *Server) JSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IdempotencyKeyHeader making retries of a POST request safe, the first response for a key is replayed
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader set on responses replayed for a reused idempotency key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// MaxIdempotencyKeyLength accepted in the Idempotency-Key header
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyMiddleware replays the stored response of POST requests repeating an Idempotency-Key.
+// Keys are scoped to the actor, server errors are not stored so the request can be retried.
+func (srv *Server) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > MaxIdempotencyKeyLength {
+			NewErrorResponse(w, r, fmt.Errorf("%s needs to be at most %d characters: %w", IdempotencyKeyHeader, MaxIdempotencyKeyLength, ErrBadRequest))
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			NewErrorResponse(w, r, fmt.Errorf("failed to read request body: %s: %w", err, ErrBadRequest))
+			return
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key = reqctx.Actor(r.Context()) + "\x00" + key
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+
+		stored, err := srv.idempotency.Begin(r.Context(), key, fingerprint)
+		if err != nil {
+			NewErrorResponse(w, r, err)
+			return
+		}
+
+		if stored != nil {
+			for name, values := range stored.Header {
+				if name != http.CanonicalHeaderKey(RequestIDHeader) {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// release the key if the handler panics, so waiting duplicates do not block forever
+		var resp *idempotency.Response
+		defer func() {
+			if err := srv.idempotency.Complete(context.WithoutCancel(r.Context()), key, resp); err != nil {
+				slog.ErrorContext(r.Context(), "failed to complete idempotency key", "error", err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status < http.StatusInternalServerError {
+			resp = &idempotency.Response{Status: rec.status, Header: w.Header().Clone(), Body: rec.body.Bytes()}
+		}
+	})
+}
+
+// responseRecorder writing through to the client while keeping a copy of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
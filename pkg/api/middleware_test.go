@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/idempotency/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/logging"
+	"github.com/rgynn/subscription-api/pkg/metrics"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+
+	store, err := mem.NewStore(time.Hour, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{idempotency: store}
+
+	var calls int
+	handler := srv.RequestContextMiddleware(srv.IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"paused"}`))
+	})))
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/0.1/subscriptions/8-6785500/toggle_paused", strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, key)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := do("retry-1", "")
+	replay := do("retry-1", "")
+
+	if calls != 1 {
+		t.Fatalf("expected handler to be called once, got: %d", calls)
+	}
+
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() || replay.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("expected first response replayed, got: %d %s", replay.Code, replay.Body.String())
+	}
+
+	if replay.Header().Get(RequestIDHeader) == first.Header().Get(RequestIDHeader) {
+		t.Fatal("expected replay to have its own request id")
+	}
+
+	if w := do("retry-1", `{"other":"body"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for reused key with different body, got: %d", w.Code)
+	}
+
+	do("retry-2", "")
+
+	if calls != 2 {
+		t.Fatalf("expected new key to be processed, got: %d calls", calls)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo))
+
+	router, _ := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/0.1/subscriptions/8-6785500", nil)
+	r.Header.Set(RequestIDHeader, "access-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var record struct {
+		Msg       string `json:"msg"`
+		Method    string `json:"method"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		Bytes     int    `json:"bytes"`
+		RequestID string `json:"request_id"`
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if record.Msg != "request served" || record.Method != http.MethodGet || record.Route != "/api/0.1/subscriptions/{msisdn}" ||
+		record.Status != http.StatusNotFound || record.Bytes != w.Body.Len() || record.RequestID != "access-1" {
+		t.Fatalf("unexpected access log: %s", buf.String())
+	}
+
+	if strings.Contains(buf.String(), "6785500") {
+		t.Fatalf("expected msisdn to be kept out of the log, got: %s", buf.String())
+	}
+}
+
+func TestRequestContextMiddleware(t *testing.T) {
+
+	router, _ := newTestServer(t)
+
+	for id, kept := range map[string]bool{
+		"req-1.a_B":                    true,
+		strings.Repeat("a", 128):       true,
+		strings.Repeat("a", 129):       false,
+		"req-1\n{\"level\":\"ERROR\"}": false,
+		"req 1":                        false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/0.1/subscriptions/8-6785500", nil)
+		r.Header.Set(RequestIDHeader, id)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		got := w.Header().Get(RequestIDHeader)
+		if kept && got != id || !kept && (got == id || got == "") {
+			t.Fatalf("expected request id %q to be kept: %t, got: %q", id, kept, got)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+
+	router, _ := newTestServer(t)
+
+	counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/api/0.1/subscriptions/{msisdn}", "404")
+	before := testutil.ToFloat64(counter)
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/0.1/subscriptions/8-678550"+strconv.Itoa(i), nil))
+	}
+
+	if n := testutil.ToFloat64(counter) - before; n != 2 {
+		t.Fatalf("expected 2 requests counted by route template, got: %v", n)
+	}
+
+	w := httptest.NewRecorder()
+	(&Server{}).NewAdminRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `subscription_api_http_requests_total{method="GET",route="/api/0.1/subscriptions/{msisdn}",status="404"}`) {
+		t.Fatalf("expected request counter to be exposed, got: %d %s", w.Code, w.Body.String())
+	}
+}
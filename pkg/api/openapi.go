@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/rgynn/subscription-api/pkg/validate"
+)
+
+// OpenAPISpec describing every route of the api, served at /api/0.1/openapi.json
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// ErrRequestNotValid returned if a request does not match the OpenAPI spec
+var ErrRequestNotValid = errors.New("request does not match the api specification")
+
+// ErrResponseNotValid returned if a response does not match the OpenAPI spec
+var ErrResponseNotValid = errors.New("response does not match the api specification")
+
+// LoadOpenAPI parses and validates the bundled OpenAPI spec
+func LoadOpenAPI() (*openapi3.T, error) {
+
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi spec not valid: %w", err)
+	}
+
+	return doc, nil
+}
+
+// OpenAPIHandler for api
+func (srv *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(OpenAPISpec)
+}
+
+// DocsHandler for api, renders the OpenAPI spec
+func (srv *Server) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// OpenAPIValidator checking requests, and optionally responses, against the OpenAPI spec
+type OpenAPIValidator struct {
+	router    routers.Router
+	responses bool
+	options   *openapi3filter.Options
+}
+
+// NewOpenAPIValidator for the bundled spec, validating responses is meant for tests
+// as an invalid response is replaced by an error
+func NewOpenAPIValidator(validateResponses bool) (*OpenAPIValidator, error) {
+
+	doc, err := LoadOpenAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route openapi spec: %w", err)
+	}
+
+	return &OpenAPIValidator{
+		router:    router,
+		responses: validateResponses,
+		options: &openapi3filter.Options{
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		},
+	}, nil
+}
+
+// Middleware rejecting requests not matching the spec with the violations found,
+// routes not in the spec are left for the router to answer
+func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		route, params, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// clients such as curl -d default to form encoding, the api only speaks json
+		if r.ContentLength != 0 && route.Operation.RequestBody != nil {
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "" || ct == "application/x-www-form-urlencoded" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options:    v.options,
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			invalid := validate.New(ErrRequestNotValid)
+			addViolations(invalid, "", err)
+			NewErrorResponse(w, r, invalid)
+			return
+		}
+
+		if !v.responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: w.Header().Clone(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.header,
+			Body:                   ioutil.NopCloser(bytes.NewReader(rec.body.Bytes())),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "response does not match openapi spec", "method", r.Method, "path", r.URL.Path, "status", rec.status, "error", err)
+			NewErrorResponse(w, r, fmt.Errorf("%s: %w", err, ErrResponseNotValid))
+			return
+		}
+
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// addViolations found by openapi3filter to invalid, field being the one err belongs to
+func addViolations(invalid *validate.Error, field string, err error) {
+
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, err := range e {
+			addViolations(invalid, field, err)
+		}
+	case *openapi3filter.RequestError:
+		field = "body"
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		if e.Err == nil {
+			invalid.Add(field, e.Reason)
+			return
+		}
+		addViolations(invalid, field, e.Err)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 && field == "body" {
+			field = strings.Join(pointer, ".")
+		}
+		invalid.Add(field, e.Reason)
+	default:
+		invalid.Add(field, err.Error())
+	}
+}
+
+// bufferedResponse holding back the response until it has been validated
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth/scoped"
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/health"
+	idempotencymem "github.com/rgynn/subscription-api/pkg/idempotency/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/subscription/eventbus"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/subscription/service"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+	webhookmem "github.com/rgynn/subscription-api/pkg/webhook/repo/mem"
+)
+
+type stubOperators struct {
+	calls int64
+	// unknown msisdn the operator is not found for
+	unknown string
+}
+
+func (repo *stubOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	atomic.AddInt64(&repo.calls, 1)
+	if *msisdn == repo.unknown {
+		return nil, operator.ErrNotFound
+	}
+	name := "Tele2 Sverige AB"
+	return &name, nil
+}
+
+// newTestServer with in memory storage validating responses against the OpenAPI spec,
+// options are applied before the routes are set up
+func newTestServer(t *testing.T, options ...func(srv *Server)) (http.Handler, *stubOperators) {
+	t.Helper()
+
+	subscriptions, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := mem.NewHistoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := webhookmem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idempotency, err := idempotencymem.NewStore(time.Hour, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operators := &stubOperators{}
+	svc := service.NewService(subscriptions, history, operators, eventbus.New())
+
+	srv := &Server{
+		subscriptions:     scoped.NewRepository(svc),
+		history:           scoped.NewHistory(svc),
+		refresher:         scoped.NewOperatorRefresher(svc),
+		pauser:            scoped.NewPauser(svc),
+		operators:         svc.Operators(),
+		webhooks:          webhook.NewDispatcher(webhooks, webhook.DefaultPolicy, clock.New()),
+		idempotency:       idempotency,
+		health:            health.New(0, clock.New()),
+		validateResponses: true,
+	}
+
+	for _, option := range options {
+		option(srv)
+	}
+
+	router, err := srv.NewRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return router, operators
+}
+
+func TestOpenAPI(t *testing.T) {
+
+	router, _ := newTestServer(t)
+
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	var webhookID string
+
+	steps := []struct {
+		method, path, body string
+		header             []string
+		status             int
+		after              func(w *httptest.ResponseRecorder)
+	}{
+		{method: http.MethodGet, path: "/api/0.1/openapi.json", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/0.1/docs", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions", body: `{"msisdn": "8-6785500", "type": "SATELLITE"}`, status: http.StatusBadRequest, after: func(w *httptest.ResponseRecorder) {
+			var p Problem
+			json.Unmarshal(w.Body.Bytes(), &p)
+			if p.Code != CodeValidationFailed || len(p.Violations) != 2 {
+				t.Fatalf("expected violations for activate_at and type, got: %s", w.Body.String())
+			}
+		}},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions", body: `{"msisdn": "8-6785500", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`, header: []string{IdempotencyKeyHeader, "create-1"}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions", body: `{"msisdn": "8-6785500", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`, status: http.StatusConflict},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions/8-6785500", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions/8-0", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions?status=pending&sort=-activate_at&limit=10", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions?limit=0", status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions?operator_changed_since=2021-05-21T00:00:00Z", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions?operator_changed_since=yesterday", status: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/api/0.1/subscriptions/8-6785500", body: `{"msisdn": "8-6785500", "activate_at": "2021-05-21T00:00:00Z", "type": "CELL"}`, header: []string{"If-Match", `"7"`}, status: http.StatusPreconditionFailed},
+		{method: http.MethodPut, path: "/api/0.1/subscriptions/8-6785500", body: `{"msisdn": "8-6785500", "activate_at": "2021-05-21T00:00:00Z", "type": "CELL"}`, header: []string{"If-Match", `"1"`}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/toggle_paused", header: []string{"If-Match", `"1"`}, status: http.StatusPreconditionFailed},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/toggle_paused", header: []string{"If-Match", `"2"`}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/refresh_operator", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-0/refresh_operator", status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/cancel", header: []string{"If-Match", `"2"`}, status: http.StatusPreconditionFailed},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/cancel", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/subscriptions/8-6785500/cancel", status: http.StatusConflict},
+		{method: http.MethodGet, path: "/api/0.1/subscriptions/8-6785500/history?limit=2", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/0.1/webhooks", body: `{"url": "ftp://crm.example.com"}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/api/0.1/webhooks", body: `{"url": "https://crm.example.com/hooks", "event_types": ["subscription.paused"]}`, status: http.StatusCreated, after: func(w *httptest.ResponseRecorder) {
+			var m webhook.Model
+			json.Unmarshal(w.Body.Bytes(), &m)
+			webhookID = *m.ID
+		}},
+		{method: http.MethodGet, path: "/api/0.1/webhooks", status: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		w := do(step.method, step.path, step.body, step.header...)
+		if w.Code != step.status {
+			t.Fatalf("%s %s: expected %d, got: %d %s", step.method, step.path, step.status, w.Code, w.Body.String())
+		}
+		if step.after != nil {
+			step.after(w)
+		}
+	}
+
+	if w := do(http.MethodGet, "/api/0.1/webhooks/"+webhookID+"/deliveries", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected deliveries, got: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/api/0.1/webhooks/"+webhookID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected webhook deleted, got: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/api/0.1/webhooks/"+webhookID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted webhook not found, got: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUnknownOperator(t *testing.T) {
+
+	router, operators := newTestServer(t)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/api/0.1/subscriptions", `{"msisdn": "8-6785500", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected subscription created, got: %d %s", w.Code, w.Body.String())
+	}
+
+	// numbers the operator source does not know are rejected, not internal errors
+	for _, step := range []struct{ unknown, path string }{
+		{"8-6785500", "/api/0.1/subscriptions/8-6785500/refresh_operator"},
+		{"8-6785501", "/api/0.1/subscriptions"},
+	} {
+
+		operators.unknown = step.unknown
+
+		w := do(http.MethodPost, step.path, `{"msisdn": "8-6785501", "activate_at": "2031-05-21T00:00:00Z", "type": "PBX"}`)
+
+		var p Problem
+		json.Unmarshal(w.Body.Bytes(), &p)
+
+		if w.Code != http.StatusUnprocessableEntity || p.Code != CodeOperatorNotFound {
+			t.Fatalf("%s: expected operator not found, got: %d %s", step.path, w.Code, w.Body.String())
+		}
+	}
+}
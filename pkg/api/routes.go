@@ -4,17 +4,75 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/metrics"
 )
 
 func (srv *Server) NewRouter() (*mux.Router, error) {
 
+	srv.roles = map[*mux.Route]auth.Role{}
+
 	router := mux.NewRouter()
-	router.HandleFunc("/api/0.1/subscriptions", srv.SubscriptionsListHandler).Methods(http.MethodGet)
-	router.HandleFunc("/api/0.1/subscriptions", srv.SubscriptionsCreateHandler).Methods(http.MethodPost)
-	router.HandleFunc("/api/0.1/subscriptions/{msisdn}", srv.SubscriptionsGetHandler).Methods(http.MethodGet)
-	router.HandleFunc("/api/0.1/subscriptions/{msisdn}", srv.SubscriptionsUpdateHandler).Methods(http.MethodPut)
-	router.HandleFunc("/api/0.1/subscriptions/{msisdn}/toggle_paused", srv.SubscriptionsTogglePausedHandler).Methods(http.MethodPost)
-	router.HandleFunc("/api/0.1/subscriptions/{msisdn}/cancel", srv.SubscriptionsCancelHandler).Methods(http.MethodPost)
+	srv.require(auth.RoleReader, router.HandleFunc("/api/0.1/subscriptions", srv.SubscriptionsListHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAgent, router.HandleFunc("/api/0.1/subscriptions", srv.SubscriptionsCreateHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleReader, router.HandleFunc("/api/0.1/subscriptions/{msisdn}", srv.SubscriptionsGetHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAgent, router.HandleFunc("/api/0.1/subscriptions/{msisdn}", srv.SubscriptionsUpdateHandler).Methods(http.MethodPut))
+	srv.require(auth.RoleAgent, router.HandleFunc("/api/0.1/subscriptions/{msisdn}/toggle_paused", srv.SubscriptionsTogglePausedHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/subscriptions/{msisdn}/cancel", srv.SubscriptionsCancelHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleAgent, router.HandleFunc("/api/0.1/subscriptions/{msisdn}/refresh_operator", srv.SubscriptionsRefreshOperatorHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleReader, router.HandleFunc("/api/0.1/subscriptions/{msisdn}/history", srv.SubscriptionsHistoryHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/webhooks", srv.WebhooksListHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/webhooks", srv.WebhooksCreateHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/webhooks/{id}", srv.WebhooksDeleteHandler).Methods(http.MethodDelete))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/webhooks/{id}/deliveries", srv.WebhooksDeliveriesHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/api_keys", srv.APIKeysListHandler).Methods(http.MethodGet))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/api_keys", srv.APIKeysCreateHandler).Methods(http.MethodPost))
+	srv.require(auth.RoleAdmin, router.HandleFunc("/api/0.1/api_keys/{id}", srv.APIKeysDeleteHandler).Methods(http.MethodDelete))
+	router.HandleFunc("/api/0.1/openapi.json", srv.OpenAPIHandler).Methods(http.MethodGet)
+	router.HandleFunc("/api/0.1/docs", srv.DocsHandler).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", srv.HealthzHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", srv.ReadyzHandler).Methods(http.MethodGet)
+
+	schema, err := NewGraphQLSchema(srv)
+	if err != nil {
+		return nil, err
+	}
+
+	// mutations require more than reading, they are checked by their resolvers
+	srv.require(auth.RoleReader, router.Handle("/graphql", srv.GraphQLHandler(schema)).Methods(http.MethodPost))
+	if srv.graphiql {
+		router.HandleFunc("/graphql", srv.GraphiQLHandler).Methods(http.MethodGet)
+	}
+
+	validator, err := NewOpenAPIValidator(srv.validateResponses)
+	if err != nil {
+		return nil, err
+	}
+
+	router.Use(srv.RequestContextMiddleware)
+	router.Use(srv.AccessLogMiddleware)
+	router.Use(srv.MetricsMiddleware)
+	router.Use(srv.AuthMiddleware)
+	router.Use(srv.JSONMiddleware)
+	router.Use(validator.Middleware)
+	router.Use(srv.IdempotencyMiddleware)
 
 	return router, nil
 }
+
+// NewAdminRouter of the admin listener, kept apart from the api so it is not exposed with it
+func (srv *Server) NewAdminRouter() *mux.Router {
+
+	router := mux.NewRouter()
+	router.Handle("/metrics", metrics.Handler(srv.metrics)).Methods(http.MethodGet)
+	// probes keep working on the admin listener while the api drains on shutdown
+	router.HandleFunc("/healthz", srv.HealthzHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", srv.ReadyzHandler).Methods(http.MethodGet)
+
+	return router
+}
+
+// require role to call route, routes not listed are public
+func (srv *Server) require(role auth.Role, route *mux.Route) {
+	srv.roles[route] = role
+}
@@ -1,29 +1,182 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/auth/oidc"
+	authfile "github.com/rgynn/subscription-api/pkg/auth/repo/file"
+	"github.com/rgynn/subscription-api/pkg/auth/scoped"
+	"github.com/rgynn/subscription-api/pkg/clock"
 	"github.com/rgynn/subscription-api/pkg/config"
+	"github.com/rgynn/subscription-api/pkg/health"
+	"github.com/rgynn/subscription-api/pkg/idempotency"
+	idempotencymem "github.com/rgynn/subscription-api/pkg/idempotency/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/logging"
+	"github.com/rgynn/subscription-api/pkg/metrics"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/resilience"
+	"github.com/rgynn/subscription-api/pkg/rpc"
 	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/eventbus"
+	"github.com/rgynn/subscription-api/pkg/subscription/refresher"
+	"github.com/rgynn/subscription-api/pkg/subscription/scheduler"
 	subs "github.com/rgynn/subscription-api/pkg/subscription/service"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+	webhookmem "github.com/rgynn/subscription-api/pkg/webhook/repo/mem"
+	webhooksql "github.com/rgynn/subscription-api/pkg/webhook/repo/sql"
 )
 
+// ErrForcedShutdown is returned when in flight requests did not finish within the shutdown timeout
+var ErrForcedShutdown = errors.New("forced shutdown, in flight requests did not finish in time")
+
 type Server struct {
 	*http.Server
 	subscriptions subscription.Repository
+	history       subscription.HistoryReader
+	refresher     subscription.OperatorRefresher
+	pauser        subscription.Pauser
+	operators     operator.Repository
+	scheduler     *scheduler.Scheduler
+	// operatorRefresh verifying stored operators in the background, nil if disabled
+	operatorRefresh *refresher.Refresher
+	events          subscription.EventBus
+	webhooks        *webhook.Dispatcher
+	idempotency     idempotency.Store
+	health          *health.Checker
+	roles           map[*mux.Route]auth.Role
+	storage         io.Closer
+	rpc             *rpc.Server
+	rpcAddr         string
+	// admin serving metrics apart from the api
+	admin *http.Server
+	// metrics of this server, served by admin along with metrics.Registry
+	metrics         *prometheus.Registry
+	shutdownTimeout time.Duration
+	// authn of the api keys and bearer tokens of requests, disabled if it has neither
+	authn auth.Authenticator
+	// validateResponses against the OpenAPI spec, meant for tests
+	validateResponses bool
+	// graphiql page served at GET /graphql, meant for development
+	graphiql bool
 }
 
 func NewServerFromConfig(cfg *config.Config) (*Server, error) {
 
-	srv := &Server{}
+	events := eventbus.New()
+	events.Subscribe("log", logEvent)
 
-	subscriptions, err := subs.NewServiceFromConfig(cfg)
+	subscriptions, err := subs.NewServiceFromConfig(cfg, events)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize subscription service for server: %w", err)
 	}
 
-	srv.subscriptions = subscriptions
+	srv, err := newServerFromConfig(cfg, subscriptions, events)
+	if err != nil {
+		events.Close()
+		subscriptions.Close()
+		return nil, err
+	}
+
+	// workers start once nothing else can fail, a worker failing to start stops those started before it
+	if err := srv.start(); err != nil {
+		srv.stop()
+		subscriptions.Close()
+		return nil, err
+	}
+
+	return srv, nil
+}
+
+// newServerFromConfig using subscriptions and events, with none of its workers started
+func newServerFromConfig(cfg *config.Config, subscriptions *subs.Service, events subscription.EventBus) (*Server, error) {
+
+	srv := &Server{
+		events:            events,
+		rpcAddr:           cfg.GRPCPort,
+		shutdownTimeout:   cfg.ShutdownTimeout,
+		validateResponses: cfg.OpenAPIValidateResponses,
+		graphiql:          cfg.GraphiQL,
+	}
+
+	webhooks, err := webhooksFromConfig(cfg, subscriptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize webhook repository for server: %w", err)
+	}
+
+	srv.webhooks = webhook.NewDispatcher(webhooks, webhook.Policy{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Backoff:     resilience.Backoff{Base: cfg.WebhookBackoffBase, Max: cfg.WebhookBackoffMax},
+		Timeout:     cfg.WebhookTimeout,
+		Concurrency: webhook.DefaultPolicy.Concurrency,
+		Poll:        webhook.DefaultPolicy.Poll,
+	}, clock.New())
+
+	// deliveries are created with the changes, the event bus only has them drained promptly
+	subscriptions.SetOutbox(srv.webhooks)
+
+	srv.scheduler = scheduler.New(subscriptions, clock.New())
+
+	if cfg.OperatorRefreshInterval > 0 {
+		srv.operatorRefresh = refresher.New(subscriptions, cfg.OperatorRefreshInterval, cfg.OperatorRefreshMaxAge, cfg.OperatorRefreshPace, clock.New())
+	}
+
+	if !cfg.AuthDisabled && cfg.APIKeysFile != "" {
+		keys, err := authfile.NewRepository(cfg.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize api key repository for server: %w", err)
+		}
+		srv.authn.Keys = auth.NewKeys(keys, clock.New())
+	}
+
+	if !cfg.AuthDisabled && cfg.OIDCIssuer != "" {
+		tokens, err := newVerifierFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize bearer token verifier for server: %w", err)
+		}
+		srv.authn.Tokens = tokens
+	}
+
+	srv.idempotency, err = idempotencymem.NewStore(cfg.IdempotencyTTL, clock.New())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize idempotency store for server: %w", err)
+	}
+
+	// principals scoped by their bearer tokens only see the subscriptions of their msisdns
+	srv.subscriptions = scoped.NewRepository(srv.scheduler)
+	srv.history = scoped.NewHistory(subscriptions)
+	srv.refresher = scoped.NewOperatorRefresher(subscriptions)
+	srv.pauser = scoped.NewPauser(subscriptions)
+	srv.operators = subscriptions.Operators()
+	srv.storage = subscriptions
+	srv.rpc = rpc.NewServer(srv.subscriptions, srv.events, &srv.authn)
+
+	srv.health = health.New(cfg.HealthCacheTTL, clock.New())
+	srv.health.Register("storage", cfg.HealthCheckTimeout, subscriptions.CheckStorage)
+	srv.health.Register("operators", cfg.HealthCheckTimeout, subscriptions.CheckOperators)
+	srv.health.Register("scheduler", cfg.HealthCheckTimeout, func(ctx context.Context) error {
+		if !srv.scheduler.Running() {
+			return errors.New("activation scheduler is not running")
+		}
+		return nil
+	})
+
+	// the collector belongs to this server, registering it globally would fail for a second one
+	srv.metrics = prometheus.NewRegistry()
+	if err := srv.metrics.Register(metrics.NewSubscriptionCollector(subscriptions)); err != nil {
+		return nil, fmt.Errorf("failed to register subscription metrics for server: %w", err)
+	}
 
 	router, err := srv.NewRouter()
 	if err != nil {
@@ -38,5 +191,160 @@ func NewServerFromConfig(cfg *config.Config) (*Server, error) {
 		Handler:      router,
 	}
 
+	srv.admin = &http.Server{
+		Addr:        cfg.AdminPort,
+		IdleTimeout: cfg.IdleTimeout,
+		ReadTimeout: cfg.ReadTimeout,
+		Handler:     srv.NewAdminRouter(),
+	}
+
 	return srv, nil
 }
+
+// start background workers of server
+func (srv *Server) start() error {
+
+	if err := srv.webhooks.Start(context.Background()); err != nil {
+		return fmt.Errorf("failed to start webhook dispatcher for server: %w", err)
+	}
+
+	srv.events.Subscribe("webhooks", srv.webhooks.Handle)
+
+	if err := srv.scheduler.Start(context.Background()); err != nil {
+		return fmt.Errorf("failed to start activation scheduler for server: %w", err)
+	}
+
+	if srv.operatorRefresh != nil {
+		if err := srv.operatorRefresh.Start(context.Background()); err != nil {
+			return fmt.Errorf("failed to start operator refresher for server: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// stop background workers of server, those not started are skipped
+func (srv *Server) stop() {
+
+	// stop producing events before draining the bus, so no change goes unpublished
+	srv.scheduler.Stop()
+
+	if srv.operatorRefresh != nil {
+		srv.operatorRefresh.Stop()
+	}
+
+	if err := srv.events.Close(); err != nil {
+		slog.Error("failed to close event bus", "error", err)
+	}
+
+	// the bus has handed every event to the dispatcher, let it finish deliveries in flight
+	srv.webhooks.Stop()
+}
+
+// webhooksFromConfig stores webhooks in the same database as the subscriptions, so deliveries
+// left unfinished are picked up again after a restart
+func webhooksFromConfig(cfg *config.Config, subscriptions *subs.Service) (webhook.Repository, error) {
+
+	if cfg.Database == "memory" {
+		return webhookmem.NewRepository()
+	}
+
+	return webhooksql.NewRepository(subscriptions.DB())
+}
+
+// newVerifierFromConfig of bearer tokens, loading the keys of the OIDC provider from url or file
+func newVerifierFromConfig(cfg *config.Config) (*oidc.Verifier, error) {
+
+	var (
+		keys *oidc.JWKS
+		err  error
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ClientTimeout)
+	defer cancel()
+
+	if cfg.OIDCJWKSURL != "" {
+		keys, err = oidc.NewJWKSFromURL(ctx, cfg.OIDCJWKSURL, &http.Client{Timeout: cfg.ClientTimeout}, cfg.OIDCJWKSRefresh, clock.New())
+	} else {
+		keys, err = oidc.NewJWKSFromFile(ctx, cfg.OIDCJWKSFile, cfg.OIDCJWKSRefresh, clock.New())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return oidc.NewVerifier(keys, oidc.Options{
+		Issuer:      cfg.OIDCIssuer,
+		Audience:    cfg.OIDCAudience,
+		Leeway:      cfg.OIDCLeeway,
+		MSISDNClaim: cfg.OIDCMSISDNClaim,
+	}, clock.New()), nil
+}
+
+// Run http, grpc and admin server until SIGINT or SIGTERM is received, then shut them down gracefully
+func (srv *Server) Run() error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 3)
+	go func() { errc <- srv.ListenAndServe() }()
+	go func() { errc <- srv.rpc.ListenAndServe(srv.rpcAddr) }()
+	go func() { errc <- srv.admin.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		srv.Shutdown(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+
+	// a second signal kills the process instead of waiting for the shutdown
+	stop()
+	slog.Info("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), srv.shutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(ctx)
+}
+
+// Shutdown server by draining in flight requests and grpc calls until ctx is done, then
+// stopping background workers and closing storage. Returns ErrForcedShutdown if connections
+// had to be closed before their requests finished.
+func (srv *Server) Shutdown(ctx context.Context) error {
+
+	var forced bool
+
+	srv.health.Drain()
+
+	if err := srv.Server.Shutdown(ctx); err != nil {
+		forced = true
+		srv.Server.Close()
+	}
+
+	if err := srv.rpc.Shutdown(ctx); err != nil {
+		forced = true
+	}
+
+	// metrics stay available while the api drains
+	if err := srv.admin.Shutdown(ctx); err != nil {
+		srv.admin.Close()
+	}
+
+	srv.stop()
+
+	if err := srv.storage.Close(); err != nil {
+		return fmt.Errorf("failed to close storage: %w", err)
+	}
+
+	if forced {
+		return ErrForcedShutdown
+	}
+
+	return nil
+}
+
+func logEvent(ctx context.Context, e subscription.DomainEvent) {
+	meta := e.Metadata()
+	slog.InfoContext(ctx, "subscription changed", "event", e.Type(), "msisdn", meta.MSISDN, "actor", meta.Actor, logging.RequestIDKey, meta.RequestID)
+}
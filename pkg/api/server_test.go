@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rgynn/subscription-api/pkg/config"
+)
+
+func TestNewServerFromConfig(t *testing.T) {
+
+	t.Setenv("DATABASE", "memory")
+	t.Setenv("PTS_URL", "http://127.0.0.1:1/")
+	t.Setenv("AUTH_DISABLED", "true")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every server has its own subscription metrics, so a second one can be constructed
+	for i := 0; i < 2; i++ {
+
+		srv, err := NewServerFromConfig(cfg)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+
+		w := httptest.NewRecorder()
+		srv.admin.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d: expected metrics to be served, got: %d %s", i, w.Code, w.Body.String())
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnauthenticated returned if a caller provided no or invalid credentials
+var ErrUnauthenticated = errors.New("no valid credentials provided")
+
+// ErrForbidden returned if the role of a caller does not allow what it attempted
+var ErrForbidden = errors.New("not allowed for the role of the caller")
+
+// Role of a caller, each role includes the permissions of the roles below it
+type Role string
+
+const (
+	// RoleReader may read subscriptions
+	RoleReader Role = "reader"
+	// RoleAgent may also create, update, pause and resume subscriptions
+	RoleAgent Role = "agent"
+	// RoleAdmin may also cancel subscriptions and manage webhooks and api keys
+	RoleAdmin Role = "admin"
+)
+
+var ranks = map[Role]int{
+	RoleReader: 1,
+	RoleAgent:  2,
+	RoleAdmin:  3,
+}
+
+// Valid reports if r is a known role
+func (r Role) Valid() bool {
+	return ranks[r] > 0
+}
+
+// Allows reports if r includes the permissions of required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && ranks[r] >= ranks[required]
+}
+
+// Principal authenticated for a request
+type Principal struct {
+	// Subject identifying the caller, recorded as actor of the changes it makes
+	Subject string
+	Role    Role
+	// MSISDNs the principal is limited to, nil if it may see every subscription
+	MSISDNs []string
+}
+
+// Scoped reports if p is limited to the subscriptions of some msisdns
+func (p *Principal) Scoped() bool {
+	return p != nil && p.MSISDNs != nil
+}
+
+// Sees reports if p may see the subscription of msisdn
+func (p *Principal) Sees(msisdn string) bool {
+
+	if !p.Scoped() {
+		return true
+	}
+
+	for _, m := range p.MSISDNs {
+		if m == msisdn {
+			return true
+		}
+	}
+
+	return false
+}
+
+type key int
+
+const principalKey key = iota
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFrom ctx, nil if the request was not authenticated
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey).(*Principal)
+	return p
+}
+
+// Require returns ErrUnauthenticated if ctx carries no principal and ErrForbidden if its role does not allow role
+func Require(ctx context.Context, role Role) error {
+
+	p := PrincipalFrom(ctx)
+	if p == nil {
+		return ErrUnauthenticated
+	}
+
+	if !p.Role.Allows(role) {
+		return fmt.Errorf("%s role required, %s has role %s: %w", role, p.Subject, p.Role, ErrForbidden)
+	}
+
+	return nil
+}
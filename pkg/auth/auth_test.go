@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+)
+
+// stubRepository of keys, the repositories are tested in their own packages
+type stubRepository struct {
+	keys map[string]*Key
+}
+
+func (repo *stubRepository) List(ctx context.Context) ([]*Key, error) { return nil, nil }
+
+func (repo *stubRepository) Get(ctx context.Context, id *string) (*Key, error) {
+	k, ok := repo.keys[*id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return k, nil
+}
+
+func (repo *stubRepository) Create(ctx context.Context, k *Key) (*Key, error) {
+	repo.keys[*k.ID] = k
+	return k, nil
+}
+
+func (repo *stubRepository) Delete(ctx context.Context, id *string) error { return nil }
+
+func TestKeys(t *testing.T) {
+
+	repo := &stubRepository{keys: map[string]*Key{}}
+	keys := NewKeys(repo, clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	name, role := "support", RoleAgent
+	created, err := keys.Create(ctx, &Key{Name: &name, Role: &role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Key == nil || !strings.HasPrefix(*created.Key, KeyPrefix) || created.Hash != nil {
+		t.Fatalf("expected the key and not its hash to be returned, got: %+v", created)
+	}
+
+	if stored := repo.keys[*created.ID]; stored.Key != nil || stored.Hash == nil || strings.Contains(*created.Key, *stored.Hash) {
+		t.Fatalf("expected only the hash of the key to be stored, got: %+v", stored)
+	}
+
+	principal, err := keys.Authenticate(ctx, *created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if principal.Subject != "apikey:"+*created.ID || principal.Role != RoleAgent {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+
+	for _, key := range []string{"", "support", KeyPrefix + *created.ID, *created.Key + "x", KeyPrefix + "unknown.secret"} {
+		if _, err := keys.Authenticate(ctx, key); !errors.Is(err, ErrUnauthenticated) {
+			t.Fatalf("expected %q to be unauthenticated, got: %v", key, err)
+		}
+	}
+
+	admin := Role("root")
+	if _, err := keys.Create(ctx, &Key{Name: &name, Role: &admin}); !errors.Is(err, ErrNotValid) {
+		t.Fatalf("expected unknown role to be rejected, got: %v", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+
+	if err := Require(context.Background(), RoleReader); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated without principal, got: %v", err)
+	}
+
+	ctx := WithPrincipal(context.Background(), &Principal{Subject: "apikey:support", Role: RoleAgent})
+
+	for role, allowed := range map[Role]bool{RoleReader: true, RoleAgent: true, RoleAdmin: false} {
+		err := Require(ctx, role)
+		if allowed && err != nil || !allowed && !errors.Is(err, ErrForbidden) {
+			t.Fatalf("expected agent allowed %s to be %t, got: %v", role, allowed, err)
+		}
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+
+	ctx := reqctx.WithActor(context.Background(), "support")
+
+	ctx, err := (&Authenticator{}).Authenticate(ctx, NewCredentials("", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := PrincipalFrom(ctx); p == nil || p.Subject != "support" || p.Role != RoleAdmin {
+		t.Fatalf("expected the actor to be an admin with authentication disabled, got: %+v", p)
+	}
+
+	keys := NewKeys(&stubRepository{keys: map[string]*Key{}}, clock.New())
+	authn := &Authenticator{Keys: keys}
+
+	ctx, err = authn.Authenticate(reqctx.WithActor(context.Background(), "spoofed"), NewCredentials("", ""))
+	if err != nil || PrincipalFrom(ctx) != nil || reqctx.Actor(ctx) != reqctx.AnonymousActor {
+		t.Fatalf("expected caller without credentials to be anonymous, got: %s, %v", reqctx.Actor(ctx), err)
+	}
+
+	if _, err := authn.Authenticate(context.Background(), NewCredentials("", "Bearer token")); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("expected bearer token to be rejected without an OIDC provider, got: %v", err)
+	}
+
+	if creds := NewCredentials("", "bearer  token "); !creds.Bearer || creds.Token != "token" {
+		t.Fatalf("expected bearer token to be parsed, got: %+v", creds)
+	}
+}
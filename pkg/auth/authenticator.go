@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+)
+
+// TokenVerifier authenticating bearer tokens
+type TokenVerifier interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// Credentials presented by a caller
+type Credentials struct {
+	// APIKey presented, empty if none was
+	APIKey string
+	// Token presented as bearer token, Bearer reports if one was
+	Token  string
+	Bearer bool
+}
+
+// NewCredentials presented as an api key and an authorization header, either may be empty
+func NewCredentials(apiKey, authorization string) Credentials {
+
+	creds := Credentials{APIKey: apiKey}
+
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		creds.Token, creds.Bearer = strings.TrimSpace(token), true
+	}
+
+	return creds
+}
+
+// Authenticator of callers by api key or bearer token, shared by the http and grpc api
+type Authenticator struct {
+	// Keys authenticating api keys, nil if api keys are disabled
+	Keys *Keys
+	// Tokens authenticating bearer tokens, nil if no OIDC provider is configured
+	Tokens TokenVerifier
+}
+
+// Disabled reports if neither api keys nor bearer tokens are enabled
+func (a *Authenticator) Disabled() bool {
+	return a == nil || a.Keys == nil && a.Tokens == nil
+}
+
+// Authenticate caller presenting creds, returning ctx carrying its principal as the actor of changes.
+// With authentication disabled every caller is an admin named by the actor of ctx, callers
+// presenting no credentials are anonymous.
+func (a *Authenticator) Authenticate(ctx context.Context, creds Credentials) (context.Context, error) {
+
+	switch {
+	case a.Disabled():
+		return WithPrincipal(ctx, &Principal{Subject: reqctx.Actor(ctx), Role: RoleAdmin}), nil
+	case creds.APIKey != "" || creds.Bearer:
+		principal, err := a.principal(ctx, creds)
+		if err != nil {
+			return nil, err
+		}
+		return reqctx.WithActor(WithPrincipal(ctx, principal), principal.Subject), nil
+	default:
+		// the actor of ctx is only trusted when authentication is disabled
+		return reqctx.WithActor(ctx, reqctx.AnonymousActor), nil
+	}
+}
+
+// principal of the api key or, if none was presented, bearer token with whichever is enabled
+func (a *Authenticator) principal(ctx context.Context, creds Credentials) (*Principal, error) {
+
+	switch {
+	case creds.APIKey != "" && a.Keys != nil:
+		return a.Keys.Authenticate(ctx, creds.APIKey)
+	case creds.APIKey == "" && a.Tokens != nil:
+		return a.Tokens.Authenticate(ctx, creds.Token)
+	default:
+		return nil, fmt.Errorf("credentials of a disabled kind provided: %w", ErrUnauthenticated)
+	}
+}
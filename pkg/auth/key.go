@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/validate"
+)
+
+// ErrNotFound returned if an api key not found for the provided id
+var ErrNotFound = errors.New("api key not found for the provided id")
+
+// ErrNotValid returned if provided api key not valid
+var ErrNotValid = errors.New("provided api key not valid")
+
+// KeyPrefix of api keys, making them easy to recognize in leaked secrets
+const KeyPrefix = "sak_"
+
+// Repository interface for api keys, keys are stored hashed
+type Repository interface {
+	List(ctx context.Context) ([]*Key, error)
+	Get(ctx context.Context, id *string) (*Key, error)
+	Create(ctx context.Context, k *Key) (*Key, error)
+	Delete(ctx context.Context, id *string) error
+}
+
+// Key authenticating a caller with a role, the key itself is only known when created
+type Key struct {
+	ID        *string    `json:"id"`
+	Name      *string    `json:"name"`
+	Role      *Role      `json:"role"`
+	Hash      *string    `json:"hash,omitempty"`
+	Key       *string    `json:"key,omitempty"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// ValidForSave returns a validate.Error listing every field not valid for creating an api key
+func (k *Key) ValidForSave() error {
+
+	if k == nil {
+		return fmt.Errorf("no api key provided: %w", ErrNotValid)
+	}
+
+	v := validate.New(ErrNotValid)
+
+	if k.ID != nil {
+		v.Add("id", "is read only")
+	}
+
+	if k.Hash != nil {
+		v.Add("hash", "is read only")
+	}
+
+	if k.Key != nil {
+		v.Add("key", "is read only")
+	}
+
+	if k.CreatedAt != nil {
+		v.Add("created_at", "is read only")
+	}
+
+	if k.Name == nil || *k.Name == "" {
+		v.Add("name", "is required")
+	}
+
+	if k.Role == nil {
+		v.Add("role", "is required")
+	} else if !k.Role.Valid() {
+		v.Add("role", "needs to be one of reader, agent or admin")
+	}
+
+	return v.Err()
+}
+
+// Redacted copy of the key without the key and its hash
+func (k *Key) Redacted() *Key {
+	result := *k
+	result.Hash, result.Key = nil, nil
+	return &result
+}
+
+// Subject of the principal authenticated by the key, by its id as names are not unique
+func (k *Key) Subject() string {
+	return "apikey:" + *k.ID
+}
+
+// Hash of the secret part of a key
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseKey into the id it is stored by and its secret
+func parseKey(key string) (id, secret string, ok bool) {
+
+	if !strings.HasPrefix(key, KeyPrefix) {
+		return "", "", false
+	}
+
+	id, secret, ok = strings.Cut(strings.TrimPrefix(key, KeyPrefix), ".")
+
+	return id, secret, ok && id != "" && secret != ""
+}
+
+// Keys issuing and authenticating api keys stored in the repository
+type Keys struct {
+	Repository
+	clock clock.Clock
+}
+
+// NewKeys stored in repo
+func NewKeys(repo Repository, clk clock.Clock) *Keys {
+	return &Keys{Repository: repo, clock: clk}
+}
+
+// Create key with a generated id and secret, the returned key is the only time the secret is known
+func (keys *Keys) Create(ctx context.Context, k *Key) (*Key, error) {
+
+	if err := k.ValidForSave(); err != nil {
+		return nil, err
+	}
+
+	id := randomString(8)
+	secret := randomString(32)
+	hash := Hash(secret)
+	now := keys.clock.Now()
+
+	k.ID, k.Hash, k.CreatedAt = &id, &hash, &now
+
+	created, err := keys.Repository.Create(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	result := created.Redacted()
+	key := KeyPrefix + id + "." + secret
+	result.Key = &key
+
+	return result, nil
+}
+
+// Authenticate the caller presenting key
+func (keys *Keys) Authenticate(ctx context.Context, key string) (*Principal, error) {
+
+	id, secret, ok := parseKey(key)
+	if !ok {
+		return nil, fmt.Errorf("malformed api key: %w", ErrUnauthenticated)
+	}
+
+	k, err := keys.Repository.Get(ctx, &id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, fmt.Errorf("unknown api key: %w", ErrUnauthenticated)
+	case err != nil:
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(*k.Hash)) != 1 {
+		return nil, fmt.Errorf("unknown api key: %w", ErrUnauthenticated)
+	}
+
+	return &Principal{Subject: k.Subject(), Role: *k.Role}, nil
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %s", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,328 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrKeyNotFound returned if no key of the key set has the id a token was signed with
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// DefaultRefresh of key sets, keys are also reloaded when a token names a key not seen before
+const DefaultRefresh = time.Hour
+
+// MinRefreshInterval between reloads, so tokens naming unknown keys can not hammer the source
+const MinRefreshInterval = 30 * time.Second
+
+// JWKS caches the public keys of a JSON Web Key Set, see RFC 7517. The set is reloaded every
+// refresh interval and when a token names an unknown key, so keys can be rotated at the source.
+// A failed reload keeps the keys loaded before, and keys are served from the current set while a
+// stale one is reloaded.
+type JWKS struct {
+	load    func(ctx context.Context) ([]byte, error)
+	source  string
+	refresh time.Duration
+	clock   clock.Clock
+	// current keys, swapped as a whole once a reload succeeds
+	current atomic.Pointer[keySet]
+	// reloads in flight are shared by every caller wanting one
+	group singleflight.Group
+	// triedAt is when the last reload ended, guarded by mu
+	triedAt time.Time
+	mu      sync.Mutex
+}
+
+// keySet loaded at a point in time
+type keySet struct {
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewJWKSFromURL loading the key set from url, failing if it can not be loaded initially
+func NewJWKSFromURL(ctx context.Context, url string, client *http.Client, refresh time.Duration, clk clock.Clock) (*JWKS, error) {
+
+	load := func(ctx context.Context) ([]byte, error) {
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to do request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	return newJWKS(ctx, url, load, refresh, clk)
+}
+
+// NewJWKSFromFile loading the key set from the file at path, failing if it can not be loaded initially
+func NewJWKSFromFile(ctx context.Context, path string, refresh time.Duration, clk clock.Clock) (*JWKS, error) {
+
+	load := func(ctx context.Context) ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}
+
+	return newJWKS(ctx, path, load, refresh, clk)
+}
+
+func newJWKS(ctx context.Context, source string, load func(ctx context.Context) ([]byte, error), refresh time.Duration, clk clock.Clock) (*JWKS, error) {
+
+	set := &JWKS{
+		load:    load,
+		source:  source,
+		refresh: refresh,
+		clock:   clk,
+	}
+
+	set.current.Store(&keySet{})
+
+	if err := set.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// Key with id kid, an empty kid matches the only key of a set with one key
+func (set *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+
+	now := set.clock.Now()
+	current := set.current.Load()
+
+	key, found := current.find(kid)
+
+	// reload stale sets, and sets missing the key in case it was rotated in at the source
+	stale := now.Sub(current.loadedAt) >= set.refresh
+	if (stale || !found) && set.due(now) {
+
+		// the shared reload must not be cancelled by whichever caller started it
+		detached := context.WithoutCancel(ctx)
+
+		ch := set.group.DoChan(set.source, func() (interface{}, error) {
+			return nil, set.reload(detached)
+		})
+
+		// a stale set still has the key, serve it while the set is reloaded
+		if found {
+			return key, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-ch:
+			if res.Err != nil {
+				slog.WarnContext(ctx, "failed to reload jwks, keeping keys loaded before", "keys", len(current.keys), "error", res.Err)
+			}
+		}
+
+		key, found = set.current.Load().find(kid)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%q: %w", kid, ErrKeyNotFound)
+	}
+
+	return key, nil
+}
+
+// due reports if the set may be reloaded at now, at most once every MinRefreshInterval
+func (set *JWKS) due(now time.Time) bool {
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	return now.Sub(set.triedAt) >= MinRefreshInterval
+}
+
+func (ks *keySet) find(kid string) (crypto.PublicKey, bool) {
+
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// reload keys from the source and swap them in, callers waiting on a reload in flight share it
+func (set *JWKS) reload(ctx context.Context) error {
+
+	// checked again as a reload may have finished since the caller checked
+	if !set.due(set.clock.Now()) {
+		return nil
+	}
+
+	keys, err := set.fetch(ctx)
+	if err == nil {
+		set.current.Store(&keySet{keys: keys, loadedAt: set.clock.Now()})
+	}
+
+	// tried once the attempt is over, so callers arriving meanwhile join it instead of giving up
+	set.mu.Lock()
+	set.triedAt = set.clock.Now()
+	set.mu.Unlock()
+
+	return err
+}
+
+func (set *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+
+	data, err := set.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jwks from %s: %w", set.source, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwks from %s: %w", set.source, err)
+	}
+
+	return keys, nil
+}
+
+// jwk of a key set, only the members of RSA and EC signing keys are parsed
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS into public keys by id, keys not meant for signatures or of other types are skipped
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+
+	for _, k := range set.Keys {
+
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var (
+			key crypto.PublicKey
+			err error
+		)
+
+		switch k.Kty {
+		case "RSA":
+			key, err = parseRSA(k)
+		case "EC":
+			key, err = parseEC(k)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys in set")
+	}
+
+	return keys, nil
+}
+
+func parseRSA(k jwk) (*rsa.PublicKey, error) {
+
+	n, err := decodeInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode n: %w", err)
+	}
+
+	e, err := decodeInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode e: %w", err)
+	}
+
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent out of range")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func parseEC(k jwk) (*ecdsa.PublicKey, error) {
+
+	if k.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode x: %w", err)
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode y: %w", err)
+	}
+
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("coordinates need to be 32 bytes")
+	}
+
+	// uncompressed point, checked to be on the curve when parsed
+	point := append(append([]byte{4}, x...), y...)
+
+	return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
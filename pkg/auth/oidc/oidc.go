@@ -0,0 +1,125 @@
+// Package oidc authenticates bearer tokens issued by an OpenID Connect provider, see RFC 7519.
+// Tokens are verified with the keys of a JWKS and scoped to the msisdns listed in their claims.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+// DefaultLeeway allowed between the clocks of the provider and the api
+const DefaultLeeway = 30 * time.Second
+
+// DefaultMSISDNClaim listing the msisdns a token may see
+const DefaultMSISDNClaim = "msisdns"
+
+// RoleClaim naming the role of a token, tokens without one are readers
+const RoleClaim = "role"
+
+// Options of a verifier, issuer and audience are required
+type Options struct {
+	Issuer      string
+	Audience    string
+	Leeway      time.Duration
+	MSISDNClaim string
+}
+
+// Verifier of bearer tokens signed with RS256 or ES256
+type Verifier struct {
+	keys        *JWKS
+	parser      *jwt.Parser
+	msisdnClaim string
+}
+
+func NewVerifier(keys *JWKS, opts Options, clk clock.Clock) *Verifier {
+
+	claim := opts.MSISDNClaim
+	if claim == "" {
+		claim = DefaultMSISDNClaim
+	}
+
+	return &Verifier{
+		keys:        keys,
+		msisdnClaim: claim,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+			jwt.WithIssuer(opts.Issuer),
+			jwt.WithAudience(opts.Audience),
+			jwt.WithLeeway(opts.Leeway),
+			jwt.WithExpirationRequired(),
+			jwt.WithTimeFunc(clk.Now),
+		),
+	}
+}
+
+// Authenticate token, returning a principal scoped to the msisdns of its claims. A token without
+// msisdns sees no subscriptions. Tokens are limited to the agent role, as admin routes are not scoped.
+func (v *Verifier) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+
+	claims := jwt.MapClaims{}
+
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %s: %w", err, auth.ErrUnauthenticated)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("bearer token has no subject: %w", auth.ErrUnauthenticated)
+	}
+
+	role := auth.RoleReader
+	if value, ok := claims[RoleClaim]; ok {
+		s, _ := value.(string)
+		role = auth.Role(s)
+		if !role.Valid() {
+			return nil, fmt.Errorf("bearer token has unknown role %q: %w", s, auth.ErrUnauthenticated)
+		}
+		if role.Allows(auth.RoleAdmin) {
+			role = auth.RoleAgent
+		}
+	}
+
+	msisdns, err := stringList(claims[v.msisdnClaim])
+	if err != nil {
+		return nil, fmt.Errorf("bearer token claim %s: %s: %w", v.msisdnClaim, err, auth.ErrUnauthenticated)
+	}
+
+	return &auth.Principal{
+		Subject: "oidc:" + subject,
+		Role:    role,
+		MSISDNs: msisdns,
+	}, nil
+}
+
+// stringList of a claim that is either a string or an array of strings, never nil
+func stringList(value interface{}) ([]string, error) {
+
+	switch value := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("needs to list strings")
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, errors.New("needs to be a string or a list of strings")
+	}
+}
@@ -0,0 +1,365 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+var now = time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+
+func encode(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(t *testing.T, kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(t *testing.T, kid string, key *ecdsa.PrivateKey) map[string]string {
+	point, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(point[1:33]),
+		"y": base64.RawURLEncoding.EncodeToString(point[33:]),
+	}
+}
+
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss":     "https://id.example.com/",
+		"aud":     "subscription-api",
+		"sub":     "customer-1",
+		"exp":     now.Add(time.Hour).Unix(),
+		"iat":     now.Unix(),
+		"msisdns": []string{"8-6785500", "8-6785501"},
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func TestVerifier(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks(t, rsaJWK(t, "rsa", rsaKey), ecJWK(t, "ec", ecKey)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	clk := clock.NewFake(now)
+	ctx := context.Background()
+
+	keys, err := NewJWKSFromFile(ctx, path, DefaultRefresh, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewVerifier(keys, Options{Issuer: "https://id.example.com/", Audience: "subscription-api", Leeway: DefaultLeeway}, clk)
+
+	tests := []struct {
+		name    string
+		token   string
+		valid   bool
+		role    auth.Role
+		msisdns int
+	}{
+		{name: "rs256", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(nil)), valid: true, role: auth.RoleReader, msisdns: 2},
+		{name: "es256", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"role": "agent"})), valid: true, role: auth.RoleAgent, msisdns: 2},
+		{name: "admin limited to agent", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"role": "admin"})), valid: true, role: auth.RoleAgent, msisdns: 2},
+		{name: "single msisdn", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"msisdns": "8-6785500"})), valid: true, role: auth.RoleReader, msisdns: 1},
+		{name: "no msisdns", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"msisdns": nil})), valid: true, role: auth.RoleReader, msisdns: 0},
+		{name: "expired within leeway", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()})), valid: true, role: auth.RoleReader, msisdns: 2},
+		{name: "expired", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}))},
+		{name: "no expiry", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"exp": nil}))},
+		{name: "not yet valid", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}))},
+		{name: "wrong issuer", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"iss": "https://evil.example.com/"}))},
+		{name: "wrong audience", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"aud": "other-api"}))},
+		{name: "no subject", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"sub": nil}))},
+		{name: "unknown role", token: sign(t, jwt.SigningMethodES256, "ec", ecKey, claims(jwt.MapClaims{"role": "root"}))},
+		{name: "unknown key", token: sign(t, jwt.SigningMethodES256, "other", other, claims(nil))},
+		{name: "wrong key", token: sign(t, jwt.SigningMethodES256, "ec", other, claims(nil))},
+		{name: "hs256", token: sign(t, jwt.SigningMethodHS256, "ec", []byte("secret"), claims(nil))},
+		{name: "malformed", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			principal, err := verifier.Authenticate(ctx, tt.token)
+
+			if !tt.valid {
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					t.Fatalf("expected token to be rejected, got: %+v %v", principal, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if principal.Subject != "oidc:customer-1" || principal.Role != tt.role || !principal.Scoped() || len(principal.MSISDNs) != tt.msisdns {
+				t.Fatalf("unexpected principal: %+v", principal)
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+
+	first, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		set     = jwks(t, ecJWK(t, "first", first))
+		loads   = make(chan struct{}, 10)
+		failing bool
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads <- struct{}{}
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(set)
+	}))
+	defer ts.Close()
+
+	clk := clock.NewFake(now)
+	ctx := context.Background()
+
+	keys, err := NewJWKSFromURL(ctx, ts.URL, ts.Client(), time.Hour, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewVerifier(keys, Options{Issuer: "https://id.example.com/", Audience: "subscription-api"}, clk)
+
+	authenticate := func(kid string, key *ecdsa.PrivateKey) error {
+		_, err := verifier.Authenticate(ctx, sign(t, jwt.SigningMethodES256, kid, key, claims(jwt.MapClaims{"exp": clk.Now().Add(time.Hour).Unix()})))
+		return err
+	}
+
+	if err := authenticate("first", first); err != nil {
+		t.Fatal(err)
+	}
+
+	// a token without kid is verified with the only key of the set
+	if err := authenticate("", first); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	set = jwks(t, ecJWK(t, "first", first), ecJWK(t, "second", second))
+	mu.Unlock()
+
+	// the set was just loaded, unknown keys are not looked up again right away
+	if err := authenticate("second", second); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("expected rotated key to be unknown until the set may be reloaded, got: %v", err)
+	}
+
+	clk.Advance(MinRefreshInterval)
+
+	if err := authenticate("second", second); err != nil {
+		t.Fatalf("expected rotated key to be loaded, got: %v", err)
+	}
+
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+
+	// a stale set is reloaded in the background, a failed reload keeps the keys loaded before
+	clk.Advance(time.Hour)
+
+	if err := authenticate("second", second); err != nil {
+		t.Fatalf("expected keys to be kept when reload fails, got: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-loads:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 3 loads of the set, got: %d", i)
+		}
+	}
+
+	if err := authenticate("second", second); err != nil {
+		t.Fatalf("expected keys to be kept after reload failed, got: %v", err)
+	}
+
+	if len(loads) != 0 {
+		t.Fatal("expected no more loads until the set may be reloaded again")
+	}
+}
+
+func TestJWKSReloadDoesNotBlock(t *testing.T) {
+
+	first, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		loads   = make(chan struct{}, 10)
+		release = make(chan struct{})
+		initial = make(chan []byte, 1)
+		rotated = jwks(t, ecJWK(t, "first", first), ecJWK(t, "second", second))
+	)
+
+	initial <- jwks(t, ecJWK(t, "first", first))
+
+	// the initial set is served right away, reloads once released
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loads <- struct{}{}
+		select {
+		case set := <-initial:
+			w.Write(set)
+			return
+		default:
+		}
+		<-release
+		w.Write(rotated)
+	}))
+	defer ts.Close()
+
+	clk := clock.NewFake(now)
+	ctx := context.Background()
+
+	keys, err := NewJWKSFromURL(ctx, ts.URL, ts.Client(), time.Hour, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-loads
+
+	clk.Advance(time.Hour)
+
+	// callers missing a key wait for the one reload in flight
+	found := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := keys.Key(ctx, "second")
+			found <- err
+		}()
+	}
+
+	<-loads
+
+	// while keys already loaded are served from the stale set
+	if _, err := keys.Key(ctx, "first"); err != nil {
+		t.Fatalf("expected key to be served during reload, got: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := keys.Key(cancelled, "second"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected waiting on the reload to stop with the caller, got: %v", err)
+	}
+
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-found; err != nil {
+			t.Fatalf("expected rotated key to be found, got: %v", err)
+		}
+	}
+
+	if len(loads) != 0 {
+		t.Fatalf("expected a single reload, got: %d more", len(loads))
+	}
+}
+
+func TestNewJWKS(t *testing.T) {
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if _, err := NewJWKSFromFile(ctx, filepath.Join(dir, "missing.json"), DefaultRefresh, clock.New()); err == nil {
+		t.Fatal("expected missing file to fail")
+	}
+
+	path := filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(path, []byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewJWKSFromFile(ctx, path, DefaultRefresh, clock.New()); err == nil {
+		t.Fatal("expected set without signing keys to fail")
+	}
+}
@@ -0,0 +1,223 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+)
+
+// Repository for api keys stored hashed in a json file. The file is read again when it
+// changes, so keys managed with the cli are picked up by a running server.
+type Repository struct {
+	path    string
+	keys    map[string]*auth.Key
+	modTime time.Time
+	sync.Mutex
+}
+
+// NewRepository of the keys in the file at path, which is created when the first key is
+func NewRepository(path string) (auth.Repository, error) {
+
+	if path == "" {
+		return nil, errors.New("no path provided")
+	}
+
+	repo := &Repository{path: path, keys: map[string]*auth.Key{}}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if err := repo.reload(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// reload keys if the file changed since they were read
+func (repo *Repository) reload() error {
+
+	info, err := os.Stat(repo.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// a removed file revokes every key, until one is created again
+		repo.keys, repo.modTime = map[string]*auth.Key{}, time.Time{}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to stat api keys file: %w", err)
+	case info.ModTime().Equal(repo.modTime):
+		return nil
+	}
+
+	b, err := os.ReadFile(repo.path)
+	if err != nil {
+		return fmt.Errorf("failed to read api keys file: %w", err)
+	}
+
+	var keys []*auth.Key
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return fmt.Errorf("failed to parse api keys file: %w", err)
+	}
+
+	repo.keys = make(map[string]*auth.Key, len(keys))
+	for _, k := range keys {
+		if k.ID == nil || k.Hash == nil {
+			return errors.New("api keys file has a key without id or hash")
+		}
+		repo.keys[*k.ID] = k
+	}
+
+	repo.modTime = info.ModTime()
+
+	return nil
+}
+
+// save keys by writing a new file and renaming it over the old one
+func (repo *Repository) save() error {
+
+	b, err := json.MarshalIndent(repo.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal api keys: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(repo.path), ".apikeys-*")
+	if err != nil {
+		return fmt.Errorf("failed to create api keys file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write api keys file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write api keys file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), repo.path); err != nil {
+		return fmt.Errorf("failed to replace api keys file: %w", err)
+	}
+
+	info, err := os.Stat(repo.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat api keys file: %w", err)
+	}
+
+	repo.modTime = info.ModTime()
+
+	return nil
+}
+
+func (repo *Repository) sorted() []*auth.Key {
+
+	result := make([]*auth.Key, 0, len(repo.keys))
+
+	for _, k := range repo.keys {
+		result = append(result, copyKey(k))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(*result[j].CreatedAt) })
+
+	return result
+}
+
+func (repo *Repository) List(ctx context.Context) ([]*auth.Key, error) {
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if err := repo.reload(); err != nil {
+		return nil, err
+	}
+
+	return repo.sorted(), nil
+}
+
+func (repo *Repository) Get(ctx context.Context, id *string) (*auth.Key, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if err := repo.reload(); err != nil {
+		return nil, err
+	}
+
+	k, ok := repo.keys[*id]
+	if !ok {
+		return nil, auth.ErrNotFound
+	}
+
+	return copyKey(k), nil
+}
+
+func (repo *Repository) Create(ctx context.Context, k *auth.Key) (*auth.Key, error) {
+
+	if k == nil || k.ID == nil || k.Hash == nil {
+		return nil, errors.New("no api key with id and hash provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if err := repo.reload(); err != nil {
+		return nil, err
+	}
+
+	stored := copyKey(k)
+	stored.Key = nil
+	repo.keys[*k.ID] = stored
+
+	if err := repo.save(); err != nil {
+		delete(repo.keys, *k.ID)
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (repo *Repository) Delete(ctx context.Context, id *string) error {
+
+	if id == nil {
+		return errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if err := repo.reload(); err != nil {
+		return err
+	}
+
+	k, ok := repo.keys[*id]
+	if !ok {
+		return auth.ErrNotFound
+	}
+
+	delete(repo.keys, *id)
+
+	if err := repo.save(); err != nil {
+		repo.keys[*id] = k
+		return err
+	}
+
+	return nil
+}
+
+func copyKey(k *auth.Key) *auth.Key {
+	result := *k
+	return &result
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+)
+
+func newKey(id string) *auth.Key {
+	name, role, hash, now := "support", auth.RoleReader, auth.Hash(id), time.Now()
+	return &auth.Key{ID: &id, Name: &name, Role: &role, Hash: &hash, CreatedAt: &now}
+}
+
+func TestRepository(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	ctx := context.Background()
+
+	server, err := NewRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.Create(ctx, newKey("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected keys file to only be readable by its owner, got: %s", info.Mode())
+	}
+
+	// keys written by the cli are picked up by the server
+	cli, err := NewRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Create(ctx, newKey("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// make sure the change is noticed on file systems with coarse modification times
+	future := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	id := "b"
+	if _, err := server.Get(ctx, &id); err != nil {
+		t.Fatalf("expected key created by the cli to be found, got: %v", err)
+	}
+
+	if err := server.Delete(ctx, &id); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := cli.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || *keys[0].ID != "a" {
+		t.Fatalf("expected only key a to be left, got: %d keys", len(keys))
+	}
+
+	if _, err := cli.Get(ctx, &id); !errors.Is(err, auth.ErrNotFound) {
+		t.Fatalf("expected deleted key not to be found, got: %v", err)
+	}
+
+	// removing the file revokes the keys left
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := server.List(ctx); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no keys once the file is removed, got: %d, %v", len(keys), err)
+	}
+}
@@ -0,0 +1,90 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+)
+
+// Repository for in memory api keys
+type Repository struct {
+	keys map[string]*auth.Key
+	sync.Mutex
+}
+
+func NewRepository() (auth.Repository, error) {
+	return &Repository{keys: map[string]*auth.Key{}}, nil
+}
+
+func (repo *Repository) List(ctx context.Context) ([]*auth.Key, error) {
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	result := make([]*auth.Key, 0, len(repo.keys))
+
+	for _, k := range repo.keys {
+		result = append(result, copyKey(k))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(*result[j].CreatedAt) })
+
+	return result, nil
+}
+
+func (repo *Repository) Get(ctx context.Context, id *string) (*auth.Key, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	k, ok := repo.keys[*id]
+	if !ok {
+		return nil, auth.ErrNotFound
+	}
+
+	return copyKey(k), nil
+}
+
+func (repo *Repository) Create(ctx context.Context, k *auth.Key) (*auth.Key, error) {
+
+	if k == nil || k.ID == nil || k.Hash == nil {
+		return nil, errors.New("no api key with id and hash provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	repo.keys[*k.ID] = copyKey(k)
+
+	return k, nil
+}
+
+func (repo *Repository) Delete(ctx context.Context, id *string) error {
+
+	if id == nil {
+		return errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if _, ok := repo.keys[*id]; !ok {
+		return auth.ErrNotFound
+	}
+
+	delete(repo.keys, *id)
+
+	return nil
+}
+
+func copyKey(k *auth.Key) *auth.Key {
+	result := *k
+	return &result
+}
@@ -0,0 +1,188 @@
+// Package scoped limits principals scoped to some msisdns to their subscriptions, wherever
+// subscriptions are read or changed. Subscriptions outside the scope are reported as not found.
+package scoped
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// check returns subscription.ErrNotFound if the principal of ctx may not see msisdn
+func check(ctx context.Context, msisdn *string) error {
+
+	if msisdn != nil && !auth.PrincipalFrom(ctx).Sees(*msisdn) {
+		return fmt.Errorf("%s is not within the scope of the caller: %w", *msisdn, subscription.ErrNotFound)
+	}
+
+	return nil
+}
+
+// restrict a copy of q to the msisdns the principal of ctx may see
+func restrict(ctx context.Context, q *subscription.Query) *subscription.Query {
+
+	p := auth.PrincipalFrom(ctx)
+	if !p.Scoped() {
+		return q
+	}
+
+	result := subscription.Query{}
+	if q != nil {
+		result = *q
+	}
+
+	msisdns := []string{}
+	for _, msisdn := range p.MSISDNs {
+		if result.MSISDNs == nil || contains(result.MSISDNs, msisdn) {
+			msisdns = append(msisdns, msisdn)
+		}
+	}
+	result.MSISDNs = msisdns
+
+	return &result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository of subscriptions limited to the scope of the caller
+type Repository struct {
+	next subscription.Repository
+}
+
+func NewRepository(next subscription.Repository) subscription.Repository {
+	return &Repository{next: next}
+}
+
+func (repo *Repository) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+	return repo.next.List(ctx, restrict(ctx, q))
+}
+
+func (repo *Repository) Get(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return repo.next.Get(ctx, msisdn)
+}
+
+// Create subscription, creating one outside the scope of the caller is forbidden
+func (repo *Repository) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	if m != nil && m.MSISDN != nil && !auth.PrincipalFrom(ctx).Sees(*m.MSISDN) {
+		return nil, fmt.Errorf("%s is not within the scope of the caller: %w", *m.MSISDN, auth.ErrForbidden)
+	}
+
+	return repo.next.Create(ctx, m)
+}
+
+func (repo *Repository) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	if m != nil {
+		if err := check(ctx, m.MSISDN); err != nil {
+			return nil, err
+		}
+	}
+
+	return repo.next.Update(ctx, m)
+}
+
+func (repo *Repository) Activate(ctx context.Context, msisdn *string, now time.Time) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return repo.next.Activate(ctx, msisdn, now)
+}
+
+func (repo *Repository) TogglePaused(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return repo.next.TogglePaused(ctx, msisdn, expected)
+}
+
+func (repo *Repository) Cancel(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return repo.next.Cancel(ctx, msisdn, expected)
+}
+
+// History of subscriptions limited to the scope of the caller
+type History struct {
+	next subscription.HistoryReader
+}
+
+func NewHistory(next subscription.HistoryReader) subscription.HistoryReader {
+	return &History{next: next}
+}
+
+func (h *History) History(ctx context.Context, msisdn *string, cursor *string, limit int) (*subscription.HistoryPage, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return h.next.History(ctx, msisdn, cursor, limit)
+}
+
+// OperatorRefresher of subscriptions limited to the scope of the caller
+type OperatorRefresher struct {
+	next subscription.OperatorRefresher
+}
+
+func NewOperatorRefresher(next subscription.OperatorRefresher) subscription.OperatorRefresher {
+	return &OperatorRefresher{next: next}
+}
+
+func (r *OperatorRefresher) RefreshOperator(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return r.next.RefreshOperator(ctx, msisdn)
+}
+
+// Pauser of subscriptions limited to the scope of the caller
+type Pauser struct {
+	next subscription.Pauser
+}
+
+func NewPauser(next subscription.Pauser) subscription.Pauser {
+	return &Pauser{next: next}
+}
+
+func (p *Pauser) Pause(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return p.next.Pause(ctx, msisdn, expected)
+}
+
+func (p *Pauser) Resume(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if err := check(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	return p.next.Resume(ctx, msisdn, expected)
+}
@@ -0,0 +1,103 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock used by background workers so they can be driven deterministically in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+// New clock backed by the time package
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// Fake clock that only moves when told to
+type Fake struct {
+	now     time.Time
+	waiters []*waiter
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewFake clock starting at now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &waiter{at: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- f.now
+		return w.c
+	}
+
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+
+	return w.c
+}
+
+// Advance clock by d, firing every channel returned by After that is due
+func (f *Fake) Advance(d time.Duration) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- f.now
+	}
+	f.waiters = pending
+}
+
+// BlockUntil n callers are waiting on channels returned by After
+func (f *Fake) BlockUntil(n int) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
@@ -2,64 +2,428 @@ package config
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// FileEnv naming the config file, unless given with the -config flag
+const FileEnv = "CONFIG_FILE"
+
+// FileSuffix of environment variables naming a file to read the value of a setting from,
+// so secrets can be mounted as files instead of being put in the environment
+const FileSuffix = "_FILE"
+
+// Redacted replaces the values of secrets when printed
+const Redacted = "[redacted]"
+
+// Source a setting got its effective value from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Value of a setting and where it came from
+type Value struct {
+	Name   string
+	Value  string
+	Source Source
+}
+
 type Config struct {
-	Port          string
-	PTSURL        string
-	ClientTimeout time.Duration
-	IdleTimeout   time.Duration
-	ReadTimeout   time.Duration
-	WriteTimeout  time.Duration
+	Port                     string
+	GRPCPort                 string
+	AdminPort                string
+	PTSURL                   string
+	ClientTimeout            time.Duration
+	IdleTimeout              time.Duration
+	ReadTimeout              time.Duration
+	WriteTimeout             time.Duration
+	Database                 string
+	DatabaseDSN              string
+	OperatorCacheTTL         time.Duration
+	OperatorCacheNegativeTTL time.Duration
+	OperatorRefreshInterval  time.Duration
+	OperatorRefreshMaxAge    time.Duration
+	OperatorRefreshPace      time.Duration
+	OperatorSource           string
+	NumberPlanDir            string
+	NumberPlanFallback       bool
+	NumberPlanReloadInterval time.Duration
+	PTSRetries               int
+	PTSBackoffBase           time.Duration
+	PTSBackoffMax            time.Duration
+	PTSBreakerThreshold      int
+	PTSBreakerCooldown       time.Duration
+	PTSRateLimit             float64
+	PTSRateBurst             int
+	WebhookMaxAttempts       int
+	WebhookBackoffBase       time.Duration
+	WebhookBackoffMax        time.Duration
+	WebhookTimeout           time.Duration
+	ShutdownTimeout          time.Duration
+	HealthCheckTimeout       time.Duration
+	HealthCacheTTL           time.Duration
+	IdempotencyTTL           time.Duration
+	OpenAPIValidateResponses bool
+	GraphiQL                 bool
+	AuthDisabled             bool
+	APIKeysFile              string
+	OIDCIssuer               string
+	OIDCAudience             string
+	OIDCJWKSURL              string
+	OIDCJWKSFile             string
+	OIDCJWKSRefresh          time.Duration
+	OIDCLeeway               time.Duration
+	OIDCMSISDNClaim          string
+	LogLevel                 slog.Level
+	// values of every setting in the order they are printed
+	values []Value
+}
+
+// Load config from built-in defaults, the config file, the environment and the flags in args,
+// each overriding the ones before. A .env file is loaded into the environment if present.
+func Load(args []string) (*Config, error) {
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	return load(args, os.LookupEnv)
 }
 
+// NewFromEnv loads config without flags, from the env files given, which need to exist, and the environment
 func NewFromEnv(filenames ...string) (*Config, error) {
 
-	if err := godotenv.Load(filenames...); err != nil {
-		return nil, fmt.Errorf("failed to get env variables: %w", err)
+	if len(filenames) > 0 {
+		if err := godotenv.Load(filenames...); err != nil {
+			return nil, fmt.Errorf("failed to get env variables: %w", err)
+		}
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		return nil, errors.New("no PORT env variable set")
+	return load(nil, os.LookupEnv)
+}
+
+// Usage of the flags accepted by Load
+func Usage(w io.Writer) {
+	flags, _, _ := newFlagSet()
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+}
+
+func newFlagSet() (*flag.FlagSet, *string, map[string]*string) {
+
+	flags := flag.NewFlagSet("subscription-api", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	file := flags.String("config", "", "yaml or toml config file, also given with "+FileEnv)
+
+	values := map[string]*string{}
+	for _, s := range settings {
+		values[s.name] = flags.String(s.flag(), "", fmt.Sprintf("%s (%s, default %q)", s.usage, s.name, s.def))
 	}
 
-	ptsurl := os.Getenv("PTS_URL")
-	if ptsurl == "" {
-		return nil, errors.New("no PTS_URL env variable set")
+	return flags, file, values
+}
+
+// load layers, lookupEnv is replaced in tests
+func load(args []string, lookupEnv func(name string) (string, bool)) (*Config, error) {
+
+	flags, file, flagValues := newFlagSet()
+	if err := flags.Parse(args); err != nil {
+		return nil, err
 	}
 
-	client, err := time.ParseDuration(os.Getenv("TIMEOUT_CLIENT"))
+	values := map[string]Value{}
+	for _, s := range settings {
+		values[s.name] = Value{Name: s.name, Value: s.def, Source: SourceDefault}
+	}
+
+	var errs []error
+
+	path := *file
+	if path == "" {
+		path, _ = lookupEnv(FileEnv)
+	}
+
+	if path != "" {
+		fileValues, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range settings {
+			if v, ok := fileValues[s.key()]; ok {
+				values[s.name] = Value{Name: s.name, Value: v, Source: SourceFile}
+				delete(fileValues, s.key())
+			}
+		}
+		for key := range fileValues {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", path, key))
+		}
+	}
+
+	for _, s := range settings {
+
+		v, _ := lookupEnv(s.name)
+		secretFile, _ := lookupEnv(s.name + FileSuffix)
+
+		switch {
+		case v != "" && secretFile != "":
+			errs = append(errs, fmt.Errorf("%s: both %s and %s%s env variables set", s.name, s.name, s.name, FileSuffix))
+		case secretFile != "":
+			data, err := os.ReadFile(secretFile)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: failed to read %s%s: %w", s.name, s.name, FileSuffix, err))
+				continue
+			}
+			values[s.name] = Value{Name: s.name, Value: strings.TrimRight(string(data), "\r\n"), Source: SourceEnv}
+		case v != "":
+			values[s.name] = Value{Name: s.name, Value: v, Source: SourceEnv}
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if f.Name == s.flag() {
+				values[s.name] = Value{Name: s.name, Value: *flagValues[s.name], Source: SourceFlag}
+			}
+		}
+	})
+
+	p := &parser{values: values, errs: errs}
+	cfg := p.config()
+
+	for _, s := range settings {
+		cfg.values = append(cfg.values, values[s.name])
+	}
+
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// readFile of settings keyed by their lower case names, yaml or toml by extension
+func readFile(path string) (map[string]string, error) {
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TIMEOUT_CLIENT env variable to time.Duration: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	idle, err := time.ParseDuration(os.Getenv("TIMEOUT_IDLE"))
+	raw := map[string]interface{}{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file needs to be .yaml, .yml or .toml, got: %s", path)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TIMEOUT_IDLE env variable to time.Duration: %w", err)
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	result := map[string]string{}
+	for key, v := range raw {
+		switch v := v.(type) {
+		case string:
+			result[strings.ToLower(key)] = v
+		case bool, int, int64, uint64, float64:
+			result[strings.ToLower(key)] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("config file %s: %s needs to be a string, number or bool", path, key)
+		}
 	}
 
-	read, err := time.ParseDuration(os.Getenv("TIMEOUT_READ"))
+	return result, nil
+}
+
+// Values of every setting with secrets redacted
+func (cfg *Config) Values() []Value {
+
+	result := make([]Value, 0, len(cfg.values))
+	for i, v := range cfg.values {
+		if settings[i].secret && v.Value != "" {
+			v.Value = Redacted
+		}
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// Print the effective config with secrets redacted, one NAME=value per line
+func (cfg *Config) Print(w io.Writer) {
+	for _, v := range cfg.Values() {
+		fmt.Fprintf(w, "%s=%s # %s\n", v.Name, v.Value, v.Source)
+	}
+}
+
+// parser of values into a config, collecting every error instead of stopping at the first
+type parser struct {
+	values map[string]Value
+	errs   []error
+}
+
+func (p *parser) fail(name, kind string, err error) {
+	v := p.values[name]
+	p.errs = append(p.errs, fmt.Errorf("%s: failed to parse %q from %s to %s: %w", name, v.Value, v.Source, kind, err))
+}
+
+func (p *parser) string(name string) string {
+	return p.values[name].Value
+}
+
+func (p *parser) addr(host, name string) string {
+	if port, err := strconv.ParseUint(p.string(name), 10, 16); err != nil || port == 0 {
+		p.fail(name, "port", errors.New("needs to be between 1 and 65535"))
+	}
+	return net.JoinHostPort(host, p.string(name))
+}
+
+func (p *parser) duration(name string) time.Duration {
+	d, err := time.ParseDuration(p.string(name))
+	if err != nil {
+		p.fail(name, "time.Duration", err)
+	}
+	return d
+}
+
+func (p *parser) int(name string) int {
+	i, err := strconv.Atoi(p.string(name))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TIMEOUT_READ env variable to time.Duration: %w", err)
+		p.fail(name, "int", err)
 	}
+	return i
+}
+
+func (p *parser) float(name string) float64 {
+	f, err := strconv.ParseFloat(p.string(name), 64)
+	if err != nil {
+		p.fail(name, "float", err)
+	}
+	return f
+}
 
-	write, err := time.ParseDuration(os.Getenv("TIMEOUT_WRITE"))
+func (p *parser) bool(name string) bool {
+	b, err := strconv.ParseBool(p.string(name))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse TIMEOUT_WRITE env variable to time.Duration: %w", err)
+		p.fail(name, "bool", err)
+	}
+	return b
+}
+
+func (p *parser) level(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(p.string(name))); err != nil {
+		p.fail(name, "log level", err)
+	}
+	return level
+}
+
+func (p *parser) require(ok bool, msg string) {
+	if !ok {
+		p.errs = append(p.errs, errors.New(msg))
+	}
+}
+
+func (p *parser) config() *Config {
+
+	host := p.string("HOST")
+
+	cfg := &Config{
+		Port:                     p.addr(host, "PORT"),
+		GRPCPort:                 p.addr(host, "GRPC_PORT"),
+		AdminPort:                p.addr(host, "ADMIN_PORT"),
+		PTSURL:                   p.string("PTS_URL"),
+		ClientTimeout:            p.duration("TIMEOUT_CLIENT"),
+		IdleTimeout:              p.duration("TIMEOUT_IDLE"),
+		ReadTimeout:              p.duration("TIMEOUT_READ"),
+		WriteTimeout:             p.duration("TIMEOUT_WRITE"),
+		Database:                 p.string("DATABASE"),
+		DatabaseDSN:              p.string("DATABASE_DSN"),
+		OperatorCacheTTL:         p.duration("OPERATOR_CACHE_TTL"),
+		OperatorCacheNegativeTTL: p.duration("OPERATOR_CACHE_NEGATIVE_TTL"),
+		OperatorRefreshInterval:  p.duration("OPERATOR_REFRESH_INTERVAL"),
+		OperatorRefreshMaxAge:    p.duration("OPERATOR_REFRESH_MAX_AGE"),
+		OperatorRefreshPace:      p.duration("OPERATOR_REFRESH_PACE"),
+		OperatorSource:           p.string("OPERATOR_SOURCE"),
+		NumberPlanDir:            p.string("NUMBER_PLAN_DIR"),
+		NumberPlanFallback:       p.bool("NUMBER_PLAN_FALLBACK"),
+		NumberPlanReloadInterval: p.duration("NUMBER_PLAN_RELOAD_INTERVAL"),
+		PTSRetries:               p.int("PTS_RETRIES"),
+		PTSBackoffBase:           p.duration("PTS_BACKOFF_BASE"),
+		PTSBackoffMax:            p.duration("PTS_BACKOFF_MAX"),
+		PTSBreakerThreshold:      p.int("PTS_BREAKER_THRESHOLD"),
+		PTSBreakerCooldown:       p.duration("PTS_BREAKER_COOLDOWN"),
+		PTSRateLimit:             p.float("PTS_RATE_LIMIT"),
+		PTSRateBurst:             p.int("PTS_RATE_BURST"),
+		WebhookMaxAttempts:       p.int("WEBHOOK_MAX_ATTEMPTS"),
+		WebhookBackoffBase:       p.duration("WEBHOOK_BACKOFF_BASE"),
+		WebhookBackoffMax:        p.duration("WEBHOOK_BACKOFF_MAX"),
+		WebhookTimeout:           p.duration("WEBHOOK_TIMEOUT"),
+		ShutdownTimeout:          p.duration("SHUTDOWN_TIMEOUT"),
+		HealthCheckTimeout:       p.duration("HEALTH_CHECK_TIMEOUT"),
+		HealthCacheTTL:           p.duration("HEALTH_CACHE_TTL"),
+		IdempotencyTTL:           p.duration("IDEMPOTENCY_TTL"),
+		OpenAPIValidateResponses: p.bool("OPENAPI_VALIDATE_RESPONSES"),
+		GraphiQL:                 p.bool("GRAPHIQL"),
+		AuthDisabled:             p.bool("AUTH_DISABLED"),
+		APIKeysFile:              p.string("API_KEYS_FILE"),
+		OIDCIssuer:               p.string("OIDC_ISSUER"),
+		OIDCAudience:             p.string("OIDC_AUDIENCE"),
+		OIDCJWKSURL:              p.string("OIDC_JWKS_URL"),
+		OIDCJWKSFile:             p.string("OIDC_JWKS_FILE"),
+		OIDCJWKSRefresh:          p.duration("OIDC_JWKS_REFRESH"),
+		OIDCLeeway:               p.duration("OIDC_LEEWAY"),
+		OIDCMSISDNClaim:          p.string("OIDC_MSISDN_CLAIM"),
+		LogLevel:                 p.level("LOG_LEVEL"),
+	}
+
+	switch cfg.OperatorSource {
+	case "pts":
+		p.require(cfg.PTSURL != "", "PTS_URL: no pts url set")
+		p.require(!cfg.NumberPlanFallback || cfg.NumberPlanDir != "", "NUMBER_PLAN_DIR: no number plan directory set for NUMBER_PLAN_FALLBACK")
+	case "numberplan":
+		p.require(cfg.NumberPlanDir != "", "NUMBER_PLAN_DIR: no number plan directory set for OPERATOR_SOURCE numberplan")
+	default:
+		p.require(false, fmt.Sprintf("OPERATOR_SOURCE: unknown operator source %q, needs to be pts or numberplan", cfg.OperatorSource))
+	}
+
+	switch cfg.Database {
+	case "memory":
+	case "sqlite", "postgres":
+		p.require(cfg.DatabaseDSN != "", fmt.Sprintf("DATABASE_DSN: no data source name set for DATABASE %s", cfg.Database))
+	default:
+		p.require(false, fmt.Sprintf("DATABASE: unknown database %q, needs to be memory, sqlite or postgres", cfg.Database))
+	}
+
+	p.require(cfg.AuthDisabled || cfg.APIKeysFile != "" || cfg.OIDCIssuer != "",
+		"API_KEYS_FILE: no api keys file or OIDC_ISSUER set, set AUTH_DISABLED=true to run without authentication")
+
+	if cfg.OIDCIssuer != "" {
+		p.require(cfg.OIDCAudience != "", "OIDC_AUDIENCE: no audience set for OIDC_ISSUER")
+		p.require((cfg.OIDCJWKSURL == "") != (cfg.OIDCJWKSFile == ""), "OIDC_JWKS_URL: either OIDC_JWKS_URL or OIDC_JWKS_FILE needs to be set for OIDC_ISSUER")
 	}
 
-	return &Config{
-		Port:          fmt.Sprintf("0.0.0.0:%s", port),
-		PTSURL:        ptsurl,
-		ClientTimeout: client,
-		IdleTimeout:   idle,
-		ReadTimeout:   read,
-		WriteTimeout:  write,
-	}, nil
+	return cfg
 }
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(file, []byte("port: 4000\ngrpc_port: 4001\nadmin_port: 4002\ntimeout_client: 2s\nauth_disabled: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		FileEnv:      file,
+		"GRPC_PORT":  "5001",
+		"ADMIN_PORT": "5002",
+		"HOST":       "",
+	}
+
+	cfg, err := load([]string{"-admin-port", "6002"}, lookup(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != "0.0.0.0:4000" || cfg.GRPCPort != "0.0.0.0:5001" || cfg.AdminPort != "0.0.0.0:6002" {
+		t.Fatalf("unexpected addresses: %s %s %s", cfg.Port, cfg.GRPCPort, cfg.AdminPort)
+	}
+
+	if cfg.ClientTimeout != 2*time.Second || cfg.ReadTimeout != 5*time.Second || !cfg.AuthDisabled {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	sources := map[string]Source{}
+	for _, v := range cfg.Values() {
+		sources[v.Name] = v.Source
+	}
+
+	expected := map[string]Source{"HOST": SourceDefault, "PORT": SourceFile, "GRPC_PORT": SourceEnv, "ADMIN_PORT": SourceFlag}
+	for name, source := range expected {
+		if sources[name] != source {
+			t.Errorf("expected %s from %s, got: %s", name, source, sources[name])
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(file, []byte("database = \"sqlite\"\ndatabase_dsn = \"subscriptions.db\"\npts_retries = 4\nauth_disabled = true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load([]string{"-config", file}, lookup(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database != "sqlite" || cfg.DatabaseDSN != "subscriptions.db" || cfg.PTSRetries != 4 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+
+	secret := filepath.Join(t.TempDir(), "dsn")
+	if err := os.WriteFile(secret, []byte("postgres://user:secret@db/subscriptions\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"DATABASE":          "postgres",
+		"DATABASE_DSN_FILE": secret,
+		"AUTH_DISABLED":     "true",
+	}
+
+	cfg, err := load(nil, lookup(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.DatabaseDSN != "postgres://user:secret@db/subscriptions" {
+		t.Fatalf("unexpected dsn: %q", cfg.DatabaseDSN)
+	}
+
+	var out bytes.Buffer
+	cfg.Print(&out)
+
+	if strings.Contains(out.String(), "secret") || !strings.Contains(out.String(), "DATABASE_DSN="+Redacted+" # env\n") {
+		t.Fatalf("expected dsn to be redacted, got:\n%s", out.String())
+	}
+
+	env["DATABASE_DSN"] = "postgres://db/subscriptions"
+	if _, err := load(nil, lookup(env)); err == nil || !strings.Contains(err.Error(), "both DATABASE_DSN and DATABASE_DSN_FILE") {
+		t.Fatalf("expected error for both DATABASE_DSN and DATABASE_DSN_FILE, got: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("prot: 3000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		FileEnv:           file,
+		"TIMEOUT_READ":    "soon",
+		"PTS_RETRIES":     "many",
+		"PORT":            "http",
+		"DATABASE":        "sqlite",
+		"OIDC_ISSUER":     "https://issuer.example.com",
+		"LOG_LEVEL":       "loud",
+		"OPERATOR_SOURCE": "numberplan",
+	}
+
+	_, err := load(nil, lookup(env))
+	if err == nil {
+		t.Fatal("expected invalid config")
+	}
+
+	for _, expected := range []string{"unknown setting prot", "TIMEOUT_READ", "PTS_RETRIES", "PORT", "DATABASE_DSN", "OIDC_AUDIENCE", "OIDC_JWKS_URL", "LOG_LEVEL", "NUMBER_PLAN_DIR"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to mention %s, got:\n%v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import "strings"
+
+// setting of the config, named by its environment variable. In a config file it is keyed by
+// the lower case name, pts_url, and as a flag by the lower case name with dashes, -pts-url.
+type setting struct {
+	name   string
+	def    string
+	usage  string
+	secret bool
+}
+
+func (s setting) key() string {
+	return strings.ToLower(s.name)
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(s.key(), "_", "-")
+}
+
+// settings in the order they are printed
+var settings = []setting{
+	{name: "HOST", def: "0.0.0.0", usage: "host the listeners bind to"},
+	{name: "PORT", def: "3000", usage: "port of the http api"},
+	{name: "GRPC_PORT", def: "9090", usage: "port of the grpc api"},
+	{name: "ADMIN_PORT", def: "9091", usage: "port of the admin listener serving metrics"},
+	{name: "PTS_URL", def: "http://api.pts.se/PTSNumberService/Pts_Number_Service.svc/json/SearchByNumber", usage: "url of the pts number search"},
+	{name: "TIMEOUT_CLIENT", def: "5s", usage: "timeout of calls to pts and other apis"},
+	{name: "TIMEOUT_IDLE", def: "5s", usage: "idle timeout of http connections"},
+	{name: "TIMEOUT_READ", def: "5s", usage: "read timeout of http requests"},
+	{name: "TIMEOUT_WRITE", def: "5s", usage: "write timeout of http responses"},
+	{name: "DATABASE", def: "memory", usage: "storage of subscriptions: memory, sqlite or postgres"},
+	{name: "DATABASE_DSN", usage: "data source name of the database", secret: true},
+	{name: "OPERATOR_CACHE_TTL", def: "1h", usage: "time operator names are cached, 0 disables the cache"},
+	{name: "OPERATOR_CACHE_NEGATIVE_TTL", def: "5m", usage: "time numbers without operator are cached"},
+	{name: "OPERATOR_REFRESH_INTERVAL", def: "1h", usage: "interval stored operators are refreshed at, 0 disables the refresher"},
+	{name: "OPERATOR_REFRESH_MAX_AGE", def: "24h", usage: "age at which a stored operator is verified again"},
+	{name: "OPERATOR_REFRESH_PACE", def: "100ms", usage: "time between the operator lookups of a refresh, so it does not use up the pts rate limit"},
+	{name: "OPERATOR_SOURCE", def: "pts", usage: "source of operators: pts or numberplan"},
+	{name: "NUMBER_PLAN_DIR", usage: "directory number plans are imported to"},
+	{name: "NUMBER_PLAN_FALLBACK", def: "false", usage: "look up operators in the number plan while pts is unavailable"},
+	{name: "NUMBER_PLAN_RELOAD_INTERVAL", def: "1m", usage: "interval new number plans are picked up at, 0 disables reloading"},
+	{name: "PTS_RETRIES", def: "2", usage: "retries of transient pts failures"},
+	{name: "PTS_BACKOFF_BASE", def: "100ms", usage: "base delay between pts retries"},
+	{name: "PTS_BACKOFF_MAX", def: "2s", usage: "max delay between pts retries"},
+	{name: "PTS_BREAKER_THRESHOLD", def: "5", usage: "consecutive pts failures opening the breaker, 0 disables it"},
+	{name: "PTS_BREAKER_COOLDOWN", def: "30s", usage: "time the breaker stays open"},
+	{name: "PTS_RATE_LIMIT", def: "0", usage: "pts calls per second, 0 is unlimited"},
+	{name: "PTS_RATE_BURST", def: "1", usage: "pts calls allowed in a burst"},
+	{name: "WEBHOOK_MAX_ATTEMPTS", def: "8", usage: "attempts of a webhook delivery"},
+	{name: "WEBHOOK_BACKOFF_BASE", def: "1s", usage: "base delay between webhook attempts"},
+	{name: "WEBHOOK_BACKOFF_MAX", def: "1h", usage: "max delay between webhook attempts"},
+	{name: "WEBHOOK_TIMEOUT", def: "5s", usage: "timeout of a webhook attempt"},
+	{name: "SHUTDOWN_TIMEOUT", def: "20s", usage: "time in flight requests get to finish on shutdown"},
+	{name: "HEALTH_CHECK_TIMEOUT", def: "2s", usage: "timeout of each readiness check"},
+	{name: "HEALTH_CACHE_TTL", def: "5s", usage: "time readiness check results are cached"},
+	{name: "IDEMPOTENCY_TTL", def: "24h", usage: "time responses are kept for idempotency keys"},
+	{name: "OPENAPI_VALIDATE_RESPONSES", def: "false", usage: "validate responses against the openapi spec"},
+	{name: "GRAPHIQL", def: "false", usage: "serve the graphiql page"},
+	{name: "AUTH_DISABLED", def: "false", usage: "run without authentication, every request is made by an admin"},
+	{name: "API_KEYS_FILE", usage: "file api keys are stored in"},
+	{name: "OIDC_ISSUER", usage: "issuer of bearer tokens, enables them"},
+	{name: "OIDC_AUDIENCE", usage: "audience bearer tokens need to be issued for"},
+	{name: "OIDC_JWKS_URL", usage: "url of the keys bearer tokens are signed with"},
+	{name: "OIDC_JWKS_FILE", usage: "file of the keys bearer tokens are signed with"},
+	{name: "OIDC_JWKS_REFRESH", def: "1h", usage: "interval the keys of bearer tokens are reloaded at"},
+	{name: "OIDC_LEEWAY", def: "30s", usage: "clock skew allowed when checking bearer tokens"},
+	{name: "OIDC_MSISDN_CLAIM", def: "msisdns", usage: "claim listing the msisdns of a bearer token"},
+	{name: "LOG_LEVEL", def: "info", usage: "level of logs: debug, info, warn or error"},
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+// Status of a check, or of readiness as a whole
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailing Status = "failing"
+)
+
+// DefaultTimeout of a check
+const DefaultTimeout = 2 * time.Second
+
+// DefaultCacheTTL of check results, so frequent probes do not hammer dependencies
+const DefaultCacheTTL = 5 * time.Second
+
+// Check of a dependency, returning an error if it is not usable
+type Check func(ctx context.Context) error
+
+// Result of a check
+type Result struct {
+	Status     Status    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Report of readiness, failing if any check fails or the server is shutting down
+type Report struct {
+	Status       Status            `json:"status"`
+	ShuttingDown bool              `json:"shutting_down,omitempty"`
+	Checks       map[string]Result `json:"checks"`
+}
+
+// OK reports if the server is ready
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      Check
+	// mu serializes runs of the check, probes arriving while it runs get its result
+	mu     sync.Mutex
+	result *Result
+}
+
+// Checker of readiness running registered checks
+type Checker struct {
+	ttl      time.Duration
+	clock    clock.Clock
+	checks   []*check
+	draining atomic.Bool
+	mu       sync.Mutex
+}
+
+// New checker caching results for ttl
+func New(ttl time.Duration, clk clock.Clock) *Checker {
+	return &Checker{ttl: ttl, clock: clk}
+}
+
+// Register check named name, failing it if it takes longer than timeout
+func (c *Checker) Register(name string, timeout time.Duration, fn Check) {
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, &check{name: name, timeout: timeout, fn: fn})
+	sort.Slice(c.checks, func(i, j int) bool { return c.checks[i].name < c.checks[j].name })
+}
+
+// Drain fails readiness from now on, so no new traffic is sent while the server shuts down
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check readiness, running checks concurrently unless their cached result is still fresh.
+// Checks are not run once draining, dependencies may already be closed.
+func (c *Checker) Check(ctx context.Context) *Report {
+
+	if c.draining.Load() {
+		return &Report{Status: StatusFailing, ShuttingDown: true, Checks: map[string]Result{}}
+	}
+
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, ch := range checks {
+		report.Checks[ch.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+
+	return report
+}
+
+// run ch if its cached result is stale
+func (c *Checker) run(ctx context.Context, ch *check) Result {
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.result != nil && c.clock.Now().Sub(ch.result.CheckedAt) < c.ttl {
+		return *ch.result
+	}
+
+	start := c.clock.Now()
+
+	checkCtx, cancel := context.WithTimeout(ctx, ch.timeout)
+	defer cancel()
+
+	// a check ignoring its context still fails once it times out
+	errc := make(chan error, 1)
+	go func() { errc <- ch.fn(checkCtx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-checkCtx.Done():
+		err = fmt.Errorf("check did not finish within %s", ch.timeout)
+	}
+
+	result := Result{Status: StatusOK, CheckedAt: start, DurationMS: c.clock.Now().Sub(start).Milliseconds()}
+	if err != nil {
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+
+	// the probe going away says nothing about the dependency
+	if ctx.Err() == nil {
+		ch.result = &result
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+func TestChecker(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+	checker := New(5*time.Second, clk)
+
+	var calls atomic.Int32
+	var failing atomic.Bool
+
+	checker.Register("storage", time.Second, func(ctx context.Context) error {
+		calls.Add(1)
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	checker.Register("scheduler", 10*time.Millisecond, func(ctx context.Context) error {
+		// ignores its context, the checker still gives up on it
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	report := checker.Check(ctx)
+	if report.OK() || report.Checks["storage"].Status != StatusOK || report.Checks["scheduler"].Status != StatusFailing {
+		t.Fatalf("expected only scheduler to fail, got: %+v", report)
+	}
+
+	// cached until the ttl has passed
+	failing.Store(true)
+	if report := checker.Check(ctx); report.Checks["storage"].Status != StatusOK || calls.Load() != 1 {
+		t.Fatalf("expected cached storage result, got: %+v after %d calls", report.Checks["storage"], calls.Load())
+	}
+
+	clk.Advance(5 * time.Second)
+
+	report = checker.Check(ctx)
+	if result := report.Checks["storage"]; result.Status != StatusFailing || result.Error != "connection refused" || calls.Load() != 2 {
+		t.Fatalf("expected storage to be checked again and fail, got: %+v after %d calls", result, calls.Load())
+	}
+
+	checker.Drain()
+
+	if report := checker.Check(ctx); report.OK() || !report.ShuttingDown || calls.Load() != 2 {
+		t.Fatalf("expected failing readiness without checks while draining, got: %+v", report)
+	}
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// ErrFingerprintMismatch returned if a key is reused for a request different from the first one
+var ErrFingerprintMismatch = errors.New("idempotency key already used for a different request")
+
+// Response stored for a key and replayed for requests repeating it
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Copy of the response so callers can not modify a stored one
+func (resp *Response) Copy() *Response {
+	return &Response{
+		Status: resp.Status,
+		Header: resp.Header.Clone(),
+		Body:   append([]byte(nil), resp.Body...),
+	}
+}
+
+// Store of responses by idempotency key
+type Store interface {
+	// Begin processing the request with key, returning the stored response if one was completed
+	// and nil if the caller should process it. Waits while a request with the same key is in progress.
+	Begin(ctx context.Context, key, fingerprint string) (*Response, error)
+	// Complete the request with key by storing resp, a nil resp releases the key so it can be retried
+	Complete(ctx context.Context, key string, resp *Response) error
+}
+
+// Fingerprint of a request, requests reusing a key need to have the same fingerprint
+func Fingerprint(method, path string, body []byte) string {
+
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
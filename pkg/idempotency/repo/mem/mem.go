@@ -0,0 +1,124 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/idempotency"
+)
+
+// purgeEvery number of keys begun between purges of expired responses
+const purgeEvery = 1024
+
+type entry struct {
+	fingerprint string
+	resp        *idempotency.Response
+	expires     time.Time
+	// done is closed when the request in progress completes
+	done chan struct{}
+}
+
+// Store for in memory idempotency keys
+type Store struct {
+	ttl     time.Duration
+	clock   clock.Clock
+	entries map[string]*entry
+	begun   int
+	sync.Mutex
+}
+
+// NewStore keeping completed responses for ttl
+func NewStore(ttl time.Duration, clk clock.Clock) (*Store, error) {
+
+	if ttl <= 0 {
+		return nil, errors.New("idempotency ttl needs to be positive")
+	}
+
+	return &Store{
+		ttl:     ttl,
+		clock:   clk,
+		entries: map[string]*entry{},
+	}, nil
+}
+
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*idempotency.Response, error) {
+
+	for {
+		s.Lock()
+
+		e, ok := s.entries[key]
+		if ok && e.resp != nil && !e.expires.After(s.clock.Now()) {
+			delete(s.entries, key)
+			ok = false
+		}
+
+		if !ok {
+			s.entries[key] = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.purge()
+			s.Unlock()
+			return nil, nil
+		}
+
+		if e.fingerprint != fingerprint {
+			s.Unlock()
+			return nil, idempotency.ErrFingerprintMismatch
+		}
+
+		if e.resp != nil {
+			resp := e.resp.Copy()
+			s.Unlock()
+			return resp, nil
+		}
+
+		done := e.done
+		s.Unlock()
+
+		// wait for the request in progress, then look again as it may have released the key
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Store) Complete(ctx context.Context, key string, resp *idempotency.Response) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.resp != nil {
+		return errors.New("no request in progress for idempotency key")
+	}
+
+	if resp == nil {
+		delete(s.entries, key)
+	} else {
+		e.resp = resp.Copy()
+		e.expires = s.clock.Now().Add(s.ttl)
+	}
+
+	close(e.done)
+
+	return nil
+}
+
+// purge expired responses every purgeEvery keys begun, expects the lock to be held
+func (s *Store) purge() {
+
+	s.begun++
+	if s.begun%purgeEvery != 0 {
+		return
+	}
+
+	now := s.clock.Now()
+	for key, e := range s.entries {
+		if e.resp != nil && !e.expires.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/idempotency"
+)
+
+func TestStore(t *testing.T) {
+
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	store, err := NewStore(time.Hour, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp, err := store.Begin(ctx, "key", "a"); err != nil || resp != nil {
+		t.Fatalf("expected to process first request, got: %v, %v", resp, err)
+	}
+
+	if _, err := store.Begin(ctx, "key", "b"); !errors.Is(err, idempotency.ErrFingerprintMismatch) {
+		t.Fatalf("expected fingerprint mismatch, got: %v", err)
+	}
+
+	// a duplicate waits for the first request to complete
+	replayed := make(chan *idempotency.Response)
+	go func() {
+		resp, err := store.Begin(ctx, "key", "a")
+		if err != nil {
+			t.Error(err)
+		}
+		replayed <- resp
+	}()
+
+	select {
+	case <-replayed:
+		t.Fatal("expected duplicate to wait for the request in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := store.Complete(ctx, "key", &idempotency.Response{Status: 201, Body: []byte("created")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := <-replayed; resp == nil || resp.Status != 201 || string(resp.Body) != "created" {
+		t.Fatalf("expected stored response replayed, got: %+v", resp)
+	}
+
+	clk.Advance(time.Hour)
+
+	if resp, err := store.Begin(ctx, "key", "b"); err != nil || resp != nil {
+		t.Fatalf("expected expired key to be processed again, got: %v, %v", resp, err)
+	}
+
+	// releasing the key lets it be retried
+	if err := store.Complete(ctx, "key", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp, err := store.Begin(ctx, "key", "c"); err != nil || resp != nil {
+		t.Fatalf("expected released key to be processed again, got: %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,142 @@
+// Package logging writes structured json logs with log/slog. Records are tagged with the request id
+// of their context and msisdns are masked, so logs can be shipped without leaking subscribers.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+)
+
+// RequestIDKey of the attribute carrying the request id of a record
+const RequestIDKey = "request_id"
+
+// MSISDNKey of attributes masked as a whole
+const MSISDNKey = "msisdn"
+
+// VisibleDigits of an msisdn left unmasked, enough to tell numbers apart while debugging
+const VisibleDigits = 3
+
+// msisdnPattern found in free text such as messages and errors, a prefix of up to four digits,
+// optionally separated by a dash or space, followed by at least five digits
+var msisdnPattern = regexp.MustCompile(`\+?\b\d{1,4}[- ]?\d{5,}\b`)
+
+// New logger writing json to w at level and above
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// ParseLevel of a log level name, debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+
+	return level, nil
+}
+
+// MaskMSISDN replacing all but the last VisibleDigits digits with *
+func MaskMSISDN(msisdn string) string {
+
+	visible := 0
+	masked := []rune(msisdn)
+
+	for i := len(masked) - 1; i >= 0; i-- {
+		if masked[i] < '0' || masked[i] > '9' {
+			continue
+		}
+		if visible < VisibleDigits {
+			visible++
+			continue
+		}
+		masked[i] = '*'
+	}
+
+	return string(masked)
+}
+
+// Mask msisdns found in s
+func Mask(s string) string {
+	return msisdnPattern.ReplaceAllStringFunc(s, MaskMSISDN)
+}
+
+// Handler adding the request id of the context to records and masking msisdns before
+// passing them on
+type Handler struct {
+	next slog.Handler
+}
+
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+
+	masked := slog.NewRecord(r.Time, r.Level, Mask(r.Message), r.PC)
+
+	hasRequestID := false
+	r.Attrs(func(a slog.Attr) bool {
+		hasRequestID = hasRequestID || a.Key == RequestIDKey
+		masked.AddAttrs(mask(a))
+		return true
+	})
+
+	if id := reqctx.RequestID(ctx); id != "" && !hasRequestID {
+		masked.AddAttrs(slog.String(RequestIDKey, id))
+	}
+
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+
+	masked := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		masked = append(masked, mask(a))
+	}
+
+	return &Handler{next: h.next.WithAttrs(masked)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+// mask msisdns in the value of a, request ids are random and left as they are
+func mask(a slog.Attr) slog.Attr {
+
+	a.Value = a.Value.Resolve()
+
+	switch {
+	case a.Key == RequestIDKey:
+		return a
+	case a.Value.Kind() == slog.KindGroup:
+		attrs := a.Value.Group()
+		masked := make([]slog.Attr, 0, len(attrs))
+		for _, attr := range attrs {
+			masked = append(masked, mask(attr))
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(masked...)}
+	case strings.EqualFold(a.Key, MSISDNKey):
+		return slog.String(a.Key, MaskMSISDN(a.Value.String()))
+	case a.Value.Kind() == slog.KindString:
+		return slog.String(a.Key, Mask(a.Value.String()))
+	case a.Value.Kind() == slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Mask(err.Error()))
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+)
+
+func TestMaskMSISDN(t *testing.T) {
+
+	tests := map[string]string{
+		"8-6785500":    "*-****500",
+		"+46701234567": "+********567",
+		"12":           "12",
+		"":             "",
+	}
+
+	for msisdn, expected := range tests {
+		if masked := MaskMSISDN(msisdn); masked != expected {
+			t.Errorf("expected %q masked as %q, got: %q", msisdn, expected, masked)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+
+	tests := map[string]string{
+		"subscription 8-6785500 not found":          "subscription *-****500 not found",
+		"calling +46701234567 and 070 1234567":      "calling +********567 and *** ****567",
+		"activate at 2031-05-21T00:00:00Z, 3 tries": "activate at 2031-05-21T00:00:00Z, 3 tries",
+		"listening on 0.0.0.0:3000, took 1500000ns": "listening on 0.0.0.0:3000, took 1500000ns",
+	}
+
+	for s, expected := range tests {
+		if masked := Mask(s); masked != expected {
+			t.Errorf("expected %q masked as %q, got: %q", s, expected, masked)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := reqctx.WithRequestID(context.Background(), "1234567890abcdef")
+
+	logger.With("msisdn", "8-6785500").ErrorContext(ctx, "lookup of 8-6785501 failed",
+		"error", errors.New("no operator for 8-6785502"),
+		slog.Group("subscription", "msisdn", "8-6785503"),
+	)
+	logger.DebugContext(ctx, "not logged")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single json record, got: %s", buf.String())
+	}
+
+	if strings.Contains(buf.String(), "678550") {
+		t.Fatalf("expected msisdns to be masked, got: %s", buf.String())
+	}
+
+	if record[RequestIDKey] != "1234567890abcdef" || record["msisdn"] != "*-****500" || record["msg"] != "lookup of *-****501 failed" {
+		t.Fatalf("unexpected record: %s", buf.String())
+	}
+}
@@ -0,0 +1,124 @@
+// Package metrics collects Prometheus metrics of the api, the calls it makes to PTS and the
+// subscriptions it stores, served in the Prometheus exposition format by Handler.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// Namespace of every metric
+const Namespace = "subscription_api"
+
+// CollectTimeout of metrics computed from storage when scraped
+const CollectTimeout = 5 * time.Second
+
+// Registry of the metrics served by Handler, including go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests served by method, route template and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration of requests served by method and route template
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests served by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// PTSRequests made by outcome, calls failed fast by the breaker or limiter never reach pts
+	PTSRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pts_requests_total",
+		Help:      "Calls to PTS by outcome, one of ok, not_found, client_error, server_error, throttled, transport_error, breaker_open or rate_limited.",
+	}, []string{"outcome"})
+
+	// PTSRequestDuration of calls that reached pts by outcome
+	PTSRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "pts_request_duration_seconds",
+		Help:      "Duration of calls to PTS by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	// OperatorCacheLookups by result, hit or miss
+	OperatorCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "operator_cache_lookups_total",
+		Help:      "Operator cache lookups by result, hit or miss.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		PTSRequests,
+		PTSRequestDuration,
+		OperatorCacheLookups,
+	)
+}
+
+// Handler serving the metrics of Registry along with those of server, the registry of collectors
+// belonging to a single server such as the SubscriptionCollector, nil if it has none
+func Handler(server *prometheus.Registry) http.Handler {
+
+	gatherers := prometheus.Gatherers{Registry}
+	if server != nil {
+		gatherers = append(gatherers, server)
+	}
+
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// SubscriptionCollector of gauges counting stored subscriptions by status and type, computed
+// from storage when scraped
+type SubscriptionCollector struct {
+	counter subscription.Counter
+	desc    *prometheus.Desc
+}
+
+func NewSubscriptionCollector(counter subscription.Counter) *SubscriptionCollector {
+	return &SubscriptionCollector{
+		counter: counter,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, "", "subscriptions"),
+			"Stored subscriptions by status and type.",
+			[]string{"status", "type"}, nil,
+		),
+	}
+}
+
+func (c *SubscriptionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *SubscriptionCollector) Collect(ch chan<- prometheus.Metric) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), CollectTimeout)
+	defer cancel()
+
+	counts, err := c.counter.Count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count.N), string(count.Status), count.Type)
+	}
+}
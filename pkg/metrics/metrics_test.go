@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/mem"
+)
+
+func TestSubscriptionCollector(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().UTC().Add(time.Hour)
+	for i, subType := range []string{"PBX", "PBX", "CELL"} {
+		msisdn := fmt.Sprintf("8-678550%d", i)
+		typ := subType
+		if _, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &later, Type: &typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msisdn := "8-6785502"
+	if _, err := repo.Cancel(ctx, &msisdn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewSubscriptionCollector(repo.(subscription.Counter)))
+
+	expected := `
+# HELP subscription_api_subscriptions Stored subscriptions by status and type.
+# TYPE subscription_api_subscriptions gauge
+subscription_api_subscriptions{status="cancelled",type="CELL"} 1
+subscription_api_subscriptions{status="pending",type="PBX"} 2
+`
+
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/metrics"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"golang.org/x/sync/singleflight"
+)
+
+// purgeEvery entries stored the expired ones are purged
+const purgeEvery = 1024
+
+type entry struct {
+	name    *string
+	err     error
+	expires time.Time
+}
+
+// result copies the cached name so callers can not modify the cache
+func (e entry) result() (*string, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	name := *e.name
+	return &name, nil
+}
+
+// Stats of cache lookups
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Repository caching operator names of a wrapped operator.Repository
+type Repository struct {
+	next        operator.Repository
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       clock.Clock
+	entries     map[string]entry
+	// stored since expired entries were last purged
+	stored int
+	group  singleflight.Group
+	hits   uint64
+	misses uint64
+	sync.RWMutex
+}
+
+// NewRepository caching names from next for ttl and operator.ErrNotFound for negativeTTL
+func NewRepository(next operator.Repository, ttl, negativeTTL time.Duration, clk clock.Clock) (*Repository, error) {
+
+	if next == nil {
+		return nil, errors.New("no operator repository to cache provided")
+	}
+
+	if ttl <= 0 {
+		return nil, errors.New("cache ttl needs to be positive")
+	}
+
+	return &Repository{
+		next:        next,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		clock:       clk,
+		entries:     map[string]entry{},
+	}, nil
+}
+
+func (repo *Repository) Get(ctx context.Context, msisdn *string) (*string, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	if e, ok := repo.lookup(*msisdn); ok {
+		atomic.AddUint64(&repo.hits, 1)
+		metrics.OperatorCacheLookups.WithLabelValues("hit").Inc()
+		return e.result()
+	}
+
+	atomic.AddUint64(&repo.misses, 1)
+	metrics.OperatorCacheLookups.WithLabelValues("miss").Inc()
+
+	// the shared lookup must not be cancelled by whichever caller started it
+	detached := context.WithoutCancel(ctx)
+
+	ch := repo.group.DoChan(*msisdn, func() (interface{}, error) {
+		return repo.fetch(detached, *msisdn)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(entry).result()
+	}
+}
+
+// Stats of cache hits and misses since the cache was created
+func (repo *Repository) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&repo.hits),
+		Misses: atomic.LoadUint64(&repo.misses),
+	}
+}
+
+// Forget cached result for msisdn, e.g. once its number was found to be ported
+func (repo *Repository) Forget(msisdn string) {
+	repo.Lock()
+	defer repo.Unlock()
+	delete(repo.entries, msisdn)
+}
+
+func (repo *Repository) lookup(msisdn string) (entry, bool) {
+
+	repo.RLock()
+	defer repo.RUnlock()
+
+	e, ok := repo.entries[msisdn]
+	if !ok || !repo.clock.Now().Before(e.expires) {
+		return entry{}, false
+	}
+
+	return e, true
+}
+
+func (repo *Repository) fetch(ctx context.Context, msisdn string) (interface{}, error) {
+
+	// a lookup that missed just before another one stored its result finds it here
+	if e, ok := repo.lookup(msisdn); ok {
+		return e, nil
+	}
+
+	name, err := repo.next.Get(ctx, &msisdn)
+
+	var e entry
+	switch {
+	case err == nil:
+		e = entry{name: name, expires: repo.clock.Now().Add(repo.ttl)}
+	case errors.Is(err, operator.ErrNotFound) && repo.negativeTTL > 0:
+		e = entry{err: err, expires: repo.clock.Now().Add(repo.negativeTTL)}
+	default:
+		return nil, err
+	}
+
+	repo.Lock()
+	repo.entries[msisdn] = e
+	repo.purge()
+	repo.Unlock()
+
+	return e, nil
+}
+
+// purge expired entries every purgeEvery entries stored, expects the lock to be held
+func (repo *Repository) purge() {
+
+	if repo.stored++; repo.stored < purgeEvery {
+		return
+	}
+
+	repo.stored = 0
+
+	now := repo.clock.Now()
+	for msisdn, e := range repo.entries {
+		if !now.Before(e.expires) {
+			delete(repo.entries, msisdn)
+		}
+	}
+}
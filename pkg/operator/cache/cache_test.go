@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+type stubRepository struct {
+	calls   int64
+	release chan struct{}
+}
+
+func (repo *stubRepository) Get(ctx context.Context, msisdn *string) (*string, error) {
+	atomic.AddInt64(&repo.calls, 1)
+	if repo.release != nil {
+		<-repo.release
+	}
+	if *msisdn == "unknown" {
+		return nil, operator.ErrNotFound
+	}
+	name := "Tele2 Sverige AB"
+	return &name, nil
+}
+
+func TestGet(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+	next := &stubRepository{}
+
+	repo, err := NewRepository(next, time.Hour, time.Minute, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn, unknown := "8-6785500", "unknown"
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.Get(ctx, &msisdn); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Get(ctx, &unknown); !errors.Is(err, operator.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	}
+
+	if next.calls != 2 {
+		t.Fatalf("expected 2 calls to wrapped repository, got: %d", next.calls)
+	}
+
+	// negative entry expired, name still cached
+	clk.Advance(2 * time.Minute)
+	repo.Get(ctx, &msisdn)
+	repo.Get(ctx, &unknown)
+
+	if next.calls != 3 {
+		t.Fatalf("expected 3 calls to wrapped repository, got: %d", next.calls)
+	}
+
+	if stats := repo.Stats(); stats.Hits != 5 || stats.Misses != 3 {
+		t.Fatalf("expected 5 hits and 3 misses, got: %+v", stats)
+	}
+}
+
+func TestGetConcurrent(t *testing.T) {
+
+	ctx := context.Background()
+	next := &stubRepository{release: make(chan struct{})}
+
+	repo, err := NewRepository(next, time.Hour, time.Minute, clock.NewFake(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ready <- struct{}{}
+			if _, err := repo.Get(ctx, &msisdn); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		<-ready
+	}
+
+	// lookups still on their way either join the one in flight or find its result cached
+	close(next.release)
+	wg.Wait()
+
+	if next.calls != 1 {
+		t.Fatalf("expected concurrent lookups to be collapsed into 1 call, got: %d", next.calls)
+	}
+}
+
+func TestPurge(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+
+	repo, err := NewRepository(&stubRepository{}, time.Hour, time.Minute, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(prefix string, n int) {
+		for i := 0; i < n; i++ {
+			msisdn := fmt.Sprintf("%s-%d", prefix, i)
+			if _, err := repo.Get(ctx, &msisdn); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	get("8", purgeEvery-1)
+	clk.Advance(2 * time.Hour)
+
+	// expired entries are purged once purgeEvery entries were stored, whatever the size of the cache
+	get("9", 1)
+
+	if n := len(repo.entries); n != 1 {
+		t.Fatalf("expected expired entries to be purged, got: %d entries", n)
+	}
+}
+
+func TestForget(t *testing.T) {
+
+	next := &stubRepository{}
+	repo, err := NewRepository(next, time.Hour, 0, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	msisdn := "8-6785500"
+
+	for i, expected := range []int64{1, 1, 2} {
+		if i == 2 {
+			repo.Forget(msisdn)
+		}
+		if _, err := repo.Get(ctx, &msisdn); err != nil {
+			t.Fatal(err)
+		}
+		if calls := atomic.LoadInt64(&next.calls); calls != expected {
+			t.Fatalf("expected %d lookups after get %d, got: %d", expected, i+1, calls)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+// Repository looking up operators from a primary operator.Repository, falling back to a
+// secondary one while the primary is unavailable
+type Repository struct {
+	primary   operator.Repository
+	secondary operator.Repository
+}
+
+// NewRepository looking up operators from primary, or from secondary when primary fails with
+// operator.ErrUnavailable
+func NewRepository(primary, secondary operator.Repository) (*Repository, error) {
+
+	if primary == nil || secondary == nil {
+		return nil, errors.New("no primary and secondary operator repository provided")
+	}
+
+	return &Repository{primary: primary, secondary: secondary}, nil
+}
+
+func (repo *Repository) Get(ctx context.Context, msisdn *string) (*string, error) {
+	name, _, err := repo.Resolve(ctx, msisdn)
+	return name, err
+}
+
+// Resolve operator of msisdn, reporting it as not verified if the fallback answered. A failing
+// fallback returns the error of the primary as it is the one that may know better once it is
+// available again.
+func (repo *Repository) Resolve(ctx context.Context, msisdn *string) (*string, bool, error) {
+
+	name, err := repo.primary.Get(ctx, msisdn)
+	if !errors.Is(err, operator.ErrUnavailable) {
+		return name, err == nil, err
+	}
+
+	name, fallbackErr := repo.secondary.Get(ctx, msisdn)
+	if fallbackErr != nil {
+		slog.WarnContext(ctx, "operator fallback failed", "error", fallbackErr)
+		return nil, false, err
+	}
+
+	slog.WarnContext(ctx, "operator looked up from fallback", "error", err)
+
+	return name, false, nil
+}
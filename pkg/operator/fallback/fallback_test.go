@@ -0,0 +1,64 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+type stubOperators struct {
+	name string
+	err  error
+}
+
+func (repo *stubOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	if repo.err != nil {
+		return nil, repo.err
+	}
+	name := repo.name
+	return &name, nil
+}
+
+func TestResolve(t *testing.T) {
+
+	msisdn := "8-6785500"
+	plan := &stubOperators{name: "Telia Sverige AB"}
+
+	tests := []struct {
+		primary  *stubOperators
+		expected string
+		verified bool
+		err      error
+	}{
+		{primary: &stubOperators{name: "Tele2 Sverige AB"}, expected: "Tele2 Sverige AB", verified: true},
+		{primary: &stubOperators{err: operator.ErrNotFound}, err: operator.ErrNotFound},
+		{primary: &stubOperators{err: operator.ErrUnavailable}, expected: "Telia Sverige AB"},
+	}
+
+	for i, test := range tests {
+
+		repo, err := NewRepository(test.primary, plan)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name, verified, err := repo.Resolve(context.Background(), &msisdn)
+		if !errors.Is(err, test.err) || test.err == nil && (*name != test.expected || verified != test.verified) {
+			t.Fatalf("%d: expected %q verified %t, %v, got: %v %t, %v", i, test.expected, test.verified, test.err, name, verified, err)
+		}
+	}
+
+	// a failing fallback reports the primary as unavailable
+	plan.err = operator.ErrNotFound
+
+	repo, err := NewRepository(&stubOperators{err: operator.ErrUnavailable}, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Get(context.Background(), &msisdn); !errors.Is(err, operator.ErrUnavailable) {
+		t.Fatalf("expected unavailable, got: %v", err)
+	}
+}
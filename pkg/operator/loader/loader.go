@@ -0,0 +1,112 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+// DefaultWait for lookups of the same request to be collected before they are made
+const DefaultWait = time.Millisecond
+
+// DefaultConcurrency of lookups made in parallel against the wrapped repository
+const DefaultConcurrency = 8
+
+type result struct {
+	name *string
+	err  error
+	done chan struct{}
+}
+
+// Loader deduplicating operator lookups made while resolving a single request. Every msisdn
+// is looked up once with a single Get, made in parallel with the others collected within
+// wait, and its result kept for the lifetime of the loader. Subscriptions carry the operator
+// last checked, so only those whose operator was never checked are looked up.
+type Loader struct {
+	next        operator.Repository
+	wait        time.Duration
+	concurrency int
+	results     map[string]*result
+	batch       []string
+	sync.Mutex
+}
+
+// New loader looking up operators from next, meant to be created per request
+func New(next operator.Repository, wait time.Duration, concurrency int) *Loader {
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Loader{
+		next:        next,
+		wait:        wait,
+		concurrency: concurrency,
+		results:     map[string]*result{},
+	}
+}
+
+func (l *Loader) Get(ctx context.Context, msisdn *string) (*string, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	l.Lock()
+	r, ok := l.results[*msisdn]
+	if !ok {
+		r = &result{done: make(chan struct{})}
+		l.results[*msisdn] = r
+		l.batch = append(l.batch, *msisdn)
+		if len(l.batch) == 1 {
+			// the lookup starting a batch dispatches it, in the context of its request
+			time.AfterFunc(l.wait, func() { l.dispatch(ctx) })
+		}
+	}
+	l.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	name := *r.name
+
+	return &name, nil
+}
+
+// dispatch the lookups collected so far
+func (l *Loader) dispatch(ctx context.Context) {
+
+	l.Lock()
+	batch := l.batch
+	l.batch = nil
+	results := make([]*result, len(batch))
+	for i, msisdn := range batch {
+		results[i] = l.results[msisdn]
+	}
+	l.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, l.concurrency)
+
+	for i := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(msisdn string, r *result) {
+			defer func() { <-sem; wg.Done() }()
+			r.name, r.err = l.next.Get(ctx, &msisdn)
+			close(r.done)
+		}(batch[i], results[i])
+	}
+
+	wg.Wait()
+}
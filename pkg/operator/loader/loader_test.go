@@ -0,0 +1,52 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingOperators struct {
+	calls map[string]int
+	sync.Mutex
+}
+
+func (repo *countingOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	repo.Lock()
+	defer repo.Unlock()
+	repo.calls[*msisdn]++
+	name := "operator of " + *msisdn
+	return &name, nil
+}
+
+func TestLoader(t *testing.T) {
+
+	repo := &countingOperators{calls: map[string]int{}}
+	l := New(repo, 5*time.Millisecond, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msisdn := fmt.Sprintf("8-%d", i%5)
+			name, err := l.Get(context.Background(), &msisdn)
+			if err != nil || *name != "operator of "+msisdn {
+				t.Errorf("unexpected result for %s: %v, %v", msisdn, name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(repo.calls) != 5 {
+		t.Fatalf("expected 5 msisdns looked up, got: %d", len(repo.calls))
+	}
+
+	for msisdn, n := range repo.calls {
+		if n != 1 {
+			t.Fatalf("expected %s to be looked up once, got: %d", msisdn, n)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package numberplan looks up operators offline from imported number plans, mapping number
+// prefixes to the operator the range was assigned to. Ported numbers are not known to it.
+package numberplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/config"
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+// Repository for operator using the latest number plan of a store, reloading it when a new
+// version is imported
+type Repository struct {
+	store    *Store
+	interval time.Duration
+	clock    clock.Clock
+	plan     atomic.Pointer[Plan]
+	cancel   context.CancelFunc
+	done     chan struct{}
+	sync.Mutex
+}
+
+// NewRepository for operator using the latest number plan of store, checking for new versions
+// every interval once started. Having no plan imported yet is not an error, lookups fail with
+// operator.ErrUnavailable until one is.
+func NewRepository(store *Store, interval time.Duration, clk clock.Clock) (*Repository, error) {
+
+	if store == nil {
+		return nil, errors.New("no number plan store provided")
+	}
+
+	repo := &Repository{store: store, interval: interval, clock: clk}
+
+	if _, err := repo.Reload(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// NewRepositoryFromConfig for operator using the number plans imported to NUMBER_PLAN_DIR
+func NewRepositoryFromConfig(cfg *config.Config) (*Repository, error) {
+
+	store, err := NewStore(cfg.NumberPlanDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRepository(store, cfg.NumberPlanReloadInterval, clock.New())
+}
+
+func (repo *Repository) Get(ctx context.Context, msisdn *string) (*string, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	p := repo.plan.Load()
+	if p == nil {
+		return nil, fmt.Errorf("no number plan imported: %w", operator.ErrUnavailable)
+	}
+
+	name, ok := p.Lookup(*msisdn)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in number plan %s: %w", *msisdn, p.Version, operator.ErrNotFound)
+	}
+
+	return &name, nil
+}
+
+// Version of the number plan in use, empty if none is imported
+func (repo *Repository) Version() string {
+
+	if p := repo.plan.Load(); p != nil {
+		return p.Version
+	}
+
+	return ""
+}
+
+// Reload the latest number plan if it is not the one in use, reporting if it was. The plan in
+// use is kept if the latest fails to load.
+func (repo *Repository) Reload() (bool, error) {
+
+	latest, err := repo.store.Latest()
+	if err != nil {
+		return false, err
+	}
+
+	if latest == "" || latest == repo.Version() {
+		return false, nil
+	}
+
+	p, err := repo.store.Load(latest)
+	if err != nil {
+		return false, err
+	}
+
+	repo.plan.Store(p)
+
+	slog.Info("number plan loaded", "version", p.Version, "ranges", p.Len())
+
+	return true, nil
+}
+
+// Start reloading new versions in the background until ctx is done or it is stopped
+func (repo *Repository) Start(ctx context.Context) error {
+
+	if repo.interval <= 0 {
+		return errors.New("reload interval needs to be positive")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	repo.Lock()
+	repo.cancel = cancel
+	repo.done = make(chan struct{})
+	repo.Unlock()
+
+	go repo.run(ctx)
+
+	return nil
+}
+
+// Stop reloading and wait for a reload in progress to finish
+func (repo *Repository) Stop() {
+
+	repo.Lock()
+	cancel, done := repo.cancel, repo.done
+	repo.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (repo *Repository) run(ctx context.Context) {
+
+	defer close(repo.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-repo.clock.After(repo.interval):
+			if _, err := repo.Reload(); err != nil {
+				slog.ErrorContext(ctx, "failed to reload number plan, keeping the one in use", "version", repo.Version(), "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package numberplan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+)
+
+const ranges = `operator,prefix,note
+Telia Sverige AB,8,
+Tele2 Sverige AB,8-678,
+Telenor Sverige AB, 8-678 55,ported block
+Tele2 Sverige AB,8678,duplicate of 8-678
+`
+
+func TestLookup(t *testing.T) {
+
+	p, err := Parse("v1", strings.NewReader(ranges))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Len() != 3 {
+		t.Fatalf("expected 3 ranges, got: %d", p.Len())
+	}
+
+	for msisdn, expected := range map[string]string{
+		"8-6785500": "Telenor Sverige AB",
+		"8-6785400": "Tele2 Sverige AB",
+		"8-123456":  "Telia Sverige AB",
+		"8-67":      "Telia Sverige AB",
+		"7-0000000": "",
+		"not a one": "",
+	} {
+		name, ok := p.Lookup(msisdn)
+		if name != expected || ok != (expected != "") {
+			t.Errorf("%s: expected %q, got: %q %t", msisdn, expected, name, ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	for input, expected := range map[string]string{
+		"":                                     "empty",
+		"prefix\n8\n":                          "no operator column",
+		"prefix,operator\n":                    "no ranges",
+		"prefix,operator\n8,Telia\n8x,Tele2\n": "line 3: invalid prefix",
+		"prefix,operator\n8,Telia\n8,Tele2\n":  "line 3: prefix 8 assigned to both Telia and Tele2",
+		"prefix,operator\n8,\n":                "line 2: no operator",
+		"prefix,operator\n8\n":                 "line 2: expected 2 columns",
+	} {
+		_, err := Parse("v1", strings.NewReader(input))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error containing %q, got: %v", input, expected, err)
+		}
+	}
+}
+
+func TestRepository(t *testing.T) {
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewRepository(store, time.Minute, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+
+	if _, err := repo.Get(context.Background(), &msisdn); !errors.Is(err, operator.ErrUnavailable) {
+		t.Fatalf("expected unavailable without a number plan, got: %v", err)
+	}
+
+	if _, err := store.Import("2021-05-21", strings.NewReader(ranges)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Import("2021-05-21", strings.NewReader(ranges)); !errors.Is(err, ErrVersionExists) {
+		t.Fatalf("expected version to exist, got: %v", err)
+	}
+
+	if _, err := store.Import("../2021-05-22", strings.NewReader(ranges)); err == nil {
+		t.Fatal("expected invalid version")
+	}
+
+	if reloaded, err := repo.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected imported plan to be loaded, got: %t, %v", reloaded, err)
+	}
+
+	name, err := repo.Get(context.Background(), &msisdn)
+	if err != nil || *name != "Telenor Sverige AB" {
+		t.Fatalf("expected Telenor Sverige AB, got: %v, %v", name, err)
+	}
+
+	if _, err := store.Import("2021-06-01", strings.NewReader("prefix,operator\n8-678,Tele2 Sverige AB\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded, err := repo.Reload(); err != nil || !reloaded || repo.Version() != "2021-06-01" {
+		t.Fatalf("expected new version to be loaded, got: %t %s, %v", reloaded, repo.Version(), err)
+	}
+
+	name, err = repo.Get(context.Background(), &msisdn)
+	if err != nil || *name != "Tele2 Sverige AB" {
+		t.Fatalf("expected Tele2 Sverige AB, got: %v, %v", name, err)
+	}
+
+	other := "7-0000000"
+	if _, err := repo.Get(context.Background(), &other); !errors.Is(err, operator.ErrNotFound) {
+		t.Fatalf("expected not found outside the plan, got: %v", err)
+	}
+
+	if reloaded, err := repo.Reload(); err != nil || reloaded {
+		t.Fatalf("expected nothing to reload, got: %t, %v", reloaded, err)
+	}
+
+	versions, err := store.Versions()
+	if err != nil || strings.Join(versions, " ") != "2021-05-21 2021-06-01" {
+		t.Fatalf("expected two versions, got: %v, %v", versions, err)
+	}
+}
@@ -0,0 +1,195 @@
+package numberplan
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Range of numbers starting with Prefix assigned to Operator
+type Range struct {
+	Prefix   string
+	Operator string
+}
+
+// node of the trie of prefixes, indexed by digit
+type node struct {
+	children [10]*node
+	operator string
+}
+
+// Plan of number ranges, looking up the operator of the longest prefix matching a number
+type Plan struct {
+	Version string
+	root    node
+	ranges  []Range
+}
+
+// digits of number, ignoring the separators msisdns are written with
+func digits(number string) (string, error) {
+
+	var b strings.Builder
+
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-', r == ' ', r == '+':
+		default:
+			return "", fmt.Errorf("%q is not a number", number)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "", fmt.Errorf("%q has no digits", number)
+	}
+
+	return b.String(), nil
+}
+
+// insert range, failing if its prefix is already assigned to another operator
+func (p *Plan) insert(r Range) error {
+
+	prefix, err := digits(r.Prefix)
+	if err != nil {
+		return fmt.Errorf("invalid prefix: %w", err)
+	}
+
+	operator := strings.TrimSpace(r.Operator)
+	if operator == "" {
+		return fmt.Errorf("no operator for prefix %s", prefix)
+	}
+
+	n := &p.root
+	for _, d := range prefix {
+		i := d - '0'
+		if n.children[i] == nil {
+			n.children[i] = &node{}
+		}
+		n = n.children[i]
+	}
+
+	switch n.operator {
+	case "":
+		n.operator = operator
+		p.ranges = append(p.ranges, Range{Prefix: prefix, Operator: operator})
+	case operator:
+	default:
+		return fmt.Errorf("prefix %s assigned to both %s and %s", prefix, n.operator, operator)
+	}
+
+	return nil
+}
+
+// Lookup operator of the longest prefix of msisdn
+func (p *Plan) Lookup(msisdn string) (string, bool) {
+
+	number, err := digits(msisdn)
+	if err != nil {
+		return "", false
+	}
+
+	var operator string
+
+	n := &p.root
+	for _, d := range number {
+		if n = n.children[d-'0']; n == nil {
+			break
+		}
+		if n.operator != "" {
+			operator = n.operator
+		}
+	}
+
+	return operator, operator != ""
+}
+
+// Len of the plan in ranges
+func (p *Plan) Len() int {
+	return len(p.ranges)
+}
+
+// Parse plan from csv with a header row naming the columns prefix and operator, other columns
+// are ignored. Errors name the line they were found on.
+func Parse(version string, r io.Reader) (*Plan, error) {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("number plan is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read number plan: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	prefixColumn, ok := columns["prefix"]
+	if !ok {
+		return nil, errors.New("number plan has no prefix column")
+	}
+
+	operatorColumn, ok := columns["operator"]
+	if !ok {
+		return nil, errors.New("number plan has no operator column")
+	}
+
+	p := &Plan{Version: version}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read number plan: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if len(record) <= prefixColumn || len(record) <= operatorColumn {
+			return nil, fmt.Errorf("line %d: expected %d columns, got: %d", line, len(header), len(record))
+		}
+
+		if err := p.insert(Range{Prefix: record[prefixColumn], Operator: record[operatorColumn]}); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if len(p.ranges) == 0 {
+		return nil, errors.New("number plan has no ranges")
+	}
+
+	sort.Slice(p.ranges, func(i, j int) bool { return p.ranges[i].Prefix < p.ranges[j].Prefix })
+
+	return p, nil
+}
+
+// Write plan as csv that Parse reads back
+func (p *Plan) Write(w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"prefix", "operator"}); err != nil {
+		return err
+	}
+
+	for _, r := range p.ranges {
+		if err := writer.Write([]string{r.Prefix, r.Operator}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
@@ -0,0 +1,144 @@
+package numberplan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersionFormat of versions generated when importing, sorting in the order they were imported
+const VersionFormat = "20060102T150405Z"
+
+const extension = ".csv"
+
+var versionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// ErrVersionExists returned when importing a version that was already imported
+var ErrVersionExists = errors.New("number plan version already exists")
+
+// Store of imported number plans, one csv file per version in a directory. The latest version
+// by name is the one in use, remove its file to go back to the one before.
+type Store struct {
+	dir string
+}
+
+// NewStore of the number plans in dir, which is created when the first plan is imported
+func NewStore(dir string) (*Store, error) {
+
+	if dir == "" {
+		return nil, errors.New("no number plan directory provided")
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// NewVersion named after the time it was imported at
+func NewVersion(now time.Time) string {
+	return now.UTC().Format(VersionFormat)
+}
+
+func (s *Store) path(version string) string {
+	return filepath.Join(s.dir, version+extension)
+}
+
+// Versions imported, oldest first
+func (s *Store) Versions() ([]string, error) {
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read number plan directory: %w", err)
+	}
+
+	versions := []string{}
+	for _, e := range entries {
+		version, ok := strings.CutSuffix(e.Name(), extension)
+		if e.Type().IsRegular() && ok && versionPattern.MatchString(version) {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Strings(versions)
+
+	return versions, nil
+}
+
+// Latest version imported, empty if none is
+func (s *Store) Latest() (string, error) {
+
+	versions, err := s.Versions()
+	if err != nil || len(versions) == 0 {
+		return "", err
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// Load plan of version
+func (s *Store) Load(version string) (*Plan, error) {
+
+	f, err := os.Open(s.path(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open number plan %s: %w", version, err)
+	}
+	defer f.Close()
+
+	p, err := Parse(version, f)
+	if err != nil {
+		return nil, fmt.Errorf("number plan %s: %w", version, err)
+	}
+
+	return p, nil
+}
+
+// Import plan read from r as version, validating it before it is stored. The file is written
+// under a temporary name and renamed into place, so a running server never reads half of it.
+func (s *Store) Import(version string, r io.Reader) (*Plan, error) {
+
+	if !versionPattern.MatchString(version) {
+		return nil, fmt.Errorf("invalid number plan version %q, use letters, digits, dots, dashes and underscores", version)
+	}
+
+	p, err := Parse(version, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(s.path(version)); err == nil {
+		return nil, fmt.Errorf("%s: %w", version, ErrVersionExists)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create number plan directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".numberplan-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create number plan file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := p.Write(tmp); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write number plan file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write number plan file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(version)); err != nil {
+		return nil, fmt.Errorf("failed to store number plan file: %w", err)
+	}
+
+	return p, nil
+}
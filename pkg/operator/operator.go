@@ -5,9 +5,18 @@ import (
 	"errors"
 )
 
-// ErrNotFound returned if a subscription not found for the provided msisdn
-var ErrNotFound = errors.New("subscription not found for the provided msisdn")
+// ErrNotFound returned if no operator is found for the provided msisdn
+var ErrNotFound = errors.New("no operator found for the provided msisdn")
+
+// ErrUnavailable returned if the operator lookup could not be made, it may succeed if retried later
+var ErrUnavailable = errors.New("operator lookup unavailable")
 
 type Repository interface {
 	Get(ctx context.Context, msisdn *string) (*string, error)
 }
+
+// Resolver of operators telling if a name was verified against the source of truth, names a
+// fallback answered with while it was unavailable are not
+type Resolver interface {
+	Resolve(ctx context.Context, msisdn *string) (name *string, verified bool, err error)
+}
@@ -6,10 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/config"
+	"github.com/rgynn/subscription-api/pkg/metrics"
 	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/resilience"
 )
 
 // PTSResponse from api
@@ -21,29 +28,179 @@ type PTSResponse struct {
 	} `json:"d"`
 }
 
+// Outcomes of calls to pts, the label of their metrics
+const (
+	OutcomeOK             = "ok"
+	OutcomeNotFound       = "not_found"
+	OutcomeClientError    = "client_error"
+	OutcomeServerError    = "server_error"
+	OutcomeThrottled      = "throttled"
+	OutcomeTransportError = "transport_error"
+	OutcomeBreakerOpen    = "breaker_open"
+	OutcomeRateLimited    = "rate_limited"
+)
+
+// Policy for calls made to the pts api
+type Policy struct {
+	// Retries of transient failures after the first attempt
+	Retries int
+	Backoff resilience.Backoff
+	// BreakerThreshold of consecutive failures before failing fast, 0 disables the breaker
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RateLimit of calls per second, 0 disables the limiter
+	RateLimit float64
+	RateBurst int
+}
+
+// DefaultPolicy used by NewRepository
+var DefaultPolicy = Policy{
+	Retries:          2,
+	Backoff:          resilience.Backoff{Base: 100 * time.Millisecond, Max: 2 * time.Second},
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
 type Repository struct {
 	timeout time.Duration
 	url     string
 	client  *http.Client
+	policy  Policy
+	breaker *resilience.Breaker
+	limiter *resilience.Limiter
 }
 
 // NewRepsitory for operator using pts api
 func NewRepository(timeout time.Duration, url string) (operator.Repository, error) {
+	return NewRepositoryWithPolicy(timeout, url, DefaultPolicy, clock.New())
+}
+
+// NewRepositoryWithPolicy for operator using pts api, retrying and limiting calls according to policy
+func NewRepositoryWithPolicy(timeout time.Duration, url string, policy Policy, clk clock.Clock) (*Repository, error) {
+
+	if url == "" {
+		return nil, errors.New("no pts url provided")
+	}
+
 	return &Repository{
 		timeout: timeout,
 		url:     url,
 		client: &http.Client{
 			Timeout: timeout,
 		},
+		policy:  policy,
+		breaker: resilience.NewBreaker(policy.BreakerThreshold, policy.BreakerCooldown, clk),
+		limiter: resilience.NewLimiter(policy.RateLimit, policy.RateBurst, clk),
 	}, nil
 }
 
+// NewRepositoryFromConfig for operator using pts api
+func NewRepositoryFromConfig(cfg *config.Config) (*Repository, error) {
+	return NewRepositoryWithPolicy(cfg.ClientTimeout, cfg.PTSURL, Policy{
+		Retries:          cfg.PTSRetries,
+		Backoff:          resilience.Backoff{Base: cfg.PTSBackoffBase, Max: cfg.PTSBackoffMax},
+		BreakerThreshold: cfg.PTSBreakerThreshold,
+		BreakerCooldown:  cfg.PTSBreakerCooldown,
+		RateLimit:        cfg.PTSRateLimit,
+		RateBurst:        cfg.PTSRateBurst,
+	}, clock.New())
+}
+
+// BreakerState of the circuit breaker guarding calls to pts
+func (repo *Repository) BreakerState() resilience.State {
+	return repo.breaker.State()
+}
+
+// transientError from pts that may succeed if retried
+type transientError struct {
+	err        error
+	retryAfter time.Duration
+	throttled  bool
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
 func (repo *Repository) Get(ctx context.Context, msisdn *string) (*string, error) {
 
 	if msisdn == nil {
 		return nil, errors.New("no msisdn provided")
 	}
 
+	for attempt := 0; ; attempt++ {
+
+		if err := repo.breaker.Allow(); err != nil {
+			metrics.PTSRequests.WithLabelValues(OutcomeBreakerOpen).Inc()
+			slog.WarnContext(ctx, "pts lookup skipped", "msisdn", *msisdn, "error", err)
+			return nil, fmt.Errorf("pts: %s: %w", err, operator.ErrUnavailable)
+		}
+
+		if err := repo.limiter.Wait(ctx); err != nil {
+			repo.breaker.Release()
+			metrics.PTSRequests.WithLabelValues(OutcomeRateLimited).Inc()
+			return nil, fmt.Errorf("pts rate limit: %s: %w", err, operator.ErrUnavailable)
+		}
+
+		name, err := repo.get(ctx, msisdn)
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, not a failure of pts
+			repo.breaker.Release()
+			return nil, err
+		}
+
+		var transient *transientError
+		if !errors.As(err, &transient) {
+			// pts answered, even if it was with an error of ours
+			repo.breaker.Success()
+			if err != nil && !errors.Is(err, operator.ErrNotFound) {
+				slog.ErrorContext(ctx, "pts lookup failed", "msisdn", *msisdn, "error", err)
+			}
+			return name, err
+		}
+
+		if transient.throttled {
+			// pts is up, only busy
+			repo.breaker.Release()
+		} else {
+			repo.breaker.Failure()
+		}
+
+		if attempt >= repo.policy.Retries || ctx.Err() != nil {
+			slog.ErrorContext(ctx, "pts lookup failed", "msisdn", *msisdn, "attempts", attempt+1, "error", err)
+			return nil, fmt.Errorf("%s: %w", err, operator.ErrUnavailable)
+		}
+
+		// waiting longer than any backoff of ours would park the caller, pts is as good as unavailable
+		if max := repo.policy.Backoff.Max; max > 0 && transient.retryAfter > max {
+			slog.ErrorContext(ctx, "pts lookup failed, asked to retry later than allowed", "msisdn", *msisdn, "attempts", attempt+1, "retry_after", transient.retryAfter, "error", err)
+			return nil, fmt.Errorf("%s: %w", err, operator.ErrUnavailable)
+		}
+
+		delay := repo.policy.Backoff.Delay(attempt)
+		if transient.retryAfter > delay {
+			delay = transient.retryAfter
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			slog.ErrorContext(ctx, "pts lookup failed, no time left to retry", "msisdn", *msisdn, "attempts", attempt+1, "error", err)
+			return nil, fmt.Errorf("%s: %w", err, operator.ErrUnavailable)
+		}
+
+		slog.WarnContext(ctx, "pts lookup failed, retrying", "msisdn", *msisdn, "attempt", attempt+1, "delay", delay, "error", err)
+
+		if err := resilience.Sleep(ctx, delay); err != nil {
+			return nil, fmt.Errorf("%s: %w", err, operator.ErrUnavailable)
+		}
+	}
+}
+
+func (repo *Repository) get(ctx context.Context, msisdn *string) (*string, error) {
+
 	url := fmt.Sprintf("%s?number=%s", repo.url, *msisdn)
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -56,32 +213,81 @@ func (repo *Repository) Get(ctx context.Context, msisdn *string) (*string, error
 
 	req = req.WithContext(ctx)
 
+	start := time.Now()
+	outcome := OutcomeTransportError
+	defer func() {
+		metrics.PTSRequests.WithLabelValues(outcome).Inc()
+		metrics.PTSRequestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	if id := reqctx.RequestID(ctx); id != "" {
+		req.Header.Set(reqctx.RequestIDHeader, id)
+	}
+
 	resp, err := repo.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call pts: %w", err)
+		return nil, &transientError{err: fmt.Errorf("failed to call pts: %w", err)}
 	}
+	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	switch {
+	case resp.StatusCode == http.StatusOK:
 		break
+	case resp.StatusCode == http.StatusTooManyRequests:
+		outcome = OutcomeThrottled
+		return nil, &transientError{
+			err:        fmt.Errorf("pts api rate limit exceeded, got: %d", resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			throttled:  true,
+		}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		outcome = OutcomeServerError
+		return nil, &transientError{
+			err:        fmt.Errorf("expected status code 200 OK from PTS API, got: %d", resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	default:
+		outcome = OutcomeClientError
 		return nil, fmt.Errorf("expected status code 200 OK from PTS API, got: %d", resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, &transientError{err: fmt.Errorf("failed to read body from pts response: %w", err)}
 	}
-	defer resp.Body.Close()
 
 	var response PTSResponse
 	if err := json.Unmarshal(body, &response); err != nil {
+		outcome = OutcomeClientError
 		return nil, fmt.Errorf("failed to unmarshal body from pts response: %w", err)
 	}
 
 	if response.D.Name == "Operatör saknas" {
+		outcome = OutcomeNotFound
 		return nil, operator.ErrNotFound
 	}
 
+	outcome = OutcomeOK
+
 	return &response.D.Name, nil
 }
+
+// parseRetryAfter header given either in seconds or as a http date
+func parseRetryAfter(v string) time.Duration {
+
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -2,30 +2,42 @@ package pts
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rgynn/subscription-api/pkg/clock"
 	"github.com/rgynn/subscription-api/pkg/config"
+	"github.com/rgynn/subscription-api/pkg/metrics"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/resilience"
 )
 
 func TestGet(t *testing.T) {
 
 	cfg, err := config.NewFromEnv("../../../.env")
 	if err != nil {
-		t.Fatal(err)
+		t.Skipf("skipping test against live PTS api: %s", err)
 	}
 
-	repo := &Repository{
-		timeout: cfg.ClientTimeout,
-		url:     cfg.PTSURL,
-		client: &http.Client{
-			Timeout: cfg.ClientTimeout,
-		},
+	msisdn := os.Getenv("TEST_MSISDN_NUMBER")
+	if msisdn == "" {
+		t.Skip("skipping test against live PTS api: no TEST_MSISDN_NUMBER env variable set")
+	}
+
+	repo, err := NewRepositoryFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	ctx := context.Background()
-	msisdn := os.Getenv("TEST_MSISDN_NUMBER")
 	expectedName := os.Getenv("TEST_OPERATOR_NAME")
 
 	result, err := repo.Get(ctx, &msisdn)
@@ -37,3 +49,326 @@ func TestGet(t *testing.T) {
 		t.Fatalf("expected operator name to be: %s, got: %s", expectedName, *result)
 	}
 }
+
+const okBody = `{"d":{"__type":"NumberResult","Name":"Tele2 Sverige AB","Number":"8-6785500"}}`
+
+// fakePTS responds with statuses in order, then 200 OK
+func fakePTS(t *testing.T, hits *int64, statuses ...int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(hits, 1)
+		if int(n) <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		fmt.Fprint(w, okBody)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func testPolicy() Policy {
+	return Policy{
+		Retries: 2,
+		Backoff: resilience.Backoff{Base: time.Millisecond, Max: 5 * time.Millisecond},
+	}
+}
+
+func TestGetRetriesTransientErrors(t *testing.T) {
+
+	var hits int64
+	srv := fakePTS(t, &hits, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	policy := testPolicy()
+	policy.Backoff.Max = 2 * time.Second
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, policy, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	start := time.Now()
+
+	name, err := repo.Get(context.Background(), &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *name != "Tele2 Sverige AB" || hits != 3 {
+		t.Fatalf("expected operator after 3 calls, got: %s after %d calls", *name, hits)
+	}
+
+	if time.Since(start) < time.Second {
+		t.Fatalf("expected Retry-After of 1s to be respected, took: %s", time.Since(start))
+	}
+}
+
+func TestGetRetryAfterTooLong(t *testing.T) {
+
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, testPolicy(), clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no deadline, as for the background refresher
+	msisdn := "8-6785500"
+	if _, err := repo.Get(context.Background(), &msisdn); !errors.Is(err, operator.ErrUnavailable) || hits != 1 {
+		t.Fatalf("expected ErrUnavailable without retrying, got: %v after %d calls", err, hits)
+	}
+}
+
+func TestGetGivesUp(t *testing.T) {
+
+	var hits int64
+	srv := fakePTS(t, &hits, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, testPolicy(), clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := testutil.ToFloat64(metrics.PTSRequests.WithLabelValues(OutcomeServerError))
+
+	msisdn := "8-6785500"
+	if _, err := repo.Get(context.Background(), &msisdn); !errors.Is(err, operator.ErrUnavailable) || hits != 3 {
+		t.Fatalf("expected ErrUnavailable after 3 calls, got: %v after %d calls", err, hits)
+	}
+
+	if n := testutil.ToFloat64(metrics.PTSRequests.WithLabelValues(OutcomeServerError)) - failures; n != 3 {
+		t.Fatalf("expected 3 server errors counted, got: %v", n)
+	}
+}
+
+func TestGetDoesNotRetryClientErrors(t *testing.T) {
+
+	var hits int64
+	srv := fakePTS(t, &hits, http.StatusBadRequest)
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, testPolicy(), clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	if _, err := repo.Get(context.Background(), &msisdn); err == nil || errors.Is(err, operator.ErrUnavailable) || hits != 1 {
+		t.Fatalf("expected non transient error after 1 call, got: %v after %d calls", err, hits)
+	}
+}
+
+func TestGetCircuitBreaker(t *testing.T) {
+
+	var hits int64
+	srv := fakePTS(t, &hits, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+
+	clk := clock.NewFake(time.Now())
+	policy := Policy{BreakerThreshold: 2, BreakerCooldown: time.Minute}
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, policy, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	msisdn := "8-6785500"
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.Get(ctx, &msisdn); !errors.Is(err, operator.ErrUnavailable) {
+			t.Fatalf("expected ErrUnavailable, got: %v", err)
+		}
+	}
+
+	if hits != 2 || repo.BreakerState() != resilience.StateOpen {
+		t.Fatalf("expected open breaker to fail fast after 2 calls, got %d calls, state: %s", hits, repo.BreakerState())
+	}
+
+	clk.Advance(time.Minute)
+
+	// failing probe opens the breaker again
+	if _, err := repo.Get(ctx, &msisdn); !errors.Is(err, operator.ErrUnavailable) || hits != 3 {
+		t.Fatalf("expected probe call to fail, got: %v after %d calls", err, hits)
+	}
+
+	clk.Advance(time.Minute)
+
+	if _, err := repo.Get(ctx, &msisdn); err != nil || repo.BreakerState() != resilience.StateClosed {
+		t.Fatalf("expected successful probe to close breaker, got: %v, state: %s", err, repo.BreakerState())
+	}
+}
+
+func TestGetRateLimited(t *testing.T) {
+
+	var hits int64
+	srv := fakePTS(t, &hits)
+
+	clk := clock.NewFake(time.Now())
+	policy := Policy{RateLimit: 1, RateBurst: 1}
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, policy, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	msisdn := "8-6785500"
+
+	if _, err := repo.Get(ctx, &msisdn); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := repo.Get(ctx, &msisdn)
+		done <- err
+	}()
+
+	clk.BlockUntil(1)
+
+	if n := atomic.LoadInt64(&hits); n != 1 {
+		t.Fatalf("expected second call to wait for a token, got: %d calls", n)
+	}
+
+	clk.Advance(time.Second)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if hits != 2 {
+		t.Fatalf("expected 2 calls, got: %d", hits)
+	}
+}
+
+func TestGetPropagatesRequestID(t *testing.T) {
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(reqctx.RequestIDHeader)
+		fmt.Fprint(w, okBody)
+	}))
+	t.Cleanup(srv.Close)
+
+	repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, testPolicy(), clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	if _, err := repo.Get(reqctx.WithRequestID(context.Background(), "abc123"), &msisdn); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != "abc123" {
+		t.Fatalf("expected request id to be passed on to pts, got: %q", got)
+	}
+}
+
+// TestGetReleasesProbe checks a half-open probe ending without a verdict on pts lets the next probe through
+func TestGetReleasesProbe(t *testing.T) {
+
+	msisdn := "8-6785500"
+
+	tests := []struct {
+		name string
+		// status of the probe if it reaches pts, 0 hangs until the request is cancelled
+		status int
+		policy Policy
+		// probe made by the test, with time advanced on clk before the next one
+		probe func(t *testing.T, repo *Repository, clk *clock.Fake) error
+	}{
+		{
+			name:   "throttled",
+			status: http.StatusTooManyRequests,
+			policy: Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute},
+			probe: func(t *testing.T, repo *Repository, clk *clock.Fake) error {
+				_, err := repo.Get(context.Background(), &msisdn)
+				return err
+			},
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusOK,
+			// a token every two minutes, so the probe after one minute has to wait
+			policy: Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute, RateLimit: 1.0 / 120, RateBurst: 1},
+			probe: func(t *testing.T, repo *Repository, clk *clock.Fake) error {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				_, err := repo.Get(ctx, &msisdn)
+				return err
+			},
+		},
+		{
+			name:   "cancelled",
+			status: 0,
+			policy: Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute},
+			probe: func(t *testing.T, repo *Repository, clk *clock.Fake) error {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				_, err := repo.Get(ctx, &msisdn)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var hits int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt64(&hits, 1)
+				switch {
+				case n == 1:
+					w.WriteHeader(http.StatusInternalServerError)
+				case n == 2 && test.status == 0:
+					<-r.Context().Done()
+				case n == 2 && test.status != http.StatusOK:
+					w.WriteHeader(test.status)
+				default:
+					fmt.Fprint(w, okBody)
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			clk := clock.NewFake(time.Now())
+
+			repo, err := NewRepositoryWithPolicy(time.Second, srv.URL, test.policy, clk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := repo.Get(context.Background(), &msisdn); !errors.Is(err, operator.ErrUnavailable) || repo.BreakerState() != resilience.StateOpen {
+				t.Fatalf("expected failure to open the breaker, got: %v, state: %s", err, repo.BreakerState())
+			}
+
+			clk.Advance(time.Minute)
+
+			if err := test.probe(t, repo, clk); err == nil {
+				t.Fatal("expected probe to fail")
+			}
+
+			if state := repo.BreakerState(); state != resilience.StateHalfOpen {
+				t.Fatalf("expected breaker to stay half-open, got: %s", state)
+			}
+
+			clk.Advance(time.Minute)
+
+			if _, err := repo.Get(context.Background(), &msisdn); err != nil || repo.BreakerState() != resilience.StateClosed {
+				t.Fatalf("expected next probe to close the breaker, got: %v, state: %s", err, repo.BreakerState())
+			}
+		})
+	}
+}
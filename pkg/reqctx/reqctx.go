@@ -0,0 +1,75 @@
+package reqctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type key int
+
+const (
+	requestIDKey key = iota
+	actorKey
+)
+
+// RequestIDHeader carrying the id of a request, passed on to the apis called while serving it
+const RequestIDHeader = "X-Request-ID"
+
+// MaxRequestIDLength of request ids accepted from clients
+const MaxRequestIDLength = 128
+
+// AnonymousActor used when no actor is present in the context
+const AnonymousActor = "anonymous"
+
+// NewRequestID generates a random request id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports if id provided by a client is safe to log and pass on: at most
+// MaxRequestIDLength letters, digits, '.', '_' and '-'
+func ValidRequestID(id string) bool {
+
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRequestID returns a copy of ctx carrying id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID carried by ctx, empty if none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithActor returns a copy of ctx carrying the actor performing changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor carried by ctx, AnonymousActor if none
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -0,0 +1,50 @@
+package resilience
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes jittered exponential delays between retries
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay before retry number attempt (starting at 0), a random duration up to Base * 2^attempt capped at Max
+func (b Backoff) Delay(attempt int) time.Duration {
+
+	if b.Base <= 0 {
+		return 0
+	}
+
+	d := b.Base
+	for i := 0; i < attempt && (b.Max <= 0 || d < b.Max); i++ {
+		d *= 2
+	}
+
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Sleep for d or until ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
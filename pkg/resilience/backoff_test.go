@@ -0,0 +1,39 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 100; i++ {
+			if d := b.Delay(attempt); d < 0 || d > max {
+				t.Fatalf("attempt %d: expected delay up to %s, got: %s", attempt, max, d)
+			}
+		}
+	}
+
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Fatalf("expected no delay without base, got: %s", d)
+	}
+}
+
+func TestSleep(t *testing.T) {
+
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected sleep to end with ctx, got: %v", err)
+	}
+}
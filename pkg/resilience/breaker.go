@@ -0,0 +1,140 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+// ErrCircuitOpen returned while a breaker is failing fast
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State of a circuit breaker
+type State string
+
+const (
+	// StateClosed lets all calls through
+	StateClosed State = "closed"
+	// StateOpen fails all calls fast until the cooldown has passed
+	StateOpen State = "open"
+	// StateHalfOpen lets a single probe call through
+	StateHalfOpen State = "half-open"
+)
+
+// Breaker opens after Threshold consecutive failures and probes again after Cooldown
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	clock     clock.Clock
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	sync.Mutex
+}
+
+// NewBreaker opening after threshold consecutive failures, 0 disables the breaker
+func NewBreaker(threshold int, cooldown time.Duration, clk clock.Clock) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     clk,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports if a call may be made, returning ErrCircuitOpen if not
+func (b *Breaker) Allow() error {
+
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success of a call closes the breaker
+func (b *Breaker) Success() {
+
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure of a call, opening the breaker once the threshold is reached or a probe fails
+func (b *Breaker) Failure() {
+
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// Release a call that was allowed but ended without telling if the callee works, like a call
+// given up by its caller or throttled, so a probe it was lets the next one through
+func (b *Breaker) Release() {
+
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.probing = false
+}
+
+// State of the breaker
+func (b *Breaker) State() State {
+
+	if b == nil || b.threshold <= 0 {
+		return StateClosed
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state == StateOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
@@ -0,0 +1,75 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+func TestBreaker(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	b := NewBreaker(2, time.Minute, clk)
+
+	b.Failure()
+	if err := b.Allow(); err != nil || b.State() != StateClosed {
+		t.Fatalf("expected closed breaker below threshold, got: %v, state: %s", err, b.State())
+	}
+
+	b.Failure()
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open breaker at threshold, got: %v", err)
+	}
+
+	clk.Advance(time.Minute)
+
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected half-open breaker after cooldown, got: %s", b.State())
+	}
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got: %v", err)
+	}
+
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a single probe at a time, got: %v", err)
+	}
+
+	// a released probe lets the next one through
+	b.Release()
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected probe after release, got: %v", err)
+	}
+
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("expected failed probe to open the breaker, got: %s", b.State())
+	}
+
+	clk.Advance(time.Minute)
+
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Success()
+	if err := b.Allow(); err != nil || b.State() != StateClosed {
+		t.Fatalf("expected successful probe to close the breaker, got: %v, state: %s", err, b.State())
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+
+	b := NewBreaker(0, time.Minute, clock.New())
+
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+
+	if err := b.Allow(); err != nil || b.State() != StateClosed {
+		t.Fatalf("expected disabled breaker to stay closed, got: %v, state: %s", err, b.State())
+	}
+}
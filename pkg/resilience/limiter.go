@@ -0,0 +1,78 @@
+package resilience
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+// Limiter is a token bucket refilled with rate tokens per second up to burst tokens
+type Limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  clock.Clock
+	sync.Mutex
+}
+
+// NewLimiter allowing rate calls per second with bursts of up to burst calls, a rate of 0 disables the limiter
+func NewLimiter(rate float64, burst int, clk clock.Clock) *Limiter {
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clk.Now(),
+		clock:  clk,
+	}
+}
+
+// Wait until a token is available or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+
+	for {
+		wait := l.reserve()
+		if wait == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(wait):
+		}
+	}
+}
+
+// reserve a token returning 0, or how long to wait before one is available
+func (l *Limiter) reserve() time.Duration {
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.clock.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
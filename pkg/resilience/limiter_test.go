@@ -0,0 +1,62 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+)
+
+func TestLimiter(t *testing.T) {
+
+	clk := clock.NewFake(time.Now())
+	l := NewLimiter(2, 2, clk)
+	ctx := context.Background()
+
+	// the burst is available at once
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- l.Wait(ctx) }()
+
+	clk.BlockUntil(1)
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected wait for a token, got: %v", err)
+	default:
+	}
+
+	clk.Advance(500 * time.Millisecond)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	go func() { done <- l.Wait(cancelled) }()
+
+	clk.BlockUntil(1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected wait to end with ctx, got: %v", err)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+
+	l := NewLimiter(0, 1, clock.NewFake(time.Now()))
+
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
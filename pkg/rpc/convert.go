@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/rgynn/subscription-api/pkg/rpc/subscriptionpb"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var statuses = map[subscription.Status]pb.Status{
+	subscription.StatusPending:   pb.Status_STATUS_PENDING,
+	subscription.StatusActivated: pb.Status_STATUS_ACTIVATED,
+	subscription.StatusPaused:    pb.Status_STATUS_PAUSED,
+	subscription.StatusCancelled: pb.Status_STATUS_CANCELLED,
+}
+
+var sorts = map[pb.Sort]subscription.Sort{
+	pb.Sort_SORT_UNSPECIFIED:      subscription.SortMSISDN,
+	pb.Sort_SORT_MSISDN:           subscription.SortMSISDN,
+	pb.Sort_SORT_MSISDN_DESC:      subscription.SortMSISDNDesc,
+	pb.Sort_SORT_ACTIVATE_AT:      subscription.SortActivateAt,
+	pb.Sort_SORT_ACTIVATE_AT_DESC: subscription.SortActivateAtDesc,
+}
+
+func fromStatus(s pb.Status) (*subscription.Status, error) {
+
+	if s == pb.Status_STATUS_UNSPECIFIED {
+		return nil, nil
+	}
+
+	for status, candidate := range statuses {
+		if candidate == s {
+			return &status, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown status %d: %w", s, ErrBadRequest)
+}
+
+// fromType PBX or CELL, nil if unspecified
+func fromType(t pb.Type) (*string, error) {
+
+	switch t {
+	case pb.Type_TYPE_UNSPECIFIED:
+		return nil, nil
+	case pb.Type_TYPE_PBX, pb.Type_TYPE_CELL:
+		name := strings.TrimPrefix(t.String(), "TYPE_")
+		return &name, nil
+	default:
+		return nil, fmt.Errorf("unknown type %d: %w", t, ErrBadRequest)
+	}
+}
+
+func toType(t *string) pb.Type {
+
+	if t == nil {
+		return pb.Type_TYPE_UNSPECIFIED
+	}
+
+	return pb.Type(pb.Type_value["TYPE_"+*t])
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+
+	if ts == nil {
+		return nil
+	}
+
+	t := ts.AsTime()
+
+	return &t
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
+
+func toSubscription(m *subscription.Model) *pb.Subscription {
+
+	result := &pb.Subscription{
+		Msisdn:            *m.MSISDN,
+		ActivateAt:        toTimestamp(m.ActivateAt),
+		Type:              toType(m.Type),
+		OperatorCheckedAt: toTimestamp(m.OperatorCheckedAt),
+		OperatorChangedAt: toTimestamp(m.OperatorChangedAt),
+	}
+
+	if m.Status != nil {
+		result.Status = statuses[*m.Status]
+	}
+
+	if m.Operator != nil {
+		result.Operator = *m.Operator
+	}
+
+	if m.Version != nil {
+		result.Version = *m.Version
+	}
+
+	return result
+}
+
+func fromListRequest(req *pb.ListSubscriptionsRequest) (*subscription.Query, error) {
+
+	sort, ok := sorts[req.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %d: %w", req.Sort, ErrBadRequest)
+	}
+
+	status, err := fromStatus(req.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := fromType(req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &subscription.Query{
+		Limit:                int(req.PageSize),
+		Status:               status,
+		Type:                 typ,
+		ActivateAfter:        fromTimestamp(req.ActivateAfter),
+		ActivateBefore:       fromTimestamp(req.ActivateBefore),
+		OperatorChangedSince: fromTimestamp(req.OperatorChangedSince),
+		Sort:                 sort,
+	}
+
+	if req.PageToken != "" {
+		q.Cursor = &req.PageToken
+	}
+
+	if req.Operator != "" {
+		q.Operator = &req.Operator
+	}
+
+	return q, nil
+}
+
+func toEvent(e subscription.DomainEvent) *pb.SubscriptionEvent {
+
+	meta := e.Metadata()
+
+	result := &pb.SubscriptionEvent{
+		Type:       string(e.Type()),
+		Msisdn:     meta.MSISDN,
+		OccurredAt: timestamppb.New(meta.OccurredAt),
+		Actor:      meta.Actor,
+		RequestId:  meta.RequestID,
+	}
+
+	switch e := e.(type) {
+	case *subscription.SubscriptionCreated:
+		result.Details = &pb.SubscriptionEvent_Created{Created: toSubscription(e.Subscription)}
+	case *subscription.ActivationDateChanged:
+		result.Details = &pb.SubscriptionEvent_ActivationDateChanged{ActivationDateChanged: &pb.ActivationDateChanged{
+			From: timestamppb.New(e.From),
+			To:   timestamppb.New(e.To),
+		}}
+	case *subscription.Cancelled:
+		result.Details = &pb.SubscriptionEvent_Cancelled{Cancelled: &pb.Cancelled{FromStatus: statuses[e.FromStatus]}}
+	case *subscription.OperatorChanged:
+		changed := &pb.OperatorChanged{}
+		if e.From != nil {
+			changed.From = *e.From
+		}
+		if e.To != nil {
+			changed.To = *e.To
+		}
+		result.Details = &pb.SubscriptionEvent_OperatorChanged{OperatorChanged: changed}
+	}
+
+	return result
+}
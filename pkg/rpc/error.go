@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/validate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrBadRequest returned if a request could not be converted to the domain model
+var ErrBadRequest = errors.New("request could not be parsed")
+
+// statusCodes checked in order with errors.Is, the first match decides the code
+var statusCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{ErrBadRequest, codes.InvalidArgument},
+	{auth.ErrUnauthenticated, codes.Unauthenticated},
+	{auth.ErrForbidden, codes.PermissionDenied},
+	{subscription.ErrNotValid, codes.InvalidArgument},
+	{subscription.ErrNotFound, codes.NotFound},
+	{subscription.ErrAlreadyExists, codes.AlreadyExists},
+	{subscription.ErrInvalidTransition, codes.FailedPrecondition},
+	{subscription.ErrVersionConflict, codes.Aborted},
+	{operator.ErrNotFound, codes.FailedPrecondition},
+	{operator.ErrUnavailable, codes.Unavailable},
+	{context.Canceled, codes.Canceled},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+}
+
+// newStatusError with the code decided by what err wraps, violations are attached as BadRequest details
+func newStatusError(err error) error {
+
+	code := codes.Internal
+	for _, candidate := range statusCodes {
+		if errors.Is(err, candidate.err) {
+			code = candidate.code
+			break
+		}
+	}
+
+	st := status.New(code, err.Error())
+
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return st.Err()
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, v := range invalid.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Message,
+		})
+	}
+
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
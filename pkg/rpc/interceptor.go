@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	pb "github.com/rgynn/subscription-api/pkg/rpc/subscriptionpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadata carrying the id of a call, generated if not provided by the client or not valid
+const RequestIDMetadata = "x-request-id"
+
+// ActorMetadata naming who performs a change, recorded in the subscription history
+const ActorMetadata = "x-actor"
+
+// APIKeyMetadata carrying the api key authenticating a call
+const APIKeyMetadata = "x-api-key"
+
+// AuthorizationMetadata carrying the bearer token authenticating a call
+const AuthorizationMetadata = "authorization"
+
+// roles required to call each method, methods not listed such as health checks and reflection are public
+var roles = map[string]auth.Role{
+	pb.SubscriptionService_ListSubscriptions_FullMethodName:    auth.RoleReader,
+	pb.SubscriptionService_GetSubscription_FullMethodName:      auth.RoleReader,
+	pb.SubscriptionService_WatchSubscriptions_FullMethodName:   auth.RoleReader,
+	pb.SubscriptionService_CreateSubscription_FullMethodName:   auth.RoleAgent,
+	pb.SubscriptionService_UpdateSubscription_FullMethodName:   auth.RoleAgent,
+	pb.SubscriptionService_TogglePaused_FullMethodName:         auth.RoleAgent,
+	pb.SubscriptionService_ActivateSubscription_FullMethodName: auth.RoleAdmin,
+	pb.SubscriptionService_CancelSubscription_FullMethodName:   auth.RoleAdmin,
+}
+
+// requestContext puts request id and actor of the call metadata in ctx, like the http api does with headers
+func requestContext(ctx context.Context) context.Context {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var id string
+	if values := md.Get(RequestIDMetadata); len(values) > 0 {
+		id = values[0]
+	}
+
+	if !reqctx.ValidRequestID(id) {
+		id = reqctx.NewRequestID()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id))
+
+	ctx = reqctx.WithRequestID(ctx, id)
+
+	if values := md.Get(ActorMetadata); len(values) > 0 && values[0] != "" {
+		ctx = reqctx.WithActor(ctx, values[0])
+	}
+
+	return ctx
+}
+
+// authenticate the api key or bearer token of the call like the http api does, rejecting it if the
+// principal does not have the role of the method. With authentication disabled every call is made by an admin.
+func (srv *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	ctx, err := srv.authn.Authenticate(ctx, auth.NewCredentials(first(APIKeyMetadata), first(AuthorizationMetadata)))
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	if role, ok := roles[method]; ok {
+		if err := auth.Require(ctx, role); err != nil {
+			return nil, newStatusError(err)
+		}
+	}
+
+	return ctx, nil
+}
+
+func (srv *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	ctx, err := srv.authenticate(requestContext(ctx), info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (srv *Server) streamInterceptor(impl interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	ctx, err := srv.authenticate(requestContext(ss.Context()), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(impl, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream overriding the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	pb "github.com/rgynn/subscription-api/pkg/rpc/subscriptionpb"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultWatchBuffer of events queued for a watcher before it is considered to have fallen behind
+const DefaultWatchBuffer = 256
+
+// Server of the subscription service over gRPC, with health and reflection services registered
+type Server struct {
+	pb.UnimplementedSubscriptionServiceServer
+	*grpc.Server
+	subscriptions subscription.Repository
+	events        subscription.EventBus
+	// authn of the api keys and bearer tokens of calls
+	authn  *auth.Authenticator
+	health *health.Server
+	// done is closed on shutdown to end watch streams, which would otherwise never finish
+	done    chan struct{}
+	closing sync.Once
+}
+
+// NewServer serving subscriptions, watchers are fed from events. Calls are authenticated
+// with authn, every call is made by an admin if it is disabled.
+func NewServer(subscriptions subscription.Repository, events subscription.EventBus, authn *auth.Authenticator) *Server {
+
+	srv := &Server{
+		subscriptions: subscriptions,
+		events:        events,
+		authn:         authn,
+		health:        health.NewServer(),
+		done:          make(chan struct{}),
+	}
+
+	srv.Server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(srv.unaryInterceptor),
+		grpc.ChainStreamInterceptor(srv.streamInterceptor),
+	)
+
+	pb.RegisterSubscriptionServiceServer(srv.Server, srv)
+	healthpb.RegisterHealthServer(srv.Server, srv.health)
+	reflection.Register(srv.Server)
+
+	srv.health.SetServingStatus(pb.SubscriptionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return srv
+}
+
+// ListenAndServe on addr until the server is shut down
+func (srv *Server) ListenAndServe(addr string) error {
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	return srv.Serve(lis)
+}
+
+// Shutdown server by reporting it as not serving, ending watch streams and waiting for
+// in flight calls until ctx is done. Calls still running then are cut off and ctx.Err() returned.
+func (srv *Server) Shutdown(ctx context.Context) error {
+
+	srv.health.Shutdown()
+	srv.closing.Do(func() { close(srv.done) })
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
+	}
+}
+
+func (srv *Server) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+
+	q, err := fromListRequest(req)
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	page, err := srv.subscriptions.List(ctx, q)
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	resp := &pb.ListSubscriptionsResponse{}
+	for _, m := range page.Subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, toSubscription(m))
+	}
+
+	if page.NextCursor != nil {
+		resp.NextPageToken = *page.NextCursor
+	}
+
+	return resp, nil
+}
+
+func (srv *Server) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	return srv.respond(srv.subscriptions.Get(ctx, &req.Msisdn))
+}
+
+func (srv *Server) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+
+	typ, err := fromType(req.Type)
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	return srv.respond(srv.subscriptions.Create(ctx, &subscription.Model{
+		MSISDN:     &req.Msisdn,
+		ActivateAt: fromTimestamp(req.ActivateAt),
+		Type:       typ,
+	}))
+}
+
+func (srv *Server) UpdateSubscription(ctx context.Context, req *pb.UpdateSubscriptionRequest) (*pb.Subscription, error) {
+
+	typ, err := fromType(req.Type)
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	return srv.respond(srv.subscriptions.Update(ctx, &subscription.Model{
+		MSISDN:     &req.Msisdn,
+		ActivateAt: fromTimestamp(req.ActivateAt),
+		Type:       typ,
+		Version:    req.Version,
+	}))
+}
+
+func (srv *Server) ActivateSubscription(ctx context.Context, req *pb.ActivateSubscriptionRequest) (*pb.Subscription, error) {
+	return srv.respond(srv.subscriptions.Activate(ctx, &req.Msisdn, time.Now().UTC()))
+}
+
+func (srv *Server) TogglePaused(ctx context.Context, req *pb.TogglePausedRequest) (*pb.Subscription, error) {
+	return srv.respond(srv.subscriptions.TogglePaused(ctx, &req.Msisdn, req.Version))
+}
+
+func (srv *Server) CancelSubscription(ctx context.Context, req *pb.CancelSubscriptionRequest) (*pb.Subscription, error) {
+	return srv.respond(srv.subscriptions.Cancel(ctx, &req.Msisdn, req.Version))
+}
+
+// respond with m, or the status err maps to
+func (srv *Server) respond(m *subscription.Model, err error) (*pb.Subscription, error) {
+
+	if err != nil {
+		return nil, newStatusError(err)
+	}
+
+	return toSubscription(m), nil
+}
+
+func (srv *Server) WatchSubscriptions(req *pb.WatchSubscriptionsRequest, stream pb.SubscriptionService_WatchSubscriptionsServer) error {
+
+	filter, err := newWatchFilter(req)
+	if err != nil {
+		return newStatusError(err)
+	}
+	filter.principal = auth.PrincipalFrom(stream.Context())
+
+	events := make(chan subscription.DomainEvent, DefaultWatchBuffer)
+	lagged := make(chan struct{})
+	var lagging sync.Once
+
+	// the handler never blocks, a slow watcher must not hold up the bus for everyone else
+	unsubscribe := srv.events.Subscribe("grpc-watch", func(ctx context.Context, e subscription.DomainEvent) {
+
+		if !filter.matches(e) {
+			return
+		}
+
+		select {
+		case events <- e:
+		default:
+			lagging.Do(func() { close(lagged) })
+		}
+	})
+	defer unsubscribe()
+
+	// headers tell the client it is subscribed, changes made after receiving them are streamed
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case e := <-events:
+			if err := stream.Send(toEvent(e)); err != nil {
+				return err
+			}
+		case <-lagged:
+			return status.Errorf(codes.ResourceExhausted, "watcher fell more than %d events behind", DefaultWatchBuffer)
+		case <-srv.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// watchFilter of a watcher, empty sets match everything the principal sees
+type watchFilter struct {
+	msisdns    map[string]bool
+	eventTypes map[subscription.EventType]bool
+	principal  *auth.Principal
+}
+
+func newWatchFilter(req *pb.WatchSubscriptionsRequest) (*watchFilter, error) {
+
+	filter := &watchFilter{
+		msisdns:    map[string]bool{},
+		eventTypes: map[subscription.EventType]bool{},
+	}
+
+	for _, msisdn := range req.Msisdns {
+		filter.msisdns[msisdn] = true
+	}
+
+	known := map[subscription.EventType]bool{}
+	for _, t := range subscription.EventTypes {
+		known[t] = true
+	}
+
+	for _, t := range req.EventTypes {
+		if !known[subscription.EventType(t)] {
+			return nil, fmt.Errorf("unknown event type %q: %w", t, ErrBadRequest)
+		}
+		filter.eventTypes[subscription.EventType(t)] = true
+	}
+
+	return filter, nil
+}
+
+func (f *watchFilter) matches(e subscription.DomainEvent) bool {
+
+	if !f.principal.Sees(e.Metadata().MSISDN) {
+		return false
+	}
+
+	if len(f.msisdns) > 0 && !f.msisdns[e.Metadata().MSISDN] {
+		return false
+	}
+
+	if len(f.eventTypes) > 0 && !f.eventTypes[e.Type()] {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,286 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/auth"
+	authmem "github.com/rgynn/subscription-api/pkg/auth/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	pb "github.com/rgynn/subscription-api/pkg/rpc/subscriptionpb"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/eventbus"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/mem"
+	"github.com/rgynn/subscription-api/pkg/subscription/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type stubOperators struct{}
+
+func (stubOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	name := "Tele2 Sverige AB"
+	return &name, nil
+}
+
+// newTestClient of a server with in memory storage, shut down when the test is done
+func newTestClient(t *testing.T, keys *auth.Keys) (*grpc.ClientConn, *Server) {
+	t.Helper()
+
+	subscriptions, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := mem.NewHistoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := eventbus.New()
+	srv := NewServer(service.NewService(subscriptions, history, stubOperators{}, events), events, &auth.Authenticator{Keys: keys})
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Shutdown(context.Background())
+		events.Close()
+	})
+
+	return conn, srv
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) *status.Status {
+	t.Helper()
+
+	st, _ := status.FromError(err)
+	if st.Code() != code {
+		t.Fatalf("expected %s, got: %v", code, err)
+	}
+
+	return st
+}
+
+func TestSubscriptionService(t *testing.T) {
+
+	conn, _ := newTestClient(t, nil)
+	client := pb.NewSubscriptionServiceClient(conn)
+	ctx := context.Background()
+
+	activateAt := timestamppb.New(time.Now().Add(-time.Hour))
+
+	created, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{Msisdn: "8-6785500", ActivateAt: activateAt, Type: pb.Type_TYPE_PBX})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Status != pb.Status_STATUS_ACTIVATED || created.Type != pb.Type_TYPE_PBX || created.Operator != "Tele2 Sverige AB" || created.Version != 1 {
+		t.Fatalf("unexpected subscription created: %v", created)
+	}
+
+	_, err = client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{Msisdn: "8-6785500", ActivateAt: activateAt, Type: pb.Type_TYPE_PBX})
+	expectCode(t, err, codes.AlreadyExists)
+
+	_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Msisdn: "8-404"})
+	expectCode(t, err, codes.NotFound)
+
+	_, err = client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{Msisdn: "8-1"})
+	st := expectCode(t, err, codes.InvalidArgument)
+
+	var fields []string
+	for _, detail := range st.Details() {
+		if bad, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range bad.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+
+	if len(fields) != 2 || fields[0] != "activate_at" || fields[1] != "type" {
+		t.Fatalf("expected violations of activate_at and type, got: %v", fields)
+	}
+
+	version := int64(7)
+	_, err = client.UpdateSubscription(ctx, &pb.UpdateSubscriptionRequest{Msisdn: "8-6785500", ActivateAt: activateAt, Type: pb.Type_TYPE_PBX, Version: &version})
+	expectCode(t, err, codes.Aborted)
+
+	_, err = client.TogglePaused(ctx, &pb.TogglePausedRequest{Msisdn: "8-6785500", Version: &version})
+	expectCode(t, err, codes.Aborted)
+
+	_, err = client.CancelSubscription(ctx, &pb.CancelSubscriptionRequest{Msisdn: "8-6785500", Version: &version})
+	expectCode(t, err, codes.Aborted)
+
+	version = 1
+	paused, err := client.TogglePaused(ctx, &pb.TogglePausedRequest{Msisdn: "8-6785500", Version: &version})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paused.Status != pb.Status_STATUS_PAUSED || paused.Version != 2 {
+		t.Fatalf("unexpected subscription paused: %v", paused)
+	}
+
+	_, err = client.ActivateSubscription(ctx, &pb.ActivateSubscriptionRequest{Msisdn: "8-6785500"})
+	expectCode(t, err, codes.FailedPrecondition)
+
+	page, err := client.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{Status: pb.Status_STATUS_PAUSED})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Subscriptions) != 1 || page.Subscriptions[0].Msisdn != "8-6785500" || page.NextPageToken != "" {
+		t.Fatalf("unexpected page: %v", page)
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: pb.SubscriptionService_ServiceDesc.ServiceName})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected serving, got: %s", health.Status)
+	}
+}
+
+func TestWatchSubscriptions(t *testing.T) {
+
+	conn, srv := newTestClient(t, nil)
+	client := pb.NewSubscriptionServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	unknown, err := client.WatchSubscriptions(ctx, &pb.WatchSubscriptionsRequest{EventTypes: []string{"subscription.unknown"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = unknown.Recv()
+	expectCode(t, err, codes.InvalidArgument)
+
+	stream, err := client.WatchSubscriptions(ctx, &pb.WatchSubscriptionsRequest{
+		Msisdns:    []string{"8-1"},
+		EventTypes: []string{string(subscription.EventSubscriptionCreated), string(subscription.EventCancelled)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the watch is subscribed once the server has sent its headers
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := metadata.AppendToOutgoingContext(ctx, ActorMetadata, "support")
+	for _, msisdn := range []string{"8-2", "8-1"} {
+		if _, err := client.CreateSubscription(writes, &pb.CreateSubscriptionRequest{Msisdn: msisdn, ActivateAt: timestamppb.Now(), Type: pb.Type_TYPE_CELL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := client.TogglePaused(writes, &pb.TogglePausedRequest{Msisdn: "8-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.CancelSubscription(writes, &pb.CancelSubscriptionRequest{Msisdn: "8-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Type != string(subscription.EventSubscriptionCreated) || created.Msisdn != "8-1" || created.Actor != "support" || created.GetCreated().GetType() != pb.Type_TYPE_CELL {
+		t.Fatalf("unexpected created event: %v", created)
+	}
+
+	cancelled, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cancelled.Type != string(subscription.EventCancelled) || cancelled.GetCancelled().GetFromStatus() != pb.Status_STATUS_PAUSED {
+		t.Fatalf("unexpected cancelled event: %v", cancelled)
+	}
+
+	// shutting down ends the watch instead of waiting for it forever
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stream.Recv()
+	expectCode(t, err, codes.Unavailable)
+}
+
+func TestAuthentication(t *testing.T) {
+
+	repo, err := authmem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := auth.NewKeys(repo, clock.New())
+
+	name, role := "dashboard", auth.RoleReader
+	reader, err := keys.Create(context.Background(), &auth.Key{Name: &name, Role: &role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := newTestClient(t, keys)
+	client := pb.NewSubscriptionServiceClient(conn)
+	ctx := context.Background()
+
+	_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Msisdn: "8-6785500"})
+	expectCode(t, err, codes.Unauthenticated)
+
+	ctx = metadata.AppendToOutgoingContext(ctx, APIKeyMetadata, *reader.Key)
+
+	_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Msisdn: "8-6785500"})
+	expectCode(t, err, codes.NotFound)
+
+	_, err = client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{Msisdn: "8-6785500", ActivateAt: timestamppb.Now(), Type: pb.Type_TYPE_PBX})
+	expectCode(t, err, codes.PermissionDenied)
+
+	// health checks are public
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+
+	tests := map[error]codes.Code{
+		fmt.Errorf("wrapped: %w", subscription.ErrNotFound): codes.NotFound,
+		fmt.Errorf("wrapped: %w", operator.ErrNotFound):     codes.FailedPrecondition,
+		fmt.Errorf("wrapped: %w", operator.ErrUnavailable):  codes.Unavailable,
+		errors.New("unexpected"):                            codes.Internal,
+	}
+
+	for err, code := range tests {
+		expectCode(t, newStatusError(err), code)
+	}
+}
@@ -0,0 +1,1355 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: subscription/v1/subscription.proto
+
+package subscriptionpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Status int32
+
+const (
+	Status_STATUS_UNSPECIFIED Status = 0
+	Status_STATUS_PENDING     Status = 1
+	Status_STATUS_ACTIVATED   Status = 2
+	Status_STATUS_PAUSED      Status = 3
+	Status_STATUS_CANCELLED   Status = 4
+)
+
+// Enum value maps for Status.
+var (
+	Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "STATUS_PENDING",
+		2: "STATUS_ACTIVATED",
+		3: "STATUS_PAUSED",
+		4: "STATUS_CANCELLED",
+	}
+	Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"STATUS_PENDING":     1,
+		"STATUS_ACTIVATED":   2,
+		"STATUS_PAUSED":      3,
+		"STATUS_CANCELLED":   4,
+	}
+)
+
+func (x Status) Enum() *Status {
+	p := new(Status)
+	*p = x
+	return p
+}
+
+func (x Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_subscription_v1_subscription_proto_enumTypes[0].Descriptor()
+}
+
+func (Status) Type() protoreflect.EnumType {
+	return &file_subscription_v1_subscription_proto_enumTypes[0]
+}
+
+func (x Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Status.Descriptor instead.
+func (Status) EnumDescriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{0}
+}
+
+type Type int32
+
+const (
+	Type_TYPE_UNSPECIFIED Type = 0
+	Type_TYPE_PBX         Type = 1
+	Type_TYPE_CELL        Type = 2
+)
+
+// Enum value maps for Type.
+var (
+	Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_PBX",
+		2: "TYPE_CELL",
+	}
+	Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_PBX":         1,
+		"TYPE_CELL":        2,
+	}
+)
+
+func (x Type) Enum() *Type {
+	p := new(Type)
+	*p = x
+	return p
+}
+
+func (x Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_subscription_v1_subscription_proto_enumTypes[1].Descriptor()
+}
+
+func (Type) Type() protoreflect.EnumType {
+	return &file_subscription_v1_subscription_proto_enumTypes[1]
+}
+
+func (x Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Type.Descriptor instead.
+func (Type) EnumDescriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{1}
+}
+
+type Sort int32
+
+const (
+	Sort_SORT_UNSPECIFIED      Sort = 0
+	Sort_SORT_MSISDN           Sort = 1
+	Sort_SORT_MSISDN_DESC      Sort = 2
+	Sort_SORT_ACTIVATE_AT      Sort = 3
+	Sort_SORT_ACTIVATE_AT_DESC Sort = 4
+)
+
+// Enum value maps for Sort.
+var (
+	Sort_name = map[int32]string{
+		0: "SORT_UNSPECIFIED",
+		1: "SORT_MSISDN",
+		2: "SORT_MSISDN_DESC",
+		3: "SORT_ACTIVATE_AT",
+		4: "SORT_ACTIVATE_AT_DESC",
+	}
+	Sort_value = map[string]int32{
+		"SORT_UNSPECIFIED":      0,
+		"SORT_MSISDN":           1,
+		"SORT_MSISDN_DESC":      2,
+		"SORT_ACTIVATE_AT":      3,
+		"SORT_ACTIVATE_AT_DESC": 4,
+	}
+)
+
+func (x Sort) Enum() *Sort {
+	p := new(Sort)
+	*p = x
+	return p
+}
+
+func (x Sort) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sort) Descriptor() protoreflect.EnumDescriptor {
+	return file_subscription_v1_subscription_proto_enumTypes[2].Descriptor()
+}
+
+func (Sort) Type() protoreflect.EnumType {
+	return &file_subscription_v1_subscription_proto_enumTypes[2]
+}
+
+func (x Sort) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sort.Descriptor instead.
+func (Sort) EnumDescriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{2}
+}
+
+type Subscription struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn     string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	ActivateAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=activate_at,json=activateAt,proto3" json:"activate_at,omitempty"`
+	Type       Type                   `protobuf:"varint,3,opt,name=type,proto3,enum=subscription.v1.Type" json:"type,omitempty"`
+	Status     Status                 `protobuf:"varint,4,opt,name=status,proto3,enum=subscription.v1.Status" json:"status,omitempty"`
+	// operator looked up from PTS when created and refreshed in the background, empty if unknown
+	Operator string `protobuf:"bytes,5,opt,name=operator,proto3" json:"operator,omitempty"`
+	// version incremented on every change
+	Version int64 `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	// operator_checked_at is when the operator was last verified against PTS, unset while it comes
+	// from the number plan fallback and was not verified yet
+	OperatorCheckedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=operator_checked_at,json=operatorCheckedAt,proto3" json:"operator_checked_at,omitempty"`
+	// operator_changed_at is when the operator was last found to have changed, e.g. the number was ported
+	OperatorChangedAt *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=operator_changed_at,json=operatorChangedAt,proto3" json:"operator_changed_at,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Subscription) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *Subscription) GetActivateAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivateAt
+	}
+	return nil
+}
+
+func (x *Subscription) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_TYPE_UNSPECIFIED
+}
+
+func (x *Subscription) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *Subscription) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *Subscription) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Subscription) GetOperatorCheckedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OperatorCheckedAt
+	}
+	return nil
+}
+
+func (x *Subscription) GetOperatorChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OperatorChangedAt
+	}
+	return nil
+}
+
+type ListSubscriptionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// page_size defaults to 100, at most 1000
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token is the next_page_token of the previous page
+	PageToken      string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	Status         Status                 `protobuf:"varint,3,opt,name=status,proto3,enum=subscription.v1.Status" json:"status,omitempty"`
+	Type           Type                   `protobuf:"varint,4,opt,name=type,proto3,enum=subscription.v1.Type" json:"type,omitempty"`
+	Operator       string                 `protobuf:"bytes,5,opt,name=operator,proto3" json:"operator,omitempty"`
+	ActivateAfter  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=activate_after,json=activateAfter,proto3" json:"activate_after,omitempty"`
+	ActivateBefore *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=activate_before,json=activateBefore,proto3" json:"activate_before,omitempty"`
+	Sort           Sort                   `protobuf:"varint,8,opt,name=sort,proto3,enum=subscription.v1.Sort" json:"sort,omitempty"`
+	// operator_changed_since matches subscriptions whose operator changed at or after it
+	OperatorChangedSince *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=operator_changed_since,json=operatorChangedSince,proto3" json:"operator_changed_since,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsRequest) Reset() {
+	*x = ListSubscriptionsRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListSubscriptionsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListSubscriptionsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListSubscriptionsRequest) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *ListSubscriptionsRequest) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_TYPE_UNSPECIFIED
+}
+
+func (x *ListSubscriptionsRequest) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *ListSubscriptionsRequest) GetActivateAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivateAfter
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsRequest) GetActivateBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivateBefore
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsRequest) GetSort() Sort {
+	if x != nil {
+		return x.Sort
+	}
+	return Sort_SORT_UNSPECIFIED
+}
+
+func (x *ListSubscriptionsRequest) GetOperatorChangedSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OperatorChangedSince
+	}
+	return nil
+}
+
+type ListSubscriptionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscriptions []*Subscription        `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	// next_page_token is empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsResponse) Reset() {
+	*x = ListSubscriptionsResponse{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsResponse) ProtoMessage() {}
+
+func (x *ListSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListSubscriptionsResponse) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+func (x *ListSubscriptionsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn        string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSubscriptionRequest) Reset() {
+	*x = GetSubscriptionRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSubscriptionRequest) ProtoMessage() {}
+
+func (x *GetSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*GetSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetSubscriptionRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+type CreateSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn        string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	ActivateAt    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=activate_at,json=activateAt,proto3" json:"activate_at,omitempty"`
+	Type          Type                   `protobuf:"varint,3,opt,name=type,proto3,enum=subscription.v1.Type" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSubscriptionRequest) Reset() {
+	*x = CreateSubscriptionRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSubscriptionRequest) ProtoMessage() {}
+
+func (x *CreateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateSubscriptionRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetActivateAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivateAt
+	}
+	return nil
+}
+
+func (x *CreateSubscriptionRequest) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_TYPE_UNSPECIFIED
+}
+
+type UpdateSubscriptionRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn     string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	ActivateAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=activate_at,json=activateAt,proto3" json:"activate_at,omitempty"`
+	Type       Type                   `protobuf:"varint,3,opt,name=type,proto3,enum=subscription.v1.Type" json:"type,omitempty"`
+	// version the update is based on, the update is rejected if the subscription was changed since
+	Version       *int64 `protobuf:"varint,4,opt,name=version,proto3,oneof" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateSubscriptionRequest) Reset() {
+	*x = UpdateSubscriptionRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSubscriptionRequest) ProtoMessage() {}
+
+func (x *UpdateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*UpdateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateSubscriptionRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *UpdateSubscriptionRequest) GetActivateAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivateAt
+	}
+	return nil
+}
+
+func (x *UpdateSubscriptionRequest) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_TYPE_UNSPECIFIED
+}
+
+func (x *UpdateSubscriptionRequest) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+type ActivateSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn        string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActivateSubscriptionRequest) Reset() {
+	*x = ActivateSubscriptionRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActivateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActivateSubscriptionRequest) ProtoMessage() {}
+
+func (x *ActivateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActivateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*ActivateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ActivateSubscriptionRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+type TogglePausedRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	// version the change is based on, it is rejected if the subscription was changed since
+	Version       *int64 `protobuf:"varint,2,opt,name=version,proto3,oneof" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TogglePausedRequest) Reset() {
+	*x = TogglePausedRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TogglePausedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TogglePausedRequest) ProtoMessage() {}
+
+func (x *TogglePausedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TogglePausedRequest.ProtoReflect.Descriptor instead.
+func (*TogglePausedRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TogglePausedRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *TogglePausedRequest) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+type CancelSubscriptionRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Msisdn string                 `protobuf:"bytes,1,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	// version the change is based on, it is rejected if the subscription was changed since
+	Version       *int64 `protobuf:"varint,2,opt,name=version,proto3,oneof" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelSubscriptionRequest) Reset() {
+	*x = CancelSubscriptionRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelSubscriptionRequest) ProtoMessage() {}
+
+func (x *CancelSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*CancelSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CancelSubscriptionRequest) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *CancelSubscriptionRequest) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+type WatchSubscriptionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// msisdns to watch, all if empty
+	Msisdns []string `protobuf:"bytes,1,rep,name=msisdns,proto3" json:"msisdns,omitempty"`
+	// event_types to watch, e.g. subscription.cancelled, all if empty
+	EventTypes    []string `protobuf:"bytes,2,rep,name=event_types,json=eventTypes,proto3" json:"event_types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchSubscriptionsRequest) Reset() {
+	*x = WatchSubscriptionsRequest{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchSubscriptionsRequest) ProtoMessage() {}
+
+func (x *WatchSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchSubscriptionsRequest) GetMsisdns() []string {
+	if x != nil {
+		return x.Msisdns
+	}
+	return nil
+}
+
+func (x *WatchSubscriptionsRequest) GetEventTypes() []string {
+	if x != nil {
+		return x.EventTypes
+	}
+	return nil
+}
+
+type SubscriptionEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// type of the event, e.g. subscription.created
+	Type       string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Msisdn     string                 `protobuf:"bytes,2,opt,name=msisdn,proto3" json:"msisdn,omitempty"`
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	Actor      string                 `protobuf:"bytes,4,opt,name=actor,proto3" json:"actor,omitempty"`
+	RequestId  string                 `protobuf:"bytes,5,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Types that are valid to be assigned to Details:
+	//
+	//	*SubscriptionEvent_Created
+	//	*SubscriptionEvent_ActivationDateChanged
+	//	*SubscriptionEvent_Cancelled
+	//	*SubscriptionEvent_OperatorChanged
+	Details       isSubscriptionEvent_Details `protobuf_oneof:"details"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscriptionEvent) Reset() {
+	*x = SubscriptionEvent{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscriptionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscriptionEvent) ProtoMessage() {}
+
+func (x *SubscriptionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscriptionEvent.ProtoReflect.Descriptor instead.
+func (*SubscriptionEvent) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SubscriptionEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SubscriptionEvent) GetMsisdn() string {
+	if x != nil {
+		return x.Msisdn
+	}
+	return ""
+}
+
+func (x *SubscriptionEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *SubscriptionEvent) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *SubscriptionEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SubscriptionEvent) GetDetails() isSubscriptionEvent_Details {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+func (x *SubscriptionEvent) GetCreated() *Subscription {
+	if x != nil {
+		if x, ok := x.Details.(*SubscriptionEvent_Created); ok {
+			return x.Created
+		}
+	}
+	return nil
+}
+
+func (x *SubscriptionEvent) GetActivationDateChanged() *ActivationDateChanged {
+	if x != nil {
+		if x, ok := x.Details.(*SubscriptionEvent_ActivationDateChanged); ok {
+			return x.ActivationDateChanged
+		}
+	}
+	return nil
+}
+
+func (x *SubscriptionEvent) GetCancelled() *Cancelled {
+	if x != nil {
+		if x, ok := x.Details.(*SubscriptionEvent_Cancelled); ok {
+			return x.Cancelled
+		}
+	}
+	return nil
+}
+
+func (x *SubscriptionEvent) GetOperatorChanged() *OperatorChanged {
+	if x != nil {
+		if x, ok := x.Details.(*SubscriptionEvent_OperatorChanged); ok {
+			return x.OperatorChanged
+		}
+	}
+	return nil
+}
+
+type isSubscriptionEvent_Details interface {
+	isSubscriptionEvent_Details()
+}
+
+type SubscriptionEvent_Created struct {
+	Created *Subscription `protobuf:"bytes,6,opt,name=created,proto3,oneof"`
+}
+
+type SubscriptionEvent_ActivationDateChanged struct {
+	ActivationDateChanged *ActivationDateChanged `protobuf:"bytes,7,opt,name=activation_date_changed,json=activationDateChanged,proto3,oneof"`
+}
+
+type SubscriptionEvent_Cancelled struct {
+	Cancelled *Cancelled `protobuf:"bytes,8,opt,name=cancelled,proto3,oneof"`
+}
+
+type SubscriptionEvent_OperatorChanged struct {
+	OperatorChanged *OperatorChanged `protobuf:"bytes,9,opt,name=operator_changed,json=operatorChanged,proto3,oneof"`
+}
+
+func (*SubscriptionEvent_Created) isSubscriptionEvent_Details() {}
+
+func (*SubscriptionEvent_ActivationDateChanged) isSubscriptionEvent_Details() {}
+
+func (*SubscriptionEvent_Cancelled) isSubscriptionEvent_Details() {}
+
+func (*SubscriptionEvent_OperatorChanged) isSubscriptionEvent_Details() {}
+
+type ActivationDateChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActivationDateChanged) Reset() {
+	*x = ActivationDateChanged{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActivationDateChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActivationDateChanged) ProtoMessage() {}
+
+func (x *ActivationDateChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActivationDateChanged.ProtoReflect.Descriptor instead.
+func (*ActivationDateChanged) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ActivationDateChanged) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ActivationDateChanged) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+type Cancelled struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromStatus    Status                 `protobuf:"varint,1,opt,name=from_status,json=fromStatus,proto3,enum=subscription.v1.Status" json:"from_status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Cancelled) Reset() {
+	*x = Cancelled{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cancelled) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cancelled) ProtoMessage() {}
+
+func (x *Cancelled) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cancelled.ProtoReflect.Descriptor instead.
+func (*Cancelled) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Cancelled) GetFromStatus() Status {
+	if x != nil {
+		return x.FromStatus
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+type OperatorChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OperatorChanged) Reset() {
+	*x = OperatorChanged{}
+	mi := &file_subscription_v1_subscription_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OperatorChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OperatorChanged) ProtoMessage() {}
+
+func (x *OperatorChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_subscription_v1_subscription_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OperatorChanged.ProtoReflect.Descriptor instead.
+func (*OperatorChanged) Descriptor() ([]byte, []int) {
+	return file_subscription_v1_subscription_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *OperatorChanged) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *OperatorChanged) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+var File_subscription_v1_subscription_proto protoreflect.FileDescriptor
+
+const file_subscription_v1_subscription_proto_rawDesc = "" +
+	"\n" +
+	"\"subscription/v1/subscription.proto\x12\x0fsubscription.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8d\x03\n" +
+	"\fSubscription\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\x12;\n" +
+	"\vactivate_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"activateAt\x12)\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x15.subscription.v1.TypeR\x04type\x12/\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x17.subscription.v1.StatusR\x06status\x12\x1a\n" +
+	"\boperator\x18\x05 \x01(\tR\boperator\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x03R\aversion\x12J\n" +
+	"\x13operator_checked_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x11operatorCheckedAt\x12J\n" +
+	"\x13operator_changed_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x11operatorChangedAt\"\xd3\x03\n" +
+	"\x18ListSubscriptionsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12/\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x17.subscription.v1.StatusR\x06status\x12)\n" +
+	"\x04type\x18\x04 \x01(\x0e2\x15.subscription.v1.TypeR\x04type\x12\x1a\n" +
+	"\boperator\x18\x05 \x01(\tR\boperator\x12A\n" +
+	"\x0eactivate_after\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\ractivateAfter\x12C\n" +
+	"\x0factivate_before\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x0eactivateBefore\x12)\n" +
+	"\x04sort\x18\b \x01(\x0e2\x15.subscription.v1.SortR\x04sort\x12P\n" +
+	"\x16operator_changed_since\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\x14operatorChangedSince\"\x88\x01\n" +
+	"\x19ListSubscriptionsResponse\x12C\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x1d.subscription.v1.SubscriptionR\rsubscriptions\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"0\n" +
+	"\x16GetSubscriptionRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\"\x9b\x01\n" +
+	"\x19CreateSubscriptionRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\x12;\n" +
+	"\vactivate_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"activateAt\x12)\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x15.subscription.v1.TypeR\x04type\"\xc6\x01\n" +
+	"\x19UpdateSubscriptionRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\x12;\n" +
+	"\vactivate_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"activateAt\x12)\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x15.subscription.v1.TypeR\x04type\x12\x1d\n" +
+	"\aversion\x18\x04 \x01(\x03H\x00R\aversion\x88\x01\x01B\n" +
+	"\n" +
+	"\b_version\"5\n" +
+	"\x1bActivateSubscriptionRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\"X\n" +
+	"\x13TogglePausedRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\x12\x1d\n" +
+	"\aversion\x18\x02 \x01(\x03H\x00R\aversion\x88\x01\x01B\n" +
+	"\n" +
+	"\b_version\"^\n" +
+	"\x19CancelSubscriptionRequest\x12\x16\n" +
+	"\x06msisdn\x18\x01 \x01(\tR\x06msisdn\x12\x1d\n" +
+	"\aversion\x18\x02 \x01(\x03H\x00R\aversion\x88\x01\x01B\n" +
+	"\n" +
+	"\b_version\"V\n" +
+	"\x19WatchSubscriptionsRequest\x12\x18\n" +
+	"\amsisdns\x18\x01 \x03(\tR\amsisdns\x12\x1f\n" +
+	"\vevent_types\x18\x02 \x03(\tR\n" +
+	"eventTypes\"\xe4\x03\n" +
+	"\x11SubscriptionEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x16\n" +
+	"\x06msisdn\x18\x02 \x01(\tR\x06msisdn\x12;\n" +
+	"\voccurred_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12\x14\n" +
+	"\x05actor\x18\x04 \x01(\tR\x05actor\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x05 \x01(\tR\trequestId\x129\n" +
+	"\acreated\x18\x06 \x01(\v2\x1d.subscription.v1.SubscriptionH\x00R\acreated\x12`\n" +
+	"\x17activation_date_changed\x18\a \x01(\v2&.subscription.v1.ActivationDateChangedH\x00R\x15activationDateChanged\x12:\n" +
+	"\tcancelled\x18\b \x01(\v2\x1a.subscription.v1.CancelledH\x00R\tcancelled\x12M\n" +
+	"\x10operator_changed\x18\t \x01(\v2 .subscription.v1.OperatorChangedH\x00R\x0foperatorChangedB\t\n" +
+	"\adetails\"s\n" +
+	"\x15ActivationDateChanged\x12.\n" +
+	"\x04from\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\"E\n" +
+	"\tCancelled\x128\n" +
+	"\vfrom_status\x18\x01 \x01(\x0e2\x17.subscription.v1.StatusR\n" +
+	"fromStatus\"5\n" +
+	"\x0fOperatorChanged\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to*s\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSTATUS_PENDING\x10\x01\x12\x14\n" +
+	"\x10STATUS_ACTIVATED\x10\x02\x12\x11\n" +
+	"\rSTATUS_PAUSED\x10\x03\x12\x14\n" +
+	"\x10STATUS_CANCELLED\x10\x04*9\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\f\n" +
+	"\bTYPE_PBX\x10\x01\x12\r\n" +
+	"\tTYPE_CELL\x10\x02*t\n" +
+	"\x04Sort\x12\x14\n" +
+	"\x10SORT_UNSPECIFIED\x10\x00\x12\x0f\n" +
+	"\vSORT_MSISDN\x10\x01\x12\x14\n" +
+	"\x10SORT_MSISDN_DESC\x10\x02\x12\x14\n" +
+	"\x10SORT_ACTIVATE_AT\x10\x03\x12\x19\n" +
+	"\x15SORT_ACTIVATE_AT_DESC\x10\x042\xa1\x06\n" +
+	"\x13SubscriptionService\x12j\n" +
+	"\x11ListSubscriptions\x12).subscription.v1.ListSubscriptionsRequest\x1a*.subscription.v1.ListSubscriptionsResponse\x12Y\n" +
+	"\x0fGetSubscription\x12'.subscription.v1.GetSubscriptionRequest\x1a\x1d.subscription.v1.Subscription\x12_\n" +
+	"\x12CreateSubscription\x12*.subscription.v1.CreateSubscriptionRequest\x1a\x1d.subscription.v1.Subscription\x12_\n" +
+	"\x12UpdateSubscription\x12*.subscription.v1.UpdateSubscriptionRequest\x1a\x1d.subscription.v1.Subscription\x12c\n" +
+	"\x14ActivateSubscription\x12,.subscription.v1.ActivateSubscriptionRequest\x1a\x1d.subscription.v1.Subscription\x12S\n" +
+	"\fTogglePaused\x12$.subscription.v1.TogglePausedRequest\x1a\x1d.subscription.v1.Subscription\x12_\n" +
+	"\x12CancelSubscription\x12*.subscription.v1.CancelSubscriptionRequest\x1a\x1d.subscription.v1.Subscription\x12f\n" +
+	"\x12WatchSubscriptions\x12*.subscription.v1.WatchSubscriptionsRequest\x1a\".subscription.v1.SubscriptionEvent0\x01B:Z8github.com/rgynn/subscription-api/pkg/rpc/subscriptionpbb\x06proto3"
+
+var (
+	file_subscription_v1_subscription_proto_rawDescOnce sync.Once
+	file_subscription_v1_subscription_proto_rawDescData []byte
+)
+
+func file_subscription_v1_subscription_proto_rawDescGZIP() []byte {
+	file_subscription_v1_subscription_proto_rawDescOnce.Do(func() {
+		file_subscription_v1_subscription_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_subscription_v1_subscription_proto_rawDesc), len(file_subscription_v1_subscription_proto_rawDesc)))
+	})
+	return file_subscription_v1_subscription_proto_rawDescData
+}
+
+var file_subscription_v1_subscription_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_subscription_v1_subscription_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_subscription_v1_subscription_proto_goTypes = []any{
+	(Status)(0),                         // 0: subscription.v1.Status
+	(Type)(0),                           // 1: subscription.v1.Type
+	(Sort)(0),                           // 2: subscription.v1.Sort
+	(*Subscription)(nil),                // 3: subscription.v1.Subscription
+	(*ListSubscriptionsRequest)(nil),    // 4: subscription.v1.ListSubscriptionsRequest
+	(*ListSubscriptionsResponse)(nil),   // 5: subscription.v1.ListSubscriptionsResponse
+	(*GetSubscriptionRequest)(nil),      // 6: subscription.v1.GetSubscriptionRequest
+	(*CreateSubscriptionRequest)(nil),   // 7: subscription.v1.CreateSubscriptionRequest
+	(*UpdateSubscriptionRequest)(nil),   // 8: subscription.v1.UpdateSubscriptionRequest
+	(*ActivateSubscriptionRequest)(nil), // 9: subscription.v1.ActivateSubscriptionRequest
+	(*TogglePausedRequest)(nil),         // 10: subscription.v1.TogglePausedRequest
+	(*CancelSubscriptionRequest)(nil),   // 11: subscription.v1.CancelSubscriptionRequest
+	(*WatchSubscriptionsRequest)(nil),   // 12: subscription.v1.WatchSubscriptionsRequest
+	(*SubscriptionEvent)(nil),           // 13: subscription.v1.SubscriptionEvent
+	(*ActivationDateChanged)(nil),       // 14: subscription.v1.ActivationDateChanged
+	(*Cancelled)(nil),                   // 15: subscription.v1.Cancelled
+	(*OperatorChanged)(nil),             // 16: subscription.v1.OperatorChanged
+	(*timestamppb.Timestamp)(nil),       // 17: google.protobuf.Timestamp
+}
+var file_subscription_v1_subscription_proto_depIdxs = []int32{
+	17, // 0: subscription.v1.Subscription.activate_at:type_name -> google.protobuf.Timestamp
+	1,  // 1: subscription.v1.Subscription.type:type_name -> subscription.v1.Type
+	0,  // 2: subscription.v1.Subscription.status:type_name -> subscription.v1.Status
+	17, // 3: subscription.v1.Subscription.operator_checked_at:type_name -> google.protobuf.Timestamp
+	17, // 4: subscription.v1.Subscription.operator_changed_at:type_name -> google.protobuf.Timestamp
+	0,  // 5: subscription.v1.ListSubscriptionsRequest.status:type_name -> subscription.v1.Status
+	1,  // 6: subscription.v1.ListSubscriptionsRequest.type:type_name -> subscription.v1.Type
+	17, // 7: subscription.v1.ListSubscriptionsRequest.activate_after:type_name -> google.protobuf.Timestamp
+	17, // 8: subscription.v1.ListSubscriptionsRequest.activate_before:type_name -> google.protobuf.Timestamp
+	2,  // 9: subscription.v1.ListSubscriptionsRequest.sort:type_name -> subscription.v1.Sort
+	17, // 10: subscription.v1.ListSubscriptionsRequest.operator_changed_since:type_name -> google.protobuf.Timestamp
+	3,  // 11: subscription.v1.ListSubscriptionsResponse.subscriptions:type_name -> subscription.v1.Subscription
+	17, // 12: subscription.v1.CreateSubscriptionRequest.activate_at:type_name -> google.protobuf.Timestamp
+	1,  // 13: subscription.v1.CreateSubscriptionRequest.type:type_name -> subscription.v1.Type
+	17, // 14: subscription.v1.UpdateSubscriptionRequest.activate_at:type_name -> google.protobuf.Timestamp
+	1,  // 15: subscription.v1.UpdateSubscriptionRequest.type:type_name -> subscription.v1.Type
+	17, // 16: subscription.v1.SubscriptionEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	3,  // 17: subscription.v1.SubscriptionEvent.created:type_name -> subscription.v1.Subscription
+	14, // 18: subscription.v1.SubscriptionEvent.activation_date_changed:type_name -> subscription.v1.ActivationDateChanged
+	15, // 19: subscription.v1.SubscriptionEvent.cancelled:type_name -> subscription.v1.Cancelled
+	16, // 20: subscription.v1.SubscriptionEvent.operator_changed:type_name -> subscription.v1.OperatorChanged
+	17, // 21: subscription.v1.ActivationDateChanged.from:type_name -> google.protobuf.Timestamp
+	17, // 22: subscription.v1.ActivationDateChanged.to:type_name -> google.protobuf.Timestamp
+	0,  // 23: subscription.v1.Cancelled.from_status:type_name -> subscription.v1.Status
+	4,  // 24: subscription.v1.SubscriptionService.ListSubscriptions:input_type -> subscription.v1.ListSubscriptionsRequest
+	6,  // 25: subscription.v1.SubscriptionService.GetSubscription:input_type -> subscription.v1.GetSubscriptionRequest
+	7,  // 26: subscription.v1.SubscriptionService.CreateSubscription:input_type -> subscription.v1.CreateSubscriptionRequest
+	8,  // 27: subscription.v1.SubscriptionService.UpdateSubscription:input_type -> subscription.v1.UpdateSubscriptionRequest
+	9,  // 28: subscription.v1.SubscriptionService.ActivateSubscription:input_type -> subscription.v1.ActivateSubscriptionRequest
+	10, // 29: subscription.v1.SubscriptionService.TogglePaused:input_type -> subscription.v1.TogglePausedRequest
+	11, // 30: subscription.v1.SubscriptionService.CancelSubscription:input_type -> subscription.v1.CancelSubscriptionRequest
+	12, // 31: subscription.v1.SubscriptionService.WatchSubscriptions:input_type -> subscription.v1.WatchSubscriptionsRequest
+	5,  // 32: subscription.v1.SubscriptionService.ListSubscriptions:output_type -> subscription.v1.ListSubscriptionsResponse
+	3,  // 33: subscription.v1.SubscriptionService.GetSubscription:output_type -> subscription.v1.Subscription
+	3,  // 34: subscription.v1.SubscriptionService.CreateSubscription:output_type -> subscription.v1.Subscription
+	3,  // 35: subscription.v1.SubscriptionService.UpdateSubscription:output_type -> subscription.v1.Subscription
+	3,  // 36: subscription.v1.SubscriptionService.ActivateSubscription:output_type -> subscription.v1.Subscription
+	3,  // 37: subscription.v1.SubscriptionService.TogglePaused:output_type -> subscription.v1.Subscription
+	3,  // 38: subscription.v1.SubscriptionService.CancelSubscription:output_type -> subscription.v1.Subscription
+	13, // 39: subscription.v1.SubscriptionService.WatchSubscriptions:output_type -> subscription.v1.SubscriptionEvent
+	32, // [32:40] is the sub-list for method output_type
+	24, // [24:32] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
+}
+
+func init() { file_subscription_v1_subscription_proto_init() }
+func file_subscription_v1_subscription_proto_init() {
+	if File_subscription_v1_subscription_proto != nil {
+		return
+	}
+	file_subscription_v1_subscription_proto_msgTypes[5].OneofWrappers = []any{}
+	file_subscription_v1_subscription_proto_msgTypes[7].OneofWrappers = []any{}
+	file_subscription_v1_subscription_proto_msgTypes[8].OneofWrappers = []any{}
+	file_subscription_v1_subscription_proto_msgTypes[10].OneofWrappers = []any{
+		(*SubscriptionEvent_Created)(nil),
+		(*SubscriptionEvent_ActivationDateChanged)(nil),
+		(*SubscriptionEvent_Cancelled)(nil),
+		(*SubscriptionEvent_OperatorChanged)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_subscription_v1_subscription_proto_rawDesc), len(file_subscription_v1_subscription_proto_rawDesc)),
+			NumEnums:      3,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_subscription_v1_subscription_proto_goTypes,
+		DependencyIndexes: file_subscription_v1_subscription_proto_depIdxs,
+		EnumInfos:         file_subscription_v1_subscription_proto_enumTypes,
+		MessageInfos:      file_subscription_v1_subscription_proto_msgTypes,
+	}.Build()
+	File_subscription_v1_subscription_proto = out.File
+	file_subscription_v1_subscription_proto_goTypes = nil
+	file_subscription_v1_subscription_proto_depIdxs = nil
+}
@@ -0,0 +1,124 @@
+package subscription
+
+import (
+	"context"
+	"time"
+)
+
+// EventType of a domain event
+type EventType string
+
+const (
+	// EventSubscriptionCreated when a subscription is created
+	EventSubscriptionCreated EventType = "subscription.created"
+	// EventActivationDateChanged when activate_at of a pending subscription is changed
+	EventActivationDateChanged EventType = "subscription.activation_date_changed"
+	// EventActivated when a pending subscription is activated
+	EventActivated EventType = "subscription.activated"
+	// EventPaused when a subscription is paused
+	EventPaused EventType = "subscription.paused"
+	// EventResumed when a paused subscription is activated again
+	EventResumed EventType = "subscription.resumed"
+	// EventCancelled when a subscription is cancelled
+	EventCancelled EventType = "subscription.cancelled"
+	// EventOperatorChanged when the operator of a subscription changes, e.g. when a number is ported
+	EventOperatorChanged EventType = "subscription.operator_changed"
+)
+
+// EventTypes lists every event type published by the service
+var EventTypes = []EventType{
+	EventSubscriptionCreated,
+	EventActivationDateChanged,
+	EventActivated,
+	EventPaused,
+	EventResumed,
+	EventCancelled,
+	EventOperatorChanged,
+}
+
+// DomainEvent published when a subscription changes
+type DomainEvent interface {
+	Type() EventType
+	Metadata() *EventMeta
+}
+
+// EventMeta common to all domain events
+type EventMeta struct {
+	MSISDN     string    `json:"msisdn"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Actor      string    `json:"actor"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// Metadata of the event
+func (m *EventMeta) Metadata() *EventMeta {
+	return m
+}
+
+// SubscriptionCreated event
+type SubscriptionCreated struct {
+	EventMeta
+	Subscription *Model `json:"subscription"`
+}
+
+// ActivationDateChanged event
+type ActivationDateChanged struct {
+	EventMeta
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Activated event
+type Activated struct {
+	EventMeta
+}
+
+// Paused event
+type Paused struct {
+	EventMeta
+}
+
+// Resumed event
+type Resumed struct {
+	EventMeta
+}
+
+// Cancelled event
+type Cancelled struct {
+	EventMeta
+	FromStatus Status `json:"from_status"`
+}
+
+// OperatorChanged event
+type OperatorChanged struct {
+	EventMeta
+	From *string `json:"from"`
+	To   *string `json:"to"`
+}
+
+func (*SubscriptionCreated) Type() EventType   { return EventSubscriptionCreated }
+func (*ActivationDateChanged) Type() EventType { return EventActivationDateChanged }
+func (*Activated) Type() EventType             { return EventActivated }
+func (*Paused) Type() EventType                { return EventPaused }
+func (*Resumed) Type() EventType               { return EventResumed }
+func (*Cancelled) Type() EventType             { return EventCancelled }
+func (*OperatorChanged) Type() EventType       { return EventOperatorChanged }
+
+// EventHandler receives published events
+type EventHandler func(ctx context.Context, e DomainEvent)
+
+// Outbox storing what is to be delivered of events in the transaction of the change they describe,
+// so they are delivered even if the process stops before they are published
+type Outbox interface {
+	Enqueue(ctx context.Context, events ...DomainEvent) error
+}
+
+// EventBus interface for publishing domain events to subscribers
+type EventBus interface {
+	// Publish e to all subscribers, events for the same msisdn are delivered in the order published
+	Publish(ctx context.Context, e DomainEvent) error
+	// Subscribe handler to all events, returning a function that removes the subscription
+	Subscribe(name string, handler EventHandler) (unsubscribe func())
+	// Close bus after delivering already published events
+	Close() error
+}
@@ -0,0 +1,164 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// ErrClosed returned when publishing to a closed bus
+var ErrClosed = errors.New("event bus is closed")
+
+// DefaultShards of each subscriber, events for one msisdn always go to the same shard
+const DefaultShards = 8
+
+// DefaultQueueSize of each shard before Publish blocks
+const DefaultQueueSize = 256
+
+type delivery struct {
+	ctx   context.Context
+	event subscription.DomainEvent
+}
+
+type subscriber struct {
+	name    string
+	handler subscription.EventHandler
+	shards  []chan delivery
+	wg      sync.WaitGroup
+}
+
+func (s *subscriber) run(shard chan delivery) {
+	defer s.wg.Done()
+	for d := range shard {
+		s.deliver(d)
+	}
+}
+
+func (s *subscriber) deliver(d delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(d.ctx, "event handler panicked", "handler", s.name, "event", d.event.Type(), "panic", r)
+		}
+	}()
+	s.handler(d.ctx, d.event)
+}
+
+func (s *subscriber) close() {
+	for _, shard := range s.shards {
+		close(shard)
+	}
+	s.wg.Wait()
+}
+
+// Bus fanning out events in process to every subscriber
+type Bus struct {
+	shards      int
+	queueSize   int
+	subscribers map[int]*subscriber
+	next        int
+	closed      bool
+	sync.RWMutex
+}
+
+// New in process bus
+func New() *Bus {
+	return NewWithSize(DefaultShards, DefaultQueueSize)
+}
+
+// NewWithSize of shards per subscriber and queue size per shard
+func NewWithSize(shards, queueSize int) *Bus {
+
+	if shards < 1 {
+		shards = 1
+	}
+
+	return &Bus{
+		shards:      shards,
+		queueSize:   queueSize,
+		subscribers: map[int]*subscriber{},
+	}
+}
+
+func (bus *Bus) Subscribe(name string, handler subscription.EventHandler) func() {
+
+	s := &subscriber{name: name, handler: handler}
+
+	for i := 0; i < bus.shards; i++ {
+		shard := make(chan delivery, bus.queueSize)
+		s.shards = append(s.shards, shard)
+		s.wg.Add(1)
+		go s.run(shard)
+	}
+
+	bus.Lock()
+	id := bus.next
+	bus.next++
+	bus.subscribers[id] = s
+	bus.Unlock()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			bus.Lock()
+			_, ok := bus.subscribers[id]
+			delete(bus.subscribers, id)
+			bus.Unlock()
+			if ok {
+				s.close()
+			}
+		})
+	}
+}
+
+func (bus *Bus) Publish(ctx context.Context, e subscription.DomainEvent) error {
+
+	if e == nil {
+		return errors.New("no event provided")
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.Metadata().MSISDN))
+	i := int(h.Sum32() % uint32(bus.shards))
+
+	// handlers run after the request is done, keep its values but not its deadline
+	d := delivery{ctx: context.WithoutCancel(ctx), event: e}
+
+	bus.RLock()
+	defer bus.RUnlock()
+
+	if bus.closed {
+		return ErrClosed
+	}
+
+	// published changes are committed, so waiting for room is better than dropping the event
+	for _, s := range bus.subscribers {
+		s.shards[i] <- d
+	}
+
+	return nil
+}
+
+// Close bus, waiting for subscribers to handle already published events
+func (bus *Bus) Close() error {
+
+	bus.Lock()
+	if bus.closed {
+		bus.Unlock()
+		return nil
+	}
+	bus.closed = true
+	subscribers := bus.subscribers
+	bus.subscribers = map[int]*subscriber{}
+	bus.Unlock()
+
+	for _, s := range subscribers {
+		s.close()
+	}
+
+	return nil
+}
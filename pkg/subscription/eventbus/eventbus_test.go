@@ -0,0 +1,82 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+func TestPublish(t *testing.T) {
+
+	ctx := context.Background()
+	bus := NewWithSize(4, 1)
+
+	var (
+		mu       sync.Mutex
+		received = map[string]map[string][]time.Time{}
+	)
+
+	for _, name := range []string{"webhooks", "metrics"} {
+		name := name
+		received[name] = map[string][]time.Time{}
+		bus.Subscribe(name, func(ctx context.Context, e subscription.DomainEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			meta := e.Metadata()
+			received[name][meta.MSISDN] = append(received[name][meta.MSISDN], meta.OccurredAt)
+		})
+	}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		meta := subscription.EventMeta{MSISDN: fmt.Sprintf("8-%d", i%5), OccurredAt: start.Add(time.Duration(i))}
+		if err := bus.Publish(ctx, &subscription.Paused{EventMeta: meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Publish(ctx, &subscription.Paused{}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got: %v", err)
+	}
+
+	for name, byMSISDN := range received {
+		for msisdn, times := range byMSISDN {
+			if len(times) != 20 {
+				t.Fatalf("expected %s to receive 20 events for %s, got: %d", name, msisdn, len(times))
+			}
+			for i := 1; i < len(times); i++ {
+				if times[i].Before(times[i-1]) {
+					t.Fatalf("expected %s to receive events for %s in order", name, msisdn)
+				}
+			}
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+
+	ctx := context.Background()
+	bus := New()
+	defer bus.Close()
+
+	calls := make(chan struct{}, 10)
+	unsubscribe := bus.Subscribe("test", func(ctx context.Context, e subscription.DomainEvent) {
+		calls <- struct{}{}
+	})
+
+	bus.Publish(ctx, &subscription.Resumed{})
+	unsubscribe()
+	bus.Publish(ctx, &subscription.Resumed{})
+
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call before unsubscribing, got: %d", len(calls))
+	}
+}
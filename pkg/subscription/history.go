@@ -0,0 +1,100 @@
+package subscription
+
+import (
+	"context"
+	"time"
+)
+
+// Action recorded in the history of a subscription
+type Action string
+
+const (
+	// ActionCreated when a subscription is created
+	ActionCreated Action = "created"
+	// ActionUpdated when activate_at or type of a subscription is changed
+	ActionUpdated Action = "updated"
+	// ActionActivated when a pending subscription is activated
+	ActionActivated Action = "activated"
+	// ActionPaused when a subscription is paused
+	ActionPaused Action = "paused"
+	// ActionResumed when a paused subscription is activated again
+	ActionResumed Action = "resumed"
+	// ActionCancelled when a subscription is cancelled
+	ActionCancelled Action = "cancelled"
+	// ActionOperatorChanged when a refresh finds the operator of a subscription changed
+	ActionOperatorChanged Action = "operator_changed"
+)
+
+// Change of a single field of a subscription
+type Change struct {
+	Field string  `json:"field"`
+	From  *string `json:"from"`
+	To    *string `json:"to"`
+}
+
+// HistoryEntry is an immutable record of a change to a subscription
+type HistoryEntry struct {
+	// Sequence of the entry in the history of its msisdn, starting at 1
+	Sequence   int64     `json:"sequence"`
+	MSISDN     string    `json:"msisdn"`
+	Action     Action    `json:"action"`
+	FromStatus *Status   `json:"from_status"`
+	ToStatus   *Status   `json:"to_status"`
+	Changes    []Change  `json:"changes"`
+	Timestamp  time.Time `json:"timestamp"`
+	Actor      string    `json:"actor"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// HistoryPage of entries, NextCursor is set if there are more entries
+type HistoryPage struct {
+	Entries    []*HistoryEntry `json:"entries"`
+	NextCursor *string         `json:"next_cursor,omitempty"`
+}
+
+// HistoryReader for subscription history
+type HistoryReader interface {
+	History(ctx context.Context, msisdn *string, cursor *string, limit int) (*HistoryPage, error)
+}
+
+// HistoryRepository interface for subscription history
+type HistoryRepository interface {
+	HistoryReader
+	Append(ctx context.Context, e *HistoryEntry) error
+}
+
+// Diff lists the fields that differ between before and after
+func Diff(before, after *Model) []Change {
+
+	var changes []Change
+
+	field := func(name string, from, to *string) {
+		if from == nil && to == nil || from != nil && to != nil && *from == *to {
+			return
+		}
+		changes = append(changes, Change{Field: name, From: from, To: to})
+	}
+
+	if before == nil {
+		before = &Model{}
+	}
+
+	if after == nil {
+		after = &Model{}
+	}
+
+	field("activate_at", formatTime(before.ActivateAt), formatTime(after.ActivateAt))
+	field("type", before.Type, after.Type)
+	field("status", (*string)(before.Status), (*string)(after.Status))
+	field("operator", before.Operator, after.Operator)
+
+	return changes
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
@@ -0,0 +1,219 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultLimit of subscriptions returned per page
+const DefaultLimit = 100
+
+// MaxLimit of subscriptions a client may request per page
+const MaxLimit = 1000
+
+// Sort order of listed subscriptions, ties are broken by msisdn
+type Sort string
+
+const (
+	// SortMSISDN ascending
+	SortMSISDN Sort = "msisdn"
+	// SortMSISDNDesc descending
+	SortMSISDNDesc Sort = "-msisdn"
+	// SortActivateAt ascending
+	SortActivateAt Sort = "activate_at"
+	// SortActivateAtDesc descending
+	SortActivateAtDesc Sort = "-activate_at"
+)
+
+// Descending reports if s sorts in descending order
+func (s Sort) Descending() bool {
+	return s == SortMSISDNDesc || s == SortActivateAtDesc
+}
+
+// ByActivateAt reports if s sorts on activate_at
+func (s Sort) ByActivateAt() bool {
+	return s == SortActivateAt || s == SortActivateAtDesc
+}
+
+// Query for listing subscriptions, nil filters match everything
+type Query struct {
+	Cursor         *string
+	Limit          int
+	Status         *Status
+	Type           *string
+	Operator       *string
+	ActivateAfter  *time.Time
+	ActivateBefore *time.Time
+	// OperatorChangedSince matches subscriptions whose operator changed at or after it
+	OperatorChangedSince *time.Time
+	// OperatorCheckedBefore matches subscriptions whose operator was never checked or checked before it
+	OperatorCheckedBefore *time.Time
+	// NotFinal matches only subscriptions whose status can still change
+	NotFinal bool
+	Sort     Sort
+	// MSISDNs the result is restricted to, an empty slice matches nothing
+	MSISDNs []string
+}
+
+// Page of subscriptions, NextCursor is set if there are more subscriptions
+type Page struct {
+	Subscriptions []*Model `json:"subscriptions"`
+	NextCursor    *string  `json:"next_cursor,omitempty"`
+}
+
+// Normalize query filling in defaults, returning ErrNotValid if the query can not be used
+func (q *Query) Normalize() error {
+
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultLimit
+	case q.Limit < 0 || q.Limit > MaxLimit:
+		return fmt.Errorf("limit needs to be between 1 and %d: %w", MaxLimit, ErrNotValid)
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = SortMSISDN
+	case SortMSISDN, SortMSISDNDesc, SortActivateAt, SortActivateAtDesc:
+	default:
+		return fmt.Errorf("sort needs to be one of msisdn, -msisdn, activate_at or -activate_at: %w", ErrNotValid)
+	}
+
+	if q.Status != nil {
+		switch *q.Status {
+		case StatusPending, StatusActivated, StatusPaused, StatusCancelled:
+		default:
+			return fmt.Errorf("unknown status %q: %w", *q.Status, ErrNotValid)
+		}
+	}
+
+	if q.Cursor != nil {
+		if _, err := q.After(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Matches reports if m passes the filters of the query, ignoring the cursor
+func (q *Query) Matches(m *Model) bool {
+
+	if q.MSISDNs != nil && !contains(q.MSISDNs, *m.MSISDN) {
+		return false
+	}
+
+	if q.Status != nil && (m.Status == nil || *m.Status != *q.Status) {
+		return false
+	}
+
+	if q.Type != nil && (m.Type == nil || *m.Type != *q.Type) {
+		return false
+	}
+
+	if q.Operator != nil && (m.Operator == nil || *m.Operator != *q.Operator) {
+		return false
+	}
+
+	if q.ActivateAfter != nil && (m.ActivateAt == nil || m.ActivateAt.Before(*q.ActivateAfter)) {
+		return false
+	}
+
+	if q.ActivateBefore != nil && (m.ActivateAt == nil || !m.ActivateAt.Before(*q.ActivateBefore)) {
+		return false
+	}
+
+	if q.OperatorChangedSince != nil && (m.OperatorChangedAt == nil || m.OperatorChangedAt.Before(*q.OperatorChangedSince)) {
+		return false
+	}
+
+	if q.OperatorCheckedBefore != nil && m.OperatorCheckedAt != nil && !m.OperatorCheckedAt.Before(*q.OperatorCheckedBefore) {
+		return false
+	}
+
+	if q.NotFinal && m.Status != nil && m.Status.Final() {
+		return false
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor points at the last subscription of a page
+type Cursor struct {
+	Sort       Sort       `json:"s"`
+	MSISDN     string     `json:"m"`
+	ActivateAt *time.Time `json:"a,omitempty"`
+}
+
+// NewCursor after m for sort
+func NewCursor(sort Sort, m *Model) *string {
+
+	c := Cursor{Sort: sort, MSISDN: *m.MSISDN}
+	if sort.ByActivateAt() {
+		c.ActivateAt = m.ActivateAt
+	}
+
+	b, _ := json.Marshal(c)
+	s := base64.RawURLEncoding.EncodeToString(b)
+
+	return &s
+}
+
+// After decodes the cursor of the query, nil if there is none
+func (q *Query) After() (*Cursor, error) {
+
+	if q.Cursor == nil {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(*q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", ErrNotValid)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", ErrNotValid)
+	}
+
+	if c.Sort != q.Sort || c.Sort.ByActivateAt() && c.ActivateAt == nil {
+		return nil, fmt.Errorf("cursor not valid for sort %s: %w", q.Sort, ErrNotValid)
+	}
+
+	return &c, nil
+}
+
+// Less reports if a comes before b in sort order
+func (s Sort) Less(a, b *Model) bool {
+
+	if s.Descending() {
+		a, b = b, a
+	}
+
+	if s.ByActivateAt() && !a.ActivateAt.Equal(*b.ActivateAt) {
+		return a.ActivateAt.Before(*b.ActivateAt)
+	}
+
+	return *a.MSISDN < *b.MSISDN
+}
+
+// Past reports if m comes after the cursor in sort order
+func (c *Cursor) Past(m *Model) bool {
+
+	if c == nil {
+		return true
+	}
+
+	return c.Sort.Less(&Model{MSISDN: &c.MSISDN, ActivateAt: c.ActivateAt}, m)
+}
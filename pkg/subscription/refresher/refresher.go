@@ -0,0 +1,154 @@
+package refresher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// Actor recorded for operator changes found by the refresher
+const Actor = "operator-refresher"
+
+// Service whose subscriptions have their operators refreshed
+type Service interface {
+	List(ctx context.Context, q *subscription.Query) (*subscription.Page, error)
+	subscription.OperatorRefresher
+}
+
+// Refresher re-verifies the operators stored with subscriptions every interval, refreshing
+// those last checked more than maxAge ago one every pace. Cancelled subscriptions are left alone.
+type Refresher struct {
+	service  Service
+	interval time.Duration
+	maxAge   time.Duration
+	pace     time.Duration
+	clock    clock.Clock
+	cancel   context.CancelFunc
+	done     chan struct{}
+	sync.Mutex
+}
+
+// New refresher of the operators of the subscriptions of svc
+func New(svc Service, interval, maxAge, pace time.Duration, clk clock.Clock) *Refresher {
+	return &Refresher{
+		service:  svc,
+		interval: interval,
+		maxAge:   maxAge,
+		pace:     pace,
+		clock:    clk,
+	}
+}
+
+// Start refreshing in the background until ctx is done or it is stopped, the first pass runs
+// after one interval
+func (r *Refresher) Start(ctx context.Context) error {
+
+	if r.interval <= 0 {
+		return errors.New("refresh interval needs to be positive")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	r.Lock()
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.Unlock()
+
+	go r.run(ctx)
+
+	return nil
+}
+
+// Stop refresher and wait for a refresh in progress to finish
+func (r *Refresher) Stop() {
+
+	r.Lock()
+	cancel, done := r.cancel, r.done
+	r.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (r *Refresher) run(ctx context.Context) {
+
+	defer close(r.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(r.interval):
+			n, err := r.Refresh(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "operator refresh stopped early, continuing next interval", "refreshed", n, "error", err)
+				continue
+			}
+			slog.DebugContext(ctx, "operators refreshed", "refreshed", n)
+		}
+	}
+}
+
+// Refresh the operators checked more than maxAge ago, returning how many were refreshed. It stops
+// at the first lookup failing with operator.ErrUnavailable, the rest are picked up next time.
+func (r *Refresher) Refresh(ctx context.Context) (int, error) {
+
+	ctx = reqctx.WithActor(ctx, Actor)
+	stale := r.clock.Now().Add(-r.maxAge)
+	q := &subscription.Query{OperatorCheckedBefore: &stale, NotFinal: true, Limit: subscription.MaxLimit}
+
+	var n, looked int
+
+	for {
+		page, err := r.service.List(ctx, q)
+		if err != nil {
+			return n, fmt.Errorf("failed to list subscriptions to refresh: %w", err)
+		}
+
+		for _, sub := range page.Subscriptions {
+
+			if looked > 0 && r.pace > 0 {
+				select {
+				case <-ctx.Done():
+					return n, ctx.Err()
+				case <-r.clock.After(r.pace):
+				}
+			}
+
+			looked++
+
+			_, err := r.service.RefreshOperator(ctx, sub.MSISDN)
+			switch {
+			case err == nil:
+				n++
+			case errors.Is(err, operator.ErrUnavailable), ctx.Err() != nil:
+				return n, err
+			case errors.Is(err, operator.ErrNotFound):
+				// checked, the source does not know the number
+				slog.DebugContext(ctx, "no operator found to refresh", "msisdn", *sub.MSISDN)
+			case errors.Is(err, subscription.ErrNotFound):
+				// cancelled and removed since it was listed
+			default:
+				slog.ErrorContext(ctx, "failed to refresh operator", "msisdn", *sub.MSISDN, "error", err)
+			}
+		}
+
+		if page.NextCursor == nil {
+			return n, nil
+		}
+
+		q.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,134 @@
+package refresher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+type stubService struct {
+	subscriptions []*subscription.Model
+	refreshed     []string
+	unavailable   string
+	unknown       string
+}
+
+func (svc *stubService) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+
+	page := &subscription.Page{}
+
+	start := 0
+	if q.Cursor != nil {
+		fmt.Sscan(*q.Cursor, &start)
+	}
+
+	for i := start; i < len(svc.subscriptions); i++ {
+		if len(page.Subscriptions) == 1 {
+			next := fmt.Sprint(i)
+			page.NextCursor = &next
+			break
+		}
+		if q.Matches(svc.subscriptions[i]) {
+			page.Subscriptions = append(page.Subscriptions, svc.subscriptions[i])
+		}
+	}
+
+	return page, nil
+}
+
+func (svc *stubService) RefreshOperator(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+
+	if reqctx.Actor(ctx) != Actor {
+		return nil, fmt.Errorf("expected refresh by %s, got: %s", Actor, reqctx.Actor(ctx))
+	}
+
+	if *msisdn == svc.unavailable {
+		return nil, operator.ErrUnavailable
+	}
+
+	if *msisdn == svc.unknown {
+		return nil, operator.ErrNotFound
+	}
+
+	svc.refreshed = append(svc.refreshed, *msisdn)
+
+	return nil, nil
+}
+
+func newModel(msisdn string, status subscription.Status, checkedAt *time.Time) *subscription.Model {
+	return &subscription.Model{MSISDN: &msisdn, Status: &status, OperatorCheckedAt: checkedAt}
+}
+
+func TestRefresh(t *testing.T) {
+
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	fresh, old := now.Add(-time.Hour), now.Add(-48*time.Hour)
+
+	svc := &stubService{subscriptions: []*subscription.Model{
+		newModel("8-1", subscription.StatusActivated, &fresh),
+		newModel("8-2", subscription.StatusActivated, &old),
+		newModel("8-3", subscription.StatusCancelled, &old),
+		newModel("8-4", subscription.StatusPending, nil),
+		newModel("8-5", subscription.StatusPaused, &old),
+	}}
+
+	r := New(svc, time.Hour, 24*time.Hour, 0, clock.NewFake(now))
+
+	n, err := r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "[8-2 8-4 8-5]"; n != 3 || fmt.Sprint(svc.refreshed) != expected {
+		t.Fatalf("expected %s refreshed, got: %d %v", expected, n, svc.refreshed)
+	}
+
+	// numbers the source does not know are skipped
+	svc.refreshed, svc.unknown = nil, "8-2"
+
+	n, err = r.Refresh(context.Background())
+	if err != nil || n != 2 || fmt.Sprint(svc.refreshed) != "[8-4 8-5]" {
+		t.Fatalf("expected 8-2 to be skipped, got: %d %v %v", n, svc.refreshed, err)
+	}
+
+	// stops once operators are unavailable, the rest wait for the next interval
+	svc.refreshed, svc.unknown, svc.unavailable = nil, "", "8-4"
+
+	n, err = r.Refresh(context.Background())
+	if err == nil || n != 1 || fmt.Sprint(svc.refreshed) != "[8-2]" {
+		t.Fatalf("expected refresh to stop at 8-4, got: %d %v %v", n, svc.refreshed, err)
+	}
+}
+
+func TestRefreshPaced(t *testing.T) {
+
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	svc := &stubService{subscriptions: []*subscription.Model{
+		newModel("8-1", subscription.StatusActivated, nil),
+		newModel("8-2", subscription.StatusActivated, nil),
+	}}
+
+	r := New(svc, time.Hour, 24*time.Hour, time.Second, clk)
+
+	done := make(chan int)
+	go func() {
+		n, _ := r.Refresh(context.Background())
+		done <- n
+	}()
+
+	// the second lookup waits for the pace
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	if n := <-done; n != 2 {
+		t.Fatalf("expected 2 refreshed, got: %d", n)
+	}
+}
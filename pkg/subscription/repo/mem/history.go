@@ -0,0 +1,86 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// HistoryRepository for in memory subscription history
+type HistoryRepository struct {
+	entries map[string][]subscription.HistoryEntry
+	sync.Mutex
+}
+
+func NewHistoryRepository() (subscription.HistoryRepository, error) {
+	return &HistoryRepository{
+		entries: map[string][]subscription.HistoryEntry{},
+	}, nil
+}
+
+func (repo *HistoryRepository) Append(ctx context.Context, e *subscription.HistoryEntry) error {
+
+	if e == nil {
+		return errors.New("no history entry provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	entry := *e
+	entry.Changes = append([]subscription.Change(nil), e.Changes...)
+	entry.Sequence = int64(len(repo.entries[e.MSISDN]) + 1)
+
+	repo.entries[e.MSISDN] = append(repo.entries[e.MSISDN], entry)
+	e.Sequence = entry.Sequence
+
+	return nil
+}
+
+func (repo *HistoryRepository) History(ctx context.Context, msisdn *string, cursor *string, limit int) (*subscription.HistoryPage, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	if limit <= 0 {
+		return nil, errors.New("limit needs to be positive")
+	}
+
+	var after int64
+	if cursor != nil {
+		seq, err := strconv.ParseInt(*cursor, 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid cursor %q: %w", *cursor, subscription.ErrNotValid)
+		}
+		after = seq
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	entries := repo.entries[*msisdn]
+	if after > int64(len(entries)) {
+		after = int64(len(entries))
+	}
+	entries = entries[after:]
+
+	page := &subscription.HistoryPage{Entries: []*subscription.HistoryEntry{}}
+
+	for i := range entries {
+		if i == limit {
+			next := strconv.FormatInt(page.Entries[i-1].Sequence, 10)
+			page.NextCursor = &next
+			break
+		}
+		entry := entries[i]
+		entry.Changes = append([]subscription.Change(nil), entry.Changes...)
+		page.Entries = append(page.Entries, &entry)
+	}
+
+	return page, nil
+}
@@ -0,0 +1,184 @@
+package mem
+
+import (
+	"sort"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// sorted index of subscriptions in ascending order of sort
+type sorted struct {
+	sort  subscription.Sort
+	items []*subscription.Model
+}
+
+// search for the first position not before m
+func (idx *sorted) search(m *subscription.Model) int {
+	return sort.Search(len(idx.items), func(i int) bool { return !idx.sort.Less(idx.items[i], m) })
+}
+
+func (idx *sorted) insert(m *subscription.Model) {
+	i := idx.search(m)
+	idx.items = append(idx.items, nil)
+	copy(idx.items[i+1:], idx.items[i:])
+	idx.items[i] = m
+}
+
+func (idx *sorted) remove(m *subscription.Model) {
+	if i := idx.find(m); i >= 0 {
+		idx.items = append(idx.items[:i], idx.items[i+1:]...)
+	}
+}
+
+// find position of m, -1 if it is not in the index
+func (idx *sorted) find(m *subscription.Model) int {
+	i := idx.search(m)
+	if i < len(idx.items) && *idx.items[i].MSISDN == *m.MSISDN {
+		return i
+	}
+	return -1
+}
+
+// start position when walking the index past cursor c in the given direction
+func (idx *sorted) start(c *subscription.Cursor, descending bool) int {
+
+	if c == nil {
+		if descending {
+			return len(idx.items) - 1
+		}
+		return 0
+	}
+
+	m := &subscription.Model{MSISDN: &c.MSISDN, ActivateAt: c.ActivateAt}
+
+	if descending {
+		return idx.search(m) - 1
+	}
+
+	return sort.Search(len(idx.items), func(i int) bool { return idx.sort.Less(m, idx.items[i]) })
+}
+
+// sortings of a set of subscriptions, one sorted index per field they can be sorted on
+type sortings struct {
+	byMSISDN     *sorted
+	byActivateAt *sorted
+}
+
+func newSortings() *sortings {
+	return &sortings{
+		byMSISDN:     &sorted{sort: subscription.SortMSISDN},
+		byActivateAt: &sorted{sort: subscription.SortActivateAt},
+	}
+}
+
+func (s *sortings) add(m *subscription.Model) {
+	s.byMSISDN.insert(m)
+	s.byActivateAt.insert(m)
+}
+
+func (s *sortings) remove(m *subscription.Model) {
+	s.byMSISDN.remove(m)
+	s.byActivateAt.remove(m)
+}
+
+func (s *sortings) len() int {
+	return len(s.byMSISDN.items)
+}
+
+// by the index walked for sort
+func (s *sortings) by(sort subscription.Sort) *sorted {
+	if sort.ByActivateAt() {
+		return s.byActivateAt
+	}
+	return s.byMSISDN
+}
+
+// lookup of the subscriptions sharing the value of an indexed field
+type lookup map[string]*sortings
+
+func (l lookup) add(key *string, m *subscription.Model) {
+	if key == nil {
+		return
+	}
+	if l[*key] == nil {
+		l[*key] = newSortings()
+	}
+	l[*key].add(m)
+}
+
+func (l lookup) remove(key *string, m *subscription.Model) {
+	if key == nil || l[*key] == nil {
+		return
+	}
+	l[*key].remove(m)
+	if l[*key].len() == 0 {
+		delete(l, *key)
+	}
+}
+
+// indexes kept in sync with the subscriptions map of the repository
+type indexes struct {
+	all        *sortings
+	byStatus   lookup
+	byType     lookup
+	byOperator lookup
+}
+
+func newIndexes() *indexes {
+	return &indexes{
+		all:        newSortings(),
+		byStatus:   lookup{},
+		byType:     lookup{},
+		byOperator: lookup{},
+	}
+}
+
+func (idx *indexes) add(m *subscription.Model) {
+	idx.all.add(m)
+	idx.byStatus.add((*string)(m.Status), m)
+	idx.byType.add(m.Type, m)
+	idx.byOperator.add(m.Operator, m)
+}
+
+func (idx *indexes) remove(m *subscription.Model) {
+	idx.all.remove(m)
+	idx.byStatus.remove((*string)(m.Status), m)
+	idx.byType.remove(m.Type, m)
+	idx.byOperator.remove(m.Operator, m)
+}
+
+// smallest sortings of the subscriptions matching the equality filters of q, all of them if q has
+// none. The msisdns of q, the scope of the caller, are looked up in subscriptions and sorted.
+func (idx *indexes) smallest(q *subscription.Query, subscriptions map[string]*subscription.Model) *sortings {
+
+	result := idx.all
+
+	consider := func(l lookup, key *string) {
+		if key == nil {
+			return
+		}
+		s := l[*key]
+		if s == nil {
+			s = newSortings()
+		}
+		if s.len() < result.len() {
+			result = s
+		}
+	}
+
+	consider(idx.byStatus, (*string)(q.Status))
+	consider(idx.byType, q.Type)
+	consider(idx.byOperator, q.Operator)
+
+	if q.MSISDNs != nil && len(q.MSISDNs) < result.len() {
+		s := newSortings()
+		for _, msisdn := range q.MSISDNs {
+			if m, ok := subscriptions[msisdn]; ok && s.byMSISDN.find(m) < 0 {
+				s.add(m)
+			}
+		}
+		result = s
+	}
+
+	return result
+}
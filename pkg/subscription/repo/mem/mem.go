@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/rgynn/subscription-api/pkg/subscription"
 )
@@ -11,24 +12,110 @@ import (
 // Repository for in memory subscriptions
 type Repository struct {
 	subscriptions map[string]*subscription.Model
+	indexes       *indexes
 	sync.Mutex
 }
 
 func NewRepository() (subscription.Repository, error) {
 	return &Repository{
 		subscriptions: map[string]*subscription.Model{},
+		indexes:       newIndexes(),
 	}, nil
 }
 
-func (repo *Repository) List(ctx context.Context) ([]*subscription.Model, error) {
+func (repo *Repository) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+
+	if q == nil {
+		q = &subscription.Query{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	after, err := q.After()
+	if err != nil {
+		return nil, err
+	}
 
 	repo.Lock()
 	defer repo.Unlock()
 
-	result := make([]*subscription.Model, len(repo.subscriptions))
+	// walking the smallest index matching q skips most subscriptions that do not match
+	items := walk(repo.indexes.smallest(q, repo.subscriptions).by(q.Sort), q, after)
+
+	page := &subscription.Page{Subscriptions: []*subscription.Model{}}
+
+	for i, sub := range items {
+		if i == q.Limit {
+			page.NextCursor = subscription.NewCursor(q.Sort, page.Subscriptions[i-1])
+			break
+		}
+		result := *sub
+		page.Subscriptions = append(page.Subscriptions, &result)
+	}
+
+	return page, nil
+}
+
+// walk the sorted index matching q from the cursor until one more than a page is found
+func walk(idx *sorted, q *subscription.Query, after *subscription.Cursor) []*subscription.Model {
 
-	for _, sub := range repo.subscriptions {
-		result = append(result, sub)
+	step := 1
+	if q.Sort.Descending() {
+		step = -1
+	}
+
+	var items []*subscription.Model
+
+	for i := idx.start(after, q.Sort.Descending()); i >= 0 && i < len(idx.items) && len(items) <= q.Limit; i += step {
+
+		sub := idx.items[i]
+
+		// past the requested activate_at range, nothing more can match
+		if q.Sort == subscription.SortActivateAt && q.ActivateBefore != nil && !sub.ActivateAt.Before(*q.ActivateBefore) ||
+			q.Sort == subscription.SortActivateAtDesc && q.ActivateAfter != nil && sub.ActivateAt.Before(*q.ActivateAfter) {
+			break
+		}
+
+		if q.Matches(sub) {
+			items = append(items, sub)
+		}
+	}
+
+	return items
+}
+
+// put m in place of any stored subscription with the same msisdn, expects the lock to be held
+func (repo *Repository) put(m *subscription.Model) {
+
+	if old, ok := repo.subscriptions[*m.MSISDN]; ok {
+		repo.indexes.remove(old)
+	}
+
+	repo.subscriptions[*m.MSISDN] = m
+	repo.indexes.add(m)
+}
+
+// Count subscriptions by status and type
+func (repo *Repository) Count(ctx context.Context) ([]subscription.Count, error) {
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	type key struct {
+		status subscription.Status
+		typ    string
+	}
+
+	counts := map[key]int{}
+	for _, m := range repo.subscriptions {
+		counts[key{*m.Status, *m.Type}]++
+	}
+
+	result := make([]subscription.Count, 0, len(counts))
+	for k, n := range counts {
+		result = append(result, subscription.Count{Status: k.status, Type: k.typ, N: n})
 	}
 
 	return result, nil
@@ -48,7 +135,9 @@ func (repo *Repository) Get(ctx context.Context, msisdn *string) (*subscription.
 		return nil, subscription.ErrNotFound
 	}
 
-	return sub, nil
+	result := *sub
+
+	return &result, nil
 }
 
 func (repo *Repository) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
@@ -60,11 +149,24 @@ func (repo *Repository) Create(ctx context.Context, m *subscription.Model) (*sub
 	repo.Lock()
 	defer repo.Unlock()
 
-	if sub, ok := repo.subscriptions[*m.MSISDN]; ok && sub.IsActive() {
+	sub, ok := repo.subscriptions[*m.MSISDN]
+	if ok && !sub.Status.Final() {
 		return nil, subscription.ErrAlreadyExists
 	}
 
-	repo.subscriptions[*m.MSISDN] = m
+	if err := m.InitStatus(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	// versions keep increasing when a cancelled subscription is replaced
+	m.Version = nil
+	if ok {
+		m.Version = sub.Version
+	}
+	m.NextVersion()
+
+	stored := *m
+	repo.put(&stored)
 
 	return m, nil
 }
@@ -83,24 +185,49 @@ func (repo *Repository) Update(ctx context.Context, m *subscription.Model) (*sub
 		return nil, subscription.ErrNotFound
 	}
 
-	// check if trying to change activate_at and status isn't pending
-	if !sub.ActivateAt.Equal(*m.ActivateAt) && sub.Status != &subscription.StatusPending {
-		return nil, errors.New("subscription needs to be pending to update activate_at")
+	updated := *sub
+
+	if err := updated.Amend(m, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	updated.NextVersion()
+	repo.put(&updated)
+
+	result := updated
+
+	return &result, nil
+}
+
+func (repo *Repository) Activate(ctx context.Context, msisdn *string, now time.Time) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	sub, ok := repo.subscriptions[*msisdn]
+	if !ok {
+		return nil, subscription.ErrNotFound
 	}
 
-	sub.ActivateAt = m.ActivateAt
-	sub.Type = m.Type
+	updated := *sub
 
-	if err := sub.UpdateStatus(nil); err != nil {
+	if err := updated.Fire(subscription.EventActivate, now); err != nil {
 		return nil, err
 	}
 
-	repo.subscriptions[*m.MSISDN] = sub
+	updated.NextVersion()
+	repo.put(&updated)
 
-	return sub, nil
+	result := updated
+
+	return &result, nil
 }
 
-func (repo *Repository) TogglePaused(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+func (repo *Repository) TogglePaused(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
 
 	if msisdn == nil {
 		return nil, errors.New("no msisdn provided")
@@ -114,22 +241,25 @@ func (repo *Repository) TogglePaused(ctx context.Context, msisdn *string) (*subs
 		return nil, subscription.ErrNotFound
 	}
 
-	if sub.Status == &subscription.StatusPaused {
-		if err := sub.UpdateStatus(nil); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := sub.UpdateStatus(&subscription.StatusPaused); err != nil {
-			return nil, err
-		}
+	if err := sub.CheckVersion(expected); err != nil {
+		return nil, err
+	}
+
+	updated := *sub
+
+	if err := updated.TogglePaused(time.Now().UTC()); err != nil {
+		return nil, err
 	}
 
-	repo.subscriptions[*msisdn] = sub
+	updated.NextVersion()
+	repo.put(&updated)
+
+	result := updated
 
-	return sub, nil
+	return &result, nil
 }
 
-func (repo *Repository) Cancel(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+func (repo *Repository) Cancel(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
 
 	if msisdn == nil {
 		return nil, errors.New("no msisdn provided")
@@ -143,11 +273,48 @@ func (repo *Repository) Cancel(ctx context.Context, msisdn *string) (*subscripti
 		return nil, subscription.ErrNotFound
 	}
 
-	if err := sub.UpdateStatus(&subscription.StatusCancelled); err != nil {
+	if err := sub.CheckVersion(expected); err != nil {
 		return nil, err
 	}
 
-	repo.subscriptions[*msisdn] = sub
+	updated := *sub
+
+	if err := updated.Fire(subscription.EventCancel, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	updated.NextVersion()
+	repo.put(&updated)
+
+	result := updated
+
+	return &result, nil
+}
+
+// SetOperator verified at checkedAt, the version is only incremented if the operator changed
+func (repo *Repository) SetOperator(ctx context.Context, msisdn *string, operator *string, checkedAt time.Time) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	sub, ok := repo.subscriptions[*msisdn]
+	if !ok {
+		return nil, subscription.ErrNotFound
+	}
+
+	updated := *sub
+
+	if updated.SetOperator(operator, checkedAt.UTC()) {
+		updated.NextVersion()
+	}
+
+	repo.put(&updated)
+
+	result := updated
 
-	return sub, nil
+	return &result, nil
 }
@@ -0,0 +1,173 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/repotest"
+)
+
+func TestList(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo, err := NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	types := []string{"PBX", "CELL"}
+	operators := []string{"Tele2 Sverige AB", "Telia Sverige AB", "Telenor Sverige AB"}
+
+	var all []*subscription.Model
+
+	for i := 0; i < 50; i++ {
+		msisdn := fmt.Sprintf("8-%07d", (i*7919)%100000)
+		activateAt := now.Add(time.Duration(i%10-5) * 24 * time.Hour)
+		subType := types[i%len(types)]
+		op := operators[i%len(operators)]
+
+		m, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType, Operator: &op})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i%4 == 0 && m.IsActive() {
+			if m, err = repo.TogglePaused(ctx, &msisdn, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if i%5 == 1 {
+			if m, err = repo.Cancel(ctx, &msisdn, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		all = append(all, m)
+	}
+
+	paused, unknown := subscription.StatusPaused, "Unknown AB"
+	after := now.Add(-2 * 24 * time.Hour)
+	before := now.Add(3 * 24 * time.Hour)
+
+	queries := map[string]subscription.Query{
+		"all":                 {},
+		"msisdn desc":         {Sort: subscription.SortMSISDNDesc},
+		"activate_at":         {Sort: subscription.SortActivateAt},
+		"activate_at desc":    {Sort: subscription.SortActivateAtDesc},
+		"paused":              {Status: &paused, Sort: subscription.SortActivateAt},
+		"paused desc":         {Status: &paused, Sort: subscription.SortActivateAtDesc},
+		"unknown operator":    {Operator: &unknown},
+		"type and operator":   {Type: &types[0], Operator: &operators[1], Sort: subscription.SortMSISDNDesc},
+		"activate range":      {ActivateAfter: &after, ActivateBefore: &before, Sort: subscription.SortActivateAt},
+		"activate range desc": {ActivateAfter: &after, ActivateBefore: &before, Sort: subscription.SortActivateAtDesc},
+		"msisdns":             {MSISDNs: []string{*all[3].MSISDN, *all[1].MSISDN, "8-unknown"}, Sort: subscription.SortActivateAt},
+		"no msisdns":          {MSISDNs: []string{}},
+		"not final":           {NotFinal: true, Sort: subscription.SortActivateAt},
+	}
+
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+
+			if err := q.Normalize(); err != nil {
+				t.Fatal(err)
+			}
+
+			var expected []string
+			for _, m := range all {
+				if q.Matches(m) {
+					expected = append(expected, *m.MSISDN)
+				}
+			}
+			sort.Slice(expected, func(i, j int) bool {
+				return q.Sort.Less(repo.(*Repository).subscriptions[expected[i]], repo.(*Repository).subscriptions[expected[j]])
+			})
+
+			q.Limit = 7
+
+			var got []string
+			for {
+				page, err := repo.List(ctx, &q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, m := range page.Subscriptions {
+					got = append(got, *m.MSISDN)
+				}
+				if page.NextCursor == nil {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(expected) {
+				t.Fatalf("expected: %v, got: %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo, err := NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "PBX"
+
+	created, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *created.Version != 1 {
+		t.Fatalf("expected version 1, got: %d", *created.Version)
+	}
+
+	cell := "CELL"
+	updated, err := repo.Update(ctx, &subscription.Model{MSISDN: &msisdn, Type: &cell, Version: created.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second agent editing the version it read first loses
+	if _, err := repo.Update(ctx, &subscription.Model{MSISDN: &msisdn, Type: &subType, Version: created.Version}); !errors.Is(err, subscription.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got: %v", err)
+	}
+
+	cancelled, err := repo.Cancel(ctx, &msisdn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recreated, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updated.Version != 2 || *cancelled.Version != 3 || *recreated.Version != 4 {
+		t.Fatalf("expected versions 2, 3 and 4, got: %d, %d and %d", *updated.Version, *cancelled.Version, *recreated.Version)
+	}
+}
+
+func TestHistoryRepository(t *testing.T) {
+
+	repo, err := NewHistoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.History(t, repo)
+}
@@ -0,0 +1,83 @@
+// Package repotest holds the tests every subscription repository implementation has to pass
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// History tests repo numbers entries per msisdn, starting at 1, and pages through them by that
+// sequence
+func History(t *testing.T, repo subscription.HistoryRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+	msisdns := []string{"8-6785500", "8-6785501"}
+	paused := subscription.StatusPaused
+	actions := []subscription.Action{subscription.ActionCreated, subscription.ActionPaused, subscription.ActionResumed}
+
+	// interleaved, so a sequence shared by all msisdns would show
+	for i, action := range actions {
+		for _, msisdn := range msisdns {
+
+			e := &subscription.HistoryEntry{
+				MSISDN:    msisdn,
+				Action:    action,
+				ToStatus:  &paused,
+				Changes:   []subscription.Change{{Field: "status", To: (*string)(&paused)}},
+				Timestamp: time.Now().UTC(),
+				Actor:     "support",
+			}
+
+			if err := repo.Append(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+
+			if e.Sequence != int64(i+1) {
+				t.Fatalf("expected entry %d of %s to be numbered %d, got: %d", i+1, msisdn, i+1, e.Sequence)
+			}
+		}
+	}
+
+	for _, msisdn := range msisdns {
+
+		page, err := repo.History(ctx, &msisdn, nil, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Entries) != 2 || page.NextCursor == nil || *page.NextCursor != "2" || page.Entries[1].Action != subscription.ActionPaused {
+			t.Fatalf("unexpected first page of %s: %d entries, cursor: %v", msisdn, len(page.Entries), page.NextCursor)
+		}
+
+		for i, e := range page.Entries {
+			if e.Sequence != int64(i+1) || e.MSISDN != msisdn {
+				t.Fatalf("unexpected entry %d of %s: %+v", i, msisdn, e)
+			}
+		}
+
+		page, err = repo.History(ctx, &msisdn, page.NextCursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Entries) != 1 || page.NextCursor != nil || page.Entries[0].Sequence != 3 || page.Entries[0].Changes[0].Field != "status" {
+			t.Fatalf("unexpected last page of %s: %d entries, cursor: %v", msisdn, len(page.Entries), page.NextCursor)
+		}
+	}
+
+	unknown := "8-0"
+	page, err := repo.History(ctx, &unknown, nil, 2)
+	if err != nil || len(page.Entries) != 0 || page.NextCursor != nil {
+		t.Fatalf("expected no history of an unknown msisdn, got: %v, %v", page, err)
+	}
+
+	cursor := fmt.Sprint(len(actions))
+	if page, err := repo.History(ctx, &msisdns[0], &cursor, 2); err != nil || len(page.Entries) != 0 {
+		t.Fatalf("expected no entries after the last, got: %v, %v", page, err)
+	}
+}
@@ -0,0 +1,265 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	// register database/sql drivers for the supported dialects
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// Dialect of sql spoken by a database
+type Dialect struct {
+	Name         string
+	driver       string
+	numbered     bool
+	forUpdate    string
+	prepareDSN   func(dsn string) string
+	maxOpenConns int
+	// uniqueViolation reports if err is a violated primary key or unique constraint
+	uniqueViolation func(err error) bool
+}
+
+var (
+	// SQLite dialect using the pure go modernc.org/sqlite driver
+	SQLite = &Dialect{
+		Name:            "sqlite",
+		driver:          "sqlite",
+		prepareDSN:      sqliteDSN,
+		maxOpenConns:    1,
+		uniqueViolation: sqliteUniqueViolation,
+	}
+	// Postgres dialect using the pgx driver
+	Postgres = &Dialect{
+		Name:       "postgres",
+		driver:     "pgx",
+		numbered:   true,
+		forUpdate:  " FOR UPDATE",
+		prepareDSN: func(dsn string) string { return dsn },
+		uniqueViolation: func(err error) bool {
+			var e *pgconn.PgError
+			return errors.As(err, &e) && e.Code == "23505"
+		},
+	}
+)
+
+// DialectByName returns the dialect with the provided name
+func DialectByName(name string) (*Dialect, error) {
+	switch name {
+	case SQLite.Name:
+		return SQLite, nil
+	case Postgres.Name:
+		return Postgres, nil
+	default:
+		return nil, fmt.Errorf("unsupported sql dialect: %s", name)
+	}
+}
+
+func sqliteDSN(dsn string) string {
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	return dsn + sep + "_pragma=busy_timeout(5000)&_time_format=sqlite"
+}
+
+func sqliteUniqueViolation(err error) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && (e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE)
+}
+
+// rebind query written with ? placeholders to the placeholders of the dialect
+func (d *Dialect) rebind(query string) string {
+
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// DB shared by the sql repositories, of subscriptions as well as webhooks
+type DB struct {
+	*dbsql.DB
+	dialect *Dialect
+}
+
+// Open database using dialect and apply any pending migrations
+func Open(ctx context.Context, dialect *Dialect, dsn string) (*DB, error) {
+
+	if dialect == nil {
+		return nil, errors.New("no sql dialect provided")
+	}
+
+	if dsn == "" {
+		return nil, errors.New("no dsn provided")
+	}
+
+	conn, err := dbsql.Open(dialect.driver, dialect.prepareDSN(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", dialect.Name, err)
+	}
+
+	if dialect.maxOpenConns > 0 {
+		conn.SetMaxOpenConns(dialect.maxOpenConns)
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s database: %w", dialect.Name, err)
+	}
+
+	db := &DB{DB: conn, dialect: dialect}
+
+	if err := db.migrate(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *DB) migrate(ctx context.Context) error {
+
+	dir := path.Join("migrations", db.dialect.Name)
+
+	files, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations for %s: %w", db.dialect.Name, err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, file := range files {
+
+		version := strings.TrimSuffix(file.Name(), ".sql")
+
+		err := db.tx(ctx, func(tx *dbsql.Tx) error {
+
+			var applied int
+			if err := tx.QueryRowContext(ctx, db.dialect.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), version).Scan(&applied); err != nil {
+				return err
+			}
+
+			if applied > 0 {
+				return nil
+			}
+
+			stmts, err := migrations.ReadFile(path.Join(dir, file.Name()))
+			if err != nil {
+				return err
+			}
+
+			for _, stmt := range strings.Split(string(stmts), ";") {
+				if strings.TrimSpace(stmt) == "" {
+					continue
+				}
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.ExecContext(ctx, db.dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), version, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// Rebind query written with ? placeholders to the placeholders of the dialect of db
+func (db *DB) Rebind(query string) string {
+	return db.dialect.rebind(query)
+}
+
+type txKey struct{}
+
+// Atomically runs fn in a single transaction, the repositories of db join it when called with the
+// ctx passed to fn. It is committed if fn returns no error.
+func (db *DB) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.tx(ctx, func(tx *dbsql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Querier to run queries of ctx with, the transaction of ctx if there is one
+func (db *DB) Querier(ctx context.Context) Querier {
+
+	if tx, ok := ctx.Value(txKey{}).(*dbsql.Tx); ok {
+		return tx
+	}
+
+	return db.DB
+}
+
+// Querier of a database or a transaction
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (dbsql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*dbsql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *dbsql.Row
+}
+
+// tx runs fn in a transaction, committing if fn returns no error. It runs in the transaction of
+// ctx if there is one, which is committed by whoever started it.
+func (db *DB) tx(ctx context.Context, fn func(tx *dbsql.Tx) error) error {
+
+	if tx, ok := ctx.Value(txKey{}).(*dbsql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// HistoryRepository for subscription history stored in a sql database
+type HistoryRepository struct {
+	db *DB
+}
+
+func NewHistoryRepository(db *DB) (subscription.HistoryRepository, error) {
+
+	if db == nil {
+		return nil, errors.New("no db provided")
+	}
+
+	return &HistoryRepository{db: db}, nil
+}
+
+func (repo *HistoryRepository) Append(ctx context.Context, e *subscription.HistoryEntry) error {
+
+	if e == nil {
+		return errors.New("no history entry provided")
+	}
+
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history changes: %w", err)
+	}
+
+	// numbered per msisdn, as in memory, the unique index rejects an entry numbered concurrently
+	row := repo.db.Querier(ctx).QueryRowContext(ctx, repo.db.dialect.rebind(`INSERT INTO subscription_history
+		(msisdn, msisdn_sequence, action, from_status, to_status, changes, timestamp, actor, request_id)
+		VALUES (?, (SELECT COALESCE(MAX(msisdn_sequence), 0) + 1 FROM subscription_history WHERE msisdn = ?), ?, ?, ?, ?, ?, ?, ?)
+		RETURNING msisdn_sequence`),
+		e.MSISDN, e.MSISDN, string(e.Action), (*string)(e.FromStatus), (*string)(e.ToStatus), string(changes), e.Timestamp.UTC(), e.Actor, e.RequestID)
+
+	if err := row.Scan(&e.Sequence); err != nil {
+		return fmt.Errorf("failed to insert history entry: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *HistoryRepository) History(ctx context.Context, msisdn *string, cursor *string, limit int) (*subscription.HistoryPage, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	if limit <= 0 {
+		return nil, errors.New("limit needs to be positive")
+	}
+
+	var after int64
+	if cursor != nil {
+		seq, err := strconv.ParseInt(*cursor, 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid cursor %q: %w", *cursor, subscription.ErrNotValid)
+		}
+		after = seq
+	}
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, repo.db.dialect.rebind(`SELECT msisdn_sequence, msisdn, action, from_status, to_status, changes, timestamp, actor, request_id
+		FROM subscription_history WHERE msisdn = ? AND msisdn_sequence > ? ORDER BY msisdn_sequence LIMIT ?`), *msisdn, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	defer rows.Close()
+
+	page := &subscription.HistoryPage{Entries: []*subscription.HistoryEntry{}}
+
+	for rows.Next() {
+
+		var (
+			e                    subscription.HistoryEntry
+			action, changes      string
+			fromStatus, toStatus dbsql.NullString
+			timestamp            time.Time
+		)
+
+		if err := rows.Scan(&e.Sequence, &e.MSISDN, &action, &fromStatus, &toStatus, &changes, &timestamp, &e.Actor, &e.RequestID); err != nil {
+			return nil, fmt.Errorf("failed to scan history entry: %w", err)
+		}
+
+		if len(page.Entries) == limit {
+			next := strconv.FormatInt(page.Entries[limit-1].Sequence, 10)
+			page.NextCursor = &next
+			break
+		}
+
+		e.Action = subscription.Action(action)
+		e.Timestamp = timestamp.UTC()
+		e.FromStatus = nullStatus(fromStatus)
+		e.ToStatus = nullStatus(toStatus)
+
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history changes: %w", err)
+		}
+
+		page.Entries = append(page.Entries, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+
+	return page, nil
+}
+
+func nullStatus(s dbsql.NullString) *subscription.Status {
+	if !s.Valid {
+		return nil
+	}
+	status := subscription.Status(s.String)
+	return &status
+}
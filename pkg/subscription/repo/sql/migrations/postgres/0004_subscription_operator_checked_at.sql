@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN operator_checked_at TIMESTAMPTZ;
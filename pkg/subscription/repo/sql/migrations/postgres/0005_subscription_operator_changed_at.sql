@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions ADD COLUMN operator_changed_at TIMESTAMPTZ;
+CREATE INDEX subscriptions_operator_changed_at ON subscriptions (operator_changed_at, msisdn);
@@ -0,0 +1,3 @@
+ALTER TABLE subscription_history ADD COLUMN msisdn_sequence BIGINT NOT NULL DEFAULT 0;
+UPDATE subscription_history SET msisdn_sequence = (SELECT COUNT(*) FROM subscription_history h WHERE h.msisdn = subscription_history.msisdn AND h.sequence <= subscription_history.sequence);
+CREATE UNIQUE INDEX subscription_history_msisdn_sequence ON subscription_history (msisdn, msisdn_sequence);
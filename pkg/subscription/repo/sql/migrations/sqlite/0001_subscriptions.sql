@@ -0,0 +1,21 @@
+CREATE TABLE subscriptions (
+    msisdn      TEXT PRIMARY KEY,
+    activate_at TIMESTAMP NOT NULL,
+    type        TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    operator    TEXT
+);
+
+CREATE TABLE subscription_history (
+    sequence    INTEGER PRIMARY KEY AUTOINCREMENT,
+    msisdn      TEXT NOT NULL,
+    action      TEXT NOT NULL,
+    from_status TEXT,
+    to_status   TEXT,
+    changes     TEXT NOT NULL,
+    timestamp   TIMESTAMP NOT NULL,
+    actor       TEXT NOT NULL,
+    request_id  TEXT NOT NULL
+);
+
+CREATE INDEX subscription_history_msisdn ON subscription_history (msisdn, sequence);
@@ -0,0 +1,4 @@
+CREATE INDEX subscriptions_status ON subscriptions (status, msisdn);
+CREATE INDEX subscriptions_type ON subscriptions (type, msisdn);
+CREATE INDEX subscriptions_operator ON subscriptions (operator, msisdn);
+CREATE INDEX subscriptions_activate_at ON subscriptions (activate_at, msisdn);
@@ -0,0 +1 @@
+CREATE INDEX subscriptions_operator_checked_at ON subscriptions (operator_checked_at, msisdn);
@@ -0,0 +1,416 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+const columns = `msisdn, activate_at, type, status, operator, operator_checked_at, operator_changed_at, version`
+
+// Repository for subscriptions stored in a sql database
+type Repository struct {
+	db *DB
+}
+
+func NewRepository(db *DB) (subscription.Repository, error) {
+
+	if db == nil {
+		return nil, errors.New("no db provided")
+	}
+
+	return &Repository{db: db}, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*subscription.Model, error) {
+
+	var (
+		msisdn, subType, status string
+		activateAt              time.Time
+		operator                dbsql.NullString
+		operatorCheckedAt       dbsql.NullTime
+		operatorChangedAt       dbsql.NullTime
+		version                 int64
+	)
+
+	if err := row.Scan(&msisdn, &activateAt, &subType, &status, &operator, &operatorCheckedAt, &operatorChangedAt, &version); err != nil {
+		return nil, err
+	}
+
+	activateAt = activateAt.UTC()
+	s := subscription.Status(status)
+
+	m := &subscription.Model{
+		MSISDN:     &msisdn,
+		ActivateAt: &activateAt,
+		Type:       &subType,
+		Status:     &s,
+		Version:    &version,
+	}
+
+	if operator.Valid {
+		m.Operator = &operator.String
+	}
+
+	if operatorCheckedAt.Valid {
+		checkedAt := operatorCheckedAt.Time.UTC()
+		m.OperatorCheckedAt = &checkedAt
+	}
+
+	if operatorChangedAt.Valid {
+		changedAt := operatorChangedAt.Time.UTC()
+		m.OperatorChangedAt = &changedAt
+	}
+
+	return m, nil
+}
+
+func values(m *subscription.Model) []interface{} {
+	return []interface{}{*m.MSISDN, m.ActivateAt.UTC(), *m.Type, string(*m.Status), m.Operator, utc(m.OperatorCheckedAt), utc(m.OperatorChangedAt), *m.Version}
+}
+
+// utc time or nil, stored as NULL
+func utc(t *time.Time) interface{} {
+
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+func (repo *Repository) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+
+	if q == nil {
+		q = &subscription.Query{}
+	}
+
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+
+	after, err := q.After()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	filter := func(cond string, values ...interface{}) {
+		where = append(where, cond)
+		args = append(args, values...)
+	}
+
+	if q.MSISDNs != nil {
+		if len(q.MSISDNs) == 0 {
+			filter(`1 = 0`)
+		} else {
+			values := make([]interface{}, len(q.MSISDNs))
+			for i, msisdn := range q.MSISDNs {
+				values[i] = msisdn
+			}
+			filter(`msisdn IN (?`+strings.Repeat(`, ?`, len(q.MSISDNs)-1)+`)`, values...)
+		}
+	}
+
+	if q.Status != nil {
+		filter(`status = ?`, string(*q.Status))
+	}
+
+	if q.Type != nil {
+		filter(`type = ?`, *q.Type)
+	}
+
+	if q.Operator != nil {
+		filter(`operator = ?`, *q.Operator)
+	}
+
+	if q.ActivateAfter != nil {
+		filter(`activate_at >= ?`, q.ActivateAfter.UTC())
+	}
+
+	if q.ActivateBefore != nil {
+		filter(`activate_at < ?`, q.ActivateBefore.UTC())
+	}
+
+	if q.OperatorChangedSince != nil {
+		filter(`operator_changed_at >= ?`, q.OperatorChangedSince.UTC())
+	}
+
+	if q.OperatorCheckedBefore != nil {
+		filter(`(operator_checked_at IS NULL OR operator_checked_at < ?)`, q.OperatorCheckedBefore.UTC())
+	}
+
+	if q.NotFinal {
+		values := make([]interface{}, len(subscription.FinalStatuses))
+		for i, status := range subscription.FinalStatuses {
+			values[i] = string(status)
+		}
+		filter(`status NOT IN (?`+strings.Repeat(`, ?`, len(values)-1)+`)`, values...)
+	}
+
+	cmp, dir := ">", "ASC"
+	if q.Sort.Descending() {
+		cmp, dir = "<", "DESC"
+	}
+
+	order := `msisdn ` + dir
+	if q.Sort.ByActivateAt() {
+		order = `activate_at ` + dir + `, msisdn ` + dir
+	}
+
+	if after != nil {
+		if q.Sort.ByActivateAt() {
+			at := after.ActivateAt.UTC()
+			filter(`(activate_at `+cmp+` ? OR (activate_at = ? AND msisdn `+cmp+` ?))`, at, at, after.MSISDN)
+		} else {
+			filter(`msisdn `+cmp+` ?`, after.MSISDN)
+		}
+	}
+
+	query := `SELECT ` + columns + ` FROM subscriptions`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY ` + order + ` LIMIT ?`
+	args = append(args, q.Limit+1)
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, repo.db.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	page := &subscription.Page{Subscriptions: []*subscription.Model{}}
+
+	for rows.Next() {
+
+		if len(page.Subscriptions) == q.Limit {
+			page.NextCursor = subscription.NewCursor(q.Sort, page.Subscriptions[q.Limit-1])
+			break
+		}
+
+		m, err := scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+
+		page.Subscriptions = append(page.Subscriptions, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	return page, nil
+}
+
+// Count subscriptions by status and type
+func (repo *Repository) Count(ctx context.Context) ([]subscription.Count, error) {
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, `SELECT status, type, COUNT(*) FROM subscriptions GROUP BY status, type`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []subscription.Count
+	for rows.Next() {
+
+		var c subscription.Count
+		if err := rows.Scan(&c.Status, &c.Type, &c.N); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription count: %w", err)
+		}
+
+		result = append(result, c)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *Repository) Get(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	return repo.get(ctx, repo.db.Querier(ctx), *msisdn, "")
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *dbsql.Row
+}
+
+func (repo *Repository) get(ctx context.Context, q querier, msisdn string, suffix string) (*subscription.Model, error) {
+
+	row := q.QueryRowContext(ctx, repo.db.dialect.rebind(`SELECT `+columns+` FROM subscriptions WHERE msisdn = ?`+suffix), msisdn)
+
+	m, err := scan(row)
+	switch {
+	case errors.Is(err, dbsql.ErrNoRows):
+		return nil, subscription.ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	return m, nil
+}
+
+func (repo *Repository) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	if m == nil {
+		return nil, errors.New("no m *subscription.Model provided")
+	}
+
+	err := repo.db.tx(ctx, func(tx *dbsql.Tx) error {
+
+		existing, err := repo.get(ctx, tx, *m.MSISDN, repo.db.dialect.forUpdate)
+		switch {
+		case errors.Is(err, subscription.ErrNotFound):
+		case err != nil:
+			return err
+		case !existing.Status.Final():
+			return subscription.ErrAlreadyExists
+		}
+
+		if err := m.InitStatus(time.Now().UTC()); err != nil {
+			return err
+		}
+
+		// versions keep increasing when a cancelled subscription is replaced
+		m.Version = nil
+		if existing != nil {
+			m.Version = existing.Version
+		}
+		m.NextVersion()
+
+		if existing != nil {
+			_, err = tx.ExecContext(ctx, repo.db.dialect.rebind(`DELETE FROM subscriptions WHERE msisdn = ?`), *m.MSISDN)
+			if err != nil {
+				return fmt.Errorf("failed to replace cancelled subscription: %w", err)
+			}
+		}
+
+		// a row that does not exist yet is not locked, another instance may insert it first
+		_, err = tx.ExecContext(ctx, repo.db.dialect.rebind(`INSERT INTO subscriptions (`+columns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`), values(m)...)
+		switch {
+		case err != nil && repo.db.dialect.uniqueViolation(err):
+			return subscription.ErrAlreadyExists
+		case err != nil:
+			return fmt.Errorf("failed to insert subscription: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// versioned increments the version of m if the change made to it, returning err, succeeded
+func versioned(m *subscription.Model, err error) error {
+
+	if err != nil {
+		return err
+	}
+
+	m.NextVersion()
+
+	return nil
+}
+
+// mutate subscription with fn inside a transaction holding the row
+func (repo *Repository) mutate(ctx context.Context, msisdn *string, fn func(m *subscription.Model, now time.Time) error) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
+	}
+
+	var result *subscription.Model
+
+	err := repo.db.tx(ctx, func(tx *dbsql.Tx) error {
+
+		m, err := repo.get(ctx, tx, *msisdn, repo.db.dialect.forUpdate)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(m, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, repo.db.dialect.rebind(`UPDATE subscriptions SET activate_at = ?, type = ?, status = ?, operator = ?, operator_checked_at = ?, operator_changed_at = ?, version = ? WHERE msisdn = ?`),
+			m.ActivateAt.UTC(), *m.Type, string(*m.Status), m.Operator, utc(m.OperatorCheckedAt), utc(m.OperatorChangedAt), *m.Version, *m.MSISDN)
+		if err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		result = m
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (repo *Repository) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	if m == nil {
+		return nil, errors.New("no subscription provided")
+	}
+
+	return repo.mutate(ctx, m.MSISDN, func(sub *subscription.Model, now time.Time) error {
+		return versioned(sub, sub.Amend(m, now))
+	})
+}
+
+func (repo *Repository) Activate(ctx context.Context, msisdn *string, now time.Time) (*subscription.Model, error) {
+	return repo.mutate(ctx, msisdn, func(sub *subscription.Model, _ time.Time) error {
+		return versioned(sub, sub.Fire(subscription.EventActivate, now))
+	})
+}
+
+func (repo *Repository) TogglePaused(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+	return repo.mutate(ctx, msisdn, func(sub *subscription.Model, now time.Time) error {
+		if err := sub.CheckVersion(expected); err != nil {
+			return err
+		}
+		return versioned(sub, sub.TogglePaused(now))
+	})
+}
+
+func (repo *Repository) Cancel(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+	return repo.mutate(ctx, msisdn, func(sub *subscription.Model, now time.Time) error {
+		if err := sub.CheckVersion(expected); err != nil {
+			return err
+		}
+		return versioned(sub, sub.Fire(subscription.EventCancel, now))
+	})
+}
+
+// SetOperator verified at checkedAt, the version is only incremented if the operator changed
+func (repo *Repository) SetOperator(ctx context.Context, msisdn *string, operator *string, checkedAt time.Time) (*subscription.Model, error) {
+	return repo.mutate(ctx, msisdn, func(sub *subscription.Model, now time.Time) error {
+		if sub.SetOperator(operator, checkedAt.UTC()) {
+			sub.NextVersion()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,298 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/repotest"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(context.Background(), SQLite, filepath.Join(t.TempDir(), "subscriptions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestRepository(t *testing.T) {
+
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	// migrations are only applied once
+	if err := db.migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	subType := "PBX"
+	operator := "Tele2 Sverige AB"
+
+	verifiedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	created, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType, Operator: &operator, OperatorCheckedAt: &verifiedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *created.Status != subscription.StatusPending {
+		t.Fatalf("expected created subscription to be pending, got: %s", *created.Status)
+	}
+
+	if _, err := repo.Create(ctx, created); !errors.Is(err, subscription.ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got: %v", err)
+	}
+
+	if _, err := repo.TogglePaused(ctx, &msisdn, nil); !errors.Is(err, subscription.ErrInvalidTransition) {
+		t.Fatalf("expected pausing pending subscription to be invalid, got: %v", err)
+	}
+
+	past := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	stale := *created.Version - 1
+	if _, err := repo.Update(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &past, Type: &subType, Version: &stale}); !errors.Is(err, subscription.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got: %v", err)
+	}
+
+	updated, err := repo.Update(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &past, Type: &subType, Version: created.Version})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updated.Status != subscription.StatusActivated {
+		t.Fatalf("expected rescheduled subscription to be activated, got: %s", *updated.Status)
+	}
+
+	if _, err := repo.TogglePaused(ctx, &msisdn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Cancel(ctx, &msisdn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.TogglePaused(ctx, &msisdn, nil); !errors.Is(err, subscription.ErrInvalidTransition) {
+		t.Fatalf("expected pausing cancelled subscription to be invalid, got: %v", err)
+	}
+
+	got, err := repo.Get(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *got.Status != subscription.StatusCancelled || !got.ActivateAt.Equal(past) || *got.Operator != operator || *got.Version != 4 {
+		t.Fatalf("unexpected subscription: %s %s %s version %d", *got.Status, got.ActivateAt, *got.Operator, *got.Version)
+	}
+
+	checkedAt := time.Now().UTC().Truncate(time.Second)
+	ported := "Telia Sverige AB"
+
+	for i, expected := range []int64{5, 5} {
+		set, err := repo.(subscription.OperatorRecorder).SetOperator(ctx, &msisdn, &ported, checkedAt.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *set.Version != expected {
+			t.Fatalf("expected version %d after setting operator %d times, got: %d", expected, i+1, *set.Version)
+		}
+	}
+
+	got, err = repo.Get(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *got.Operator != ported || !got.OperatorCheckedAt.Equal(checkedAt.Add(time.Minute)) {
+		t.Fatalf("expected operator %s checked at %s, got: %s at %v", ported, checkedAt.Add(time.Minute), *got.Operator, got.OperatorCheckedAt)
+	}
+
+	if !got.OperatorChangedAt.Equal(checkedAt) {
+		t.Fatalf("expected operator changed at %s, got: %v", checkedAt, got.OperatorChangedAt)
+	}
+
+	for since, expected := range map[time.Duration]int{0: 1, time.Second: 0} {
+		at := checkedAt.Add(since)
+		page, err := repo.List(ctx, &subscription.Query{OperatorChangedSince: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Subscriptions) != expected {
+			t.Fatalf("expected %d subscriptions with operator changed since %s, got: %d", expected, at, len(page.Subscriptions))
+		}
+	}
+
+	for before, expected := range map[time.Duration]int{time.Minute: 0, time.Minute + time.Second: 1} {
+		at := checkedAt.Add(before)
+		page, err := repo.List(ctx, &subscription.Query{OperatorCheckedBefore: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Subscriptions) != expected {
+			t.Fatalf("expected %d subscriptions with operator checked before %s, got: %d", expected, at, len(page.Subscriptions))
+		}
+	}
+
+	later := checkedAt.Add(time.Hour)
+	if page, err := repo.List(ctx, &subscription.Query{OperatorCheckedBefore: &later, NotFinal: true}); err != nil || len(page.Subscriptions) != 0 {
+		t.Fatalf("expected the cancelled subscription not to match, got: %v, %v", page, err)
+	}
+
+	unknown := "8-0"
+	if _, err := repo.Get(ctx, &unknown); !errors.Is(err, subscription.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+
+	page, err := repo.List(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got: %d", len(page.Subscriptions))
+	}
+}
+
+func TestHistoryRepository(t *testing.T) {
+
+	repo, err := NewHistoryRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.History(t, repo)
+}
+
+func TestAtomically(t *testing.T) {
+
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	repo, err := NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := NewHistoryRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	subType := "PBX"
+	failed := errors.New("failed")
+
+	err = db.Atomically(ctx, func(ctx context.Context) error {
+		created, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+		if err != nil {
+			return err
+		}
+		if err := history.Append(ctx, &subscription.HistoryEntry{MSISDN: msisdn, Action: subscription.ActionCreated, ToStatus: created.Status, Timestamp: time.Now().UTC()}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected error of fn, got: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, &msisdn); !errors.Is(err, subscription.ErrNotFound) {
+		t.Fatalf("expected subscription to be rolled back, got: %v", err)
+	}
+
+	page, err := history.History(ctx, &msisdn, nil, 10)
+	if err != nil || len(page.Entries) != 0 {
+		t.Fatalf("expected history to be rolled back, got: %v, %v", page, err)
+	}
+
+	// a row inserted by another instance after the existence check is reported as existing
+	if _, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO subscriptions (msisdn, activate_at, type, status, version) VALUES (?, ?, ?, ?, ?)`, msisdn, activateAt, subType, subscription.StatusPending, 1)
+	if err == nil || !db.dialect.uniqueViolation(err) {
+		t.Fatalf("expected unique violation, got: %v", err)
+	}
+}
+
+func TestRepositoryList(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo, err := NewRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	subType := "CELL"
+
+	for i, offset := range []int{3, -1, 2, -2, 3} {
+		msisdn := fmt.Sprintf("8-%d", i)
+		activateAt := now.Add(time.Duration(offset) * time.Hour)
+		if _, err := repo.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := subscription.StatusPending
+	q := &subscription.Query{Status: &pending, Sort: subscription.SortActivateAtDesc, Limit: 2}
+
+	var got []string
+	for {
+		page, err := repo.List(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range page.Subscriptions {
+			got = append(got, *m.MSISDN)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	if expected := "[8-4 8-0 8-2]"; fmt.Sprint(got) != expected {
+		t.Fatalf("expected: %s, got: %v", expected, got)
+	}
+
+	for expected, msisdns := range map[int][]string{2: {"8-1", "8-3", "8-9"}, 0: {}} {
+		page, err := repo.List(ctx, &subscription.Query{MSISDNs: msisdns})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Subscriptions) != expected {
+			t.Fatalf("expected %d subscriptions of %v, got: %d", expected, msisdns, len(page.Subscriptions))
+		}
+	}
+
+	counts, err := repo.(subscription.Counter).Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Status < counts[j].Status })
+
+	if expected := "[{activated CELL 2} {pending CELL 3}]"; fmt.Sprint(counts) != expected {
+		t.Fatalf("expected counts: %s, got: %v", expected, counts)
+	}
+}
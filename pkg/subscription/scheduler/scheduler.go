@@ -0,0 +1,340 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// Actor recorded for changes made by the scheduler
+const Actor = "scheduler"
+
+// RetryDelay before a failed activation is attempted again
+var RetryDelay = 30 * time.Second
+
+// RescanInterval the repository is scanned at for due pending subscriptions created or updated
+// elsewhere, e.g. by another instance sharing the database
+var RescanInterval = time.Minute
+
+type entry struct {
+	msisdn string
+	at     time.Time
+}
+
+type queue []entry
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(entry)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// Scheduler activates pending subscriptions once their activate_at is reached.
+// It wraps a subscription.Repository so creates and updates passing through it are tracked,
+// others are found by re-scanning the repository every RescanInterval.
+type Scheduler struct {
+	subscription.Repository
+	clock     clock.Clock
+	rescan    time.Duration
+	queue     queue
+	scheduled map[string]time.Time
+	wake      chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+	sync.Mutex
+}
+
+// New scheduler activating subscriptions through repo
+func New(repo subscription.Repository, clk clock.Clock) *Scheduler {
+	return &Scheduler{
+		Repository: repo,
+		clock:      clk,
+		rescan:     RescanInterval,
+		scheduled:  map[string]time.Time{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Start scheduler after re-scanning the repository for pending subscriptions, it runs until ctx
+// is done or it is stopped
+func (s *Scheduler) Start(ctx context.Context) error {
+
+	pending := subscription.StatusPending
+	if err := s.scan(ctx, &subscription.Query{Status: &pending}, s.track); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.Unlock()
+
+	go s.run(ctx)
+
+	return nil
+}
+
+// scan the repository for subscriptions matching q, passing each to fn
+func (s *Scheduler) scan(ctx context.Context, q *subscription.Query, fn func(m *subscription.Model)) error {
+
+	q.Limit = subscription.MaxLimit
+
+	for {
+		page, err := s.Repository.List(ctx, q)
+		if err != nil {
+			return fmt.Errorf("failed to scan subscriptions for scheduler: %w", err)
+		}
+
+		for _, sub := range page.Subscriptions {
+			fn(sub)
+		}
+
+		if page.NextCursor == nil {
+			return nil
+		}
+
+		q.Cursor = page.NextCursor
+	}
+}
+
+// rescanDue tracks the due pending subscriptions not tracked yet, those tracked are already
+// activated or retried in time
+func (s *Scheduler) rescanDue(ctx context.Context) error {
+
+	pending, now := subscription.StatusPending, s.clock.Now()
+
+	return s.scan(ctx, &subscription.Query{Status: &pending, ActivateBefore: &now}, func(m *subscription.Model) {
+
+		s.Lock()
+		_, ok := s.scheduled[*m.MSISDN]
+		s.Unlock()
+
+		if !ok {
+			s.track(m)
+		}
+	})
+}
+
+// Stop scheduler and wait for an in progress activation to finish
+func (s *Scheduler) Stop() {
+
+	s.Lock()
+	cancel, done := s.cancel, s.done
+	s.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// Running reports if the scheduler loop has been started and not stopped
+func (s *Scheduler) Running() bool {
+
+	s.Lock()
+	done := s.done
+	s.Unlock()
+
+	if done == nil {
+		return false
+	}
+
+	select {
+	case <-done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *Scheduler) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	result, err := s.Repository.Create(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	s.track(result)
+
+	return result, nil
+}
+
+func (s *Scheduler) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	result, err := s.Repository.Update(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	s.track(result)
+
+	return result, nil
+}
+
+func (s *Scheduler) track(m *subscription.Model) {
+
+	if m == nil || m.MSISDN == nil || m.ActivateAt == nil || m.Status == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if *m.Status != subscription.StatusPending {
+		delete(s.scheduled, *m.MSISDN)
+		return
+	}
+
+	if at, ok := s.scheduled[*m.MSISDN]; ok && at.Equal(*m.ActivateAt) {
+		return
+	}
+
+	s.schedule(*m.MSISDN, *m.ActivateAt)
+}
+
+// schedule expects the lock to be held
+func (s *Scheduler) schedule(msisdn string, at time.Time) {
+
+	s.scheduled[msisdn] = at
+	heap.Push(&s.queue, entry{msisdn: msisdn, at: at})
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) next() (time.Time, bool) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+
+	return s.queue[0].at, true
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+
+	defer close(s.done)
+
+	var rescan <-chan time.Time
+	if s.rescan > 0 {
+		rescan = s.clock.After(s.rescan)
+	}
+
+	for {
+		var due <-chan time.Time
+		if at, ok := s.next(); ok {
+			due = s.clock.After(at.Sub(s.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-due:
+			s.activateDue(ctx)
+		case <-rescan:
+			if err := s.rescanDue(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to rescan subscriptions, retrying", "delay", s.rescan, "error", err)
+			}
+			rescan = s.clock.After(s.rescan)
+		}
+	}
+}
+
+func (s *Scheduler) popDue(now time.Time) []entry {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var result []entry
+
+	for len(s.queue) > 0 && !s.queue[0].at.After(now) {
+		e := heap.Pop(&s.queue).(entry)
+		// skip entries superseded by a later create or update
+		if at, ok := s.scheduled[e.msisdn]; !ok || !at.Equal(e.at) {
+			continue
+		}
+		delete(s.scheduled, e.msisdn)
+		result = append(result, e)
+	}
+
+	return result
+}
+
+func (s *Scheduler) activateDue(ctx context.Context) {
+
+	ctx = reqctx.WithActor(ctx, Actor)
+	now := s.clock.Now()
+
+	for _, e := range s.popDue(now) {
+
+		msisdn := e.msisdn
+
+		_, err := s.Repository.Activate(ctx, &msisdn, now)
+		switch {
+		case err == nil, errors.Is(err, subscription.ErrNotFound):
+		case errors.Is(err, subscription.ErrInvalidTransition):
+			// changed since it was scheduled, or not due by the time stored with it
+			s.retrack(ctx, msisdn)
+		default:
+			slog.ErrorContext(ctx, "failed to activate subscription, retrying", "msisdn", msisdn, "delay", RetryDelay, "error", err)
+			s.retry(msisdn)
+		}
+	}
+}
+
+// retrack subscription at its activate_at if it is still pending
+func (s *Scheduler) retrack(ctx context.Context, msisdn string) {
+
+	sub, err := s.Repository.Get(ctx, &msisdn)
+	switch {
+	case errors.Is(err, subscription.ErrNotFound):
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "failed to get subscription not activated, retrying", "msisdn", msisdn, "delay", RetryDelay, "error", err)
+		s.retry(msisdn)
+		return
+	}
+
+	// a pending subscription that was due and still is not activated is retried later instead of
+	// spinning on it
+	if *sub.Status == subscription.StatusPending && !sub.ActivateAt.After(s.clock.Now()) {
+		s.retry(msisdn)
+		return
+	}
+
+	s.track(sub)
+}
+
+// retry activation after RetryDelay unless it has been scheduled since
+func (s *Scheduler) retry(msisdn string) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.scheduled[msisdn]; !ok {
+		s.schedule(msisdn, s.clock.Now().Add(RetryDelay))
+	}
+}
@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+type stubRepository struct {
+	subscription.Repository
+	clock     clock.Clock
+	existing  []*subscription.Model
+	activated chan string
+	sync.Mutex
+}
+
+func (repo *stubRepository) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+	repo.Lock()
+	defer repo.Unlock()
+	page := &subscription.Page{}
+	for _, sub := range repo.existing {
+		if q.Matches(sub) {
+			page.Subscriptions = append(page.Subscriptions, sub)
+		}
+	}
+	return page, nil
+}
+
+func (repo *stubRepository) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+	return m, m.InitStatus(repo.clock.Now())
+}
+
+func (repo *stubRepository) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+	status := subscription.StatusPending
+	m.Status = &status
+	return m, nil
+}
+
+func (repo *stubRepository) Get(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+	repo.Lock()
+	defer repo.Unlock()
+	for _, sub := range repo.existing {
+		if *sub.MSISDN == *msisdn {
+			result := *sub
+			return &result, nil
+		}
+	}
+	return nil, subscription.ErrNotFound
+}
+
+func (repo *stubRepository) Activate(ctx context.Context, msisdn *string, now time.Time) (*subscription.Model, error) {
+	// subscriptions only created through the scheduler are activated whenever they are attempted
+	if err := repo.activate(*msisdn, now); err != nil {
+		return nil, err
+	}
+	repo.activated <- *msisdn
+	return nil, nil
+}
+
+func (repo *stubRepository) activate(msisdn string, now time.Time) error {
+	repo.Lock()
+	defer repo.Unlock()
+	for _, sub := range repo.existing {
+		if *sub.MSISDN == msisdn {
+			return sub.Fire(subscription.EventActivate, now)
+		}
+	}
+	return nil
+}
+
+func (repo *stubRepository) add(m *subscription.Model) {
+	repo.Lock()
+	defer repo.Unlock()
+	repo.existing = append(repo.existing, m)
+}
+
+func (repo *stubRepository) reschedule(msisdn string, at time.Time) {
+	repo.Lock()
+	defer repo.Unlock()
+	for _, sub := range repo.existing {
+		if *sub.MSISDN == msisdn {
+			sub.ActivateAt = &at
+		}
+	}
+}
+
+func newModel(msisdn string, at time.Time, status subscription.Status) *subscription.Model {
+	return &subscription.Model{MSISDN: &msisdn, ActivateAt: &at, Status: &status}
+}
+
+func expectActivated(t *testing.T, repo *stubRepository, msisdn string) {
+	t.Helper()
+	select {
+	case got := <-repo.activated:
+		if got != msisdn {
+			t.Fatalf("expected %s to be activated, got: %s", msisdn, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected %s to be activated", msisdn)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	repo := &stubRepository{
+		clock: clk,
+		existing: []*subscription.Model{
+			newModel("8-1", now.Add(time.Hour), subscription.StatusPending),
+			newModel("8-2", now.Add(-time.Hour), subscription.StatusActivated),
+		},
+		activated: make(chan string, 10),
+	}
+
+	s := New(repo, clk)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// pending subscription found when re-scanning the repository
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	expectActivated(t, repo, "8-1")
+
+	// created subscription rescheduled to a later date before it was due
+	if _, err := s.Create(ctx, newModel("8-3", clk.Now().Add(time.Hour), "")); err != nil {
+		t.Fatal(err)
+	}
+	clk.BlockUntil(1)
+
+	if _, err := s.Update(ctx, newModel("8-3", clk.Now().Add(3*time.Hour), "")); err != nil {
+		t.Fatal(err)
+	}
+	clk.BlockUntil(2)
+
+	clk.Advance(time.Hour)
+	clk.Advance(2 * time.Hour)
+	expectActivated(t, repo, "8-3")
+
+	s.Stop()
+
+	if len(repo.activated) != 0 {
+		t.Fatalf("expected no more activations, got: %s", <-repo.activated)
+	}
+}
+
+func TestSchedulerRetracks(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	repo := &stubRepository{
+		clock:     clk,
+		existing:  []*subscription.Model{newModel("8-1", now.Add(time.Hour), subscription.StatusPending)},
+		activated: make(chan string, 10),
+	}
+
+	s := New(repo, clk)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// rescheduled through another instance, so not due when this one gets to it
+	repo.reschedule("8-1", now.Add(2*time.Hour))
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+
+	// tracked again at the time stored with it
+	clk.BlockUntil(1)
+	if len(repo.activated) != 0 {
+		t.Fatalf("expected no activation before it is due, got: %s", <-repo.activated)
+	}
+
+	clk.Advance(time.Hour)
+	expectActivated(t, repo, "8-1")
+}
+
+func TestSchedulerRescans(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+
+	repo := &stubRepository{clock: clk, activated: make(chan string, 10)}
+
+	s := New(repo, clk)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// created through another instance after this one started
+	repo.add(newModel("8-1", now.Add(time.Second), subscription.StatusPending))
+
+	clk.BlockUntil(1)
+	clk.Advance(RescanInterval)
+	expectActivated(t, repo, "8-1")
+
+	// activated subscriptions are not found again
+	clk.BlockUntil(1)
+	clk.Advance(RescanInterval)
+	clk.BlockUntil(1)
+
+	s.Lock()
+	scheduled := len(s.scheduled)
+	s.Unlock()
+
+	if scheduled != 0 || len(repo.activated) != 0 {
+		t.Fatalf("expected no more activations, got: %s", <-repo.activated)
+	}
+}
+
+func TestSchedulerStopsWithContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clk := clock.NewFake(time.Now())
+
+	s := New(&stubRepository{clock: clk, activated: make(chan string, 1)}, clk)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	select {
+	case <-s.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected scheduler to stop once its context is done")
+	}
+}
@@ -4,148 +4,677 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/rgynn/subscription-api/pkg/clock"
 	"github.com/rgynn/subscription-api/pkg/config"
 	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/operator/cache"
+	"github.com/rgynn/subscription-api/pkg/operator/fallback"
+	"github.com/rgynn/subscription-api/pkg/operator/numberplan"
 	"github.com/rgynn/subscription-api/pkg/operator/pts"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/resilience"
 	"github.com/rgynn/subscription-api/pkg/subscription"
 	"github.com/rgynn/subscription-api/pkg/subscription/repo/mem"
+	subsql "github.com/rgynn/subscription-api/pkg/subscription/repo/sql"
 )
 
 // Service for subscriptions
 type Service struct {
-	mem       subscription.Repository
-	operators operator.Repository
+	subscriptions subscription.Repository
+	history       subscription.HistoryRepository
+	operators     operator.Repository
+	// source operators are verified against when refreshed, bypassing any cache in operators
+	source operator.Repository
+	events subscription.EventBus
+	// outbox events are enqueued to in the transaction of their change, nil if none
+	outbox subscription.Outbox
+	db     *subsql.DB
+	// pts the operators are looked up from, nil if they come from elsewhere
+	pts *pts.Repository
+	// cache of the operators looked up from pts, nil if not cached
+	cache *cache.Repository
+	// plan the operators are looked up from or fall back to, nil if not configured
+	plan *numberplan.Repository
+	// locks serialize changes to the same msisdn so history is recorded in order
+	locks [64]sync.Mutex
+	// publishing is taken over from locks once a change is committed, so its events are published
+	// in order while the next change is made
+	publishing [64]sync.Mutex
 }
 
-func NewServiceFromConfig(cfg *config.Config) (*Service, error) {
+// NewService for subscriptions stored in subscriptions and history, looking up operators from operators
+func NewService(subscriptions subscription.Repository, history subscription.HistoryRepository, operators operator.Repository, events subscription.EventBus) *Service {
+	return &Service{
+		subscriptions: subscriptions,
+		history:       history,
+		operators:     operators,
+		source:        operators,
+		events:        events,
+	}
+}
 
-	memrepo, err := mem.NewRepository()
-	if err != nil {
-		return nil, fmt.Errorf("failed to inititalize in memory repository for subscriptions")
+// SetOutbox events are enqueued to in the transaction of the change they describe, before the
+// service is used
+func (svc *Service) SetOutbox(outbox subscription.Outbox) {
+	svc.outbox = outbox
+}
+
+func NewServiceFromConfig(cfg *config.Config, events subscription.EventBus) (*Service, error) {
+
+	svc := &Service{events: events}
+
+	switch cfg.Database {
+	case "memory":
+		memrepo, err := mem.NewRepository()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inititalize in memory repository for subscriptions")
+		}
+
+		historyrepo, err := mem.NewHistoryRepository()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inititalize in memory repository for subscription history")
+		}
+
+		svc.subscriptions, svc.history = memrepo, historyrepo
+	default:
+		dialect, err := subsql.DialectByName(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+
+		db, err := subsql.Open(context.Background(), dialect, cfg.DatabaseDSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database for subscriptions: %w", err)
+		}
+
+		sqlrepo, err := subsql.NewRepository(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to inititalize sql repository for subscriptions: %w", err)
+		}
+
+		historyrepo, err := subsql.NewHistoryRepository(db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to inititalize sql repository for subscription history: %w", err)
+		}
+
+		svc.subscriptions, svc.history, svc.db = sqlrepo, historyrepo, db
 	}
 
-	operatorsrepo, err := pts.NewRepository(cfg.ClientTimeout, cfg.PTSURL)
+	operatorsrepo, err := svc.operatorsFromConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inititalize in operator repository for subscriptions")
+		svc.Close()
+		return nil, err
 	}
 
-	return &Service{
-		mem:       memrepo,
-		operators: operatorsrepo,
-	}, nil
+	svc.operators = operatorsrepo
+
+	// reloading starts last, so nothing is left running if the service cannot be constructed
+	if svc.plan != nil && cfg.NumberPlanReloadInterval > 0 {
+		if err := svc.plan.Start(context.Background()); err != nil {
+			svc.Close()
+			return nil, fmt.Errorf("failed to start number plan reloading: %w", err)
+		}
+	}
+
+	slog.Info("subscription storage opened", "database", cfg.Database, "operator_source", cfg.OperatorSource, "operator_cache_ttl", cfg.OperatorCacheTTL)
+
+	return svc, nil
 }
 
-func (svc *Service) List(ctx context.Context) ([]*subscription.Model, error) {
+// operatorsFromConfig sets up the source of operators, pts or the number plan, and returns the
+// repository to look them up from. Only pts is cached, answers of the number plan are not so a
+// fallback is not kept once pts is back. Refreshes verify against pts only, the number plan does
+// not know about ported numbers.
+func (svc *Service) operatorsFromConfig(cfg *config.Config) (operator.Repository, error) {
+
+	if cfg.OperatorSource == "numberplan" || cfg.NumberPlanFallback {
+		plan, err := numberplan.NewRepositoryFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inititalize number plan for subscriptions: %w", err)
+		}
+
+		svc.plan = plan
+	}
+
+	if cfg.OperatorSource == "numberplan" {
+		svc.source = svc.plan
+		return svc.plan, nil
+	}
 
-	result, err := svc.mem.List(ctx)
+	ptsrepo, err := pts.NewRepositoryFromConfig(cfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to inititalize in operator repository for subscriptions")
 	}
 
-	for i, sub := range result {
-		op, err := svc.operators.Get(ctx, sub.MSISDN)
+	svc.pts, svc.source = ptsrepo, ptsrepo
+
+	var operators operator.Repository = ptsrepo
+
+	if cfg.OperatorCacheTTL > 0 {
+		svc.cache, err = cache.NewRepository(operators, cfg.OperatorCacheTTL, cfg.OperatorCacheNegativeTTL, clock.New())
 		if err != nil {
-			return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %s", *sub.MSISDN, err)
+			return nil, fmt.Errorf("failed to inititalize operator cache for subscriptions: %w", err)
+		}
+		operators = svc.cache
+	}
+
+	if svc.plan == nil {
+		return operators, nil
+	}
+
+	return fallback.NewRepository(operators, svc.plan)
+}
+
+// Close storage used by the service
+func (svc *Service) Close() error {
+
+	if svc.plan != nil {
+		svc.plan.Stop()
+	}
+
+	if svc.db == nil {
+		return nil
+	}
+
+	return svc.db.Close()
+}
+
+// DB subscriptions are stored in, nil if they are kept in memory
+func (svc *Service) DB() *subsql.DB {
+	return svc.db
+}
+
+// CheckStorage reports if the database subscriptions are stored in is reachable
+func (svc *Service) CheckStorage(ctx context.Context) error {
+
+	if svc.db == nil {
+		return nil
+	}
+
+	return svc.db.PingContext(ctx)
+}
+
+// CheckOperators reports if operators can be looked up, failing while the breaker guarding pts is open
+// and there is no number plan to fall back to. PTS itself is not called, probes should not count against
+// its rate limit.
+func (svc *Service) CheckOperators(ctx context.Context) error {
+
+	planned := svc.plan != nil && svc.plan.Version() != ""
+
+	if svc.pts == nil {
+		if svc.plan != nil && !planned {
+			return errors.New("no number plan imported")
 		}
-		result[i].Operator = op
+		return nil
+	}
+
+	if state := svc.pts.BreakerState(); state == resilience.StateOpen && !planned {
+		return fmt.Errorf("pts circuit breaker is %s", state)
+	}
+
+	return nil
+}
+
+// Operators used to look up the operator of subscriptions
+func (svc *Service) Operators() operator.Repository {
+	return svc.operators
+}
+
+// Count subscriptions by status and type
+func (svc *Service) Count(ctx context.Context) ([]subscription.Count, error) {
+
+	counter, ok := svc.subscriptions.(subscription.Counter)
+	if !ok {
+		return nil, errors.New("subscription repository can not count subscriptions")
 	}
 
-	return result, nil
+	return counter.Count(ctx)
+}
+
+// List subscriptions matching q, operators are served from storage so reads do not depend on pts
+func (svc *Service) List(ctx context.Context, q *subscription.Query) (*subscription.Page, error) {
+	return svc.subscriptions.List(ctx, q)
 }
 
+// Get subscription, its operator is served from storage so reads do not depend on pts
 func (svc *Service) Get(ctx context.Context, msisdn *string) (*subscription.Model, error) {
 
 	if msisdn == nil {
 		return nil, errors.New("no msisdn provided")
 	}
 
-	result, err := svc.mem.Get(ctx, msisdn)
-	if err != nil {
+	return svc.subscriptions.Get(ctx, msisdn)
+}
+
+func (svc *Service) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+
+	if err := m.ValidForSave(); err != nil {
 		return nil, err
 	}
 
-	op, err := svc.operators.Get(ctx, msisdn)
+	// looked up before locking, so a slow source of operators does not hold up other changes
+	op, verified, err := svc.resolveOperator(ctx, m.MSISDN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %s", *msisdn, err)
+		return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %w", *m.MSISDN, err)
 	}
 
-	result.Operator = op
+	return svc.apply(ctx, *m.MSISDN, func(ctx context.Context, now time.Time) (change, error) {
+
+		// an operator from a fallback is left unverified, so the refresher verifies it first thing
+		if verified {
+			m.SetOperator(op, now)
+		} else {
+			m.Operator = op
+		}
+
+		result, err := svc.subscriptions.Create(ctx, m)
 
-	return result, nil
+		return change{action: subscription.ActionCreated, after: result}, err
+	})
 }
 
-func (svc *Service) Create(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+func (svc *Service) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
 
-	if m == nil {
-		return nil, errors.New("no subscription provided")
+	if err := m.ValidForUpdate(); err != nil {
+		return nil, err
 	}
 
-	if err := m.ValidForSave(); err != nil {
-		return nil, fmt.Errorf("%s: %w", err.Error(), subscription.ErrNotValid)
+	return svc.apply(ctx, *m.MSISDN, func(ctx context.Context, now time.Time) (change, error) {
+
+		before, err := svc.subscriptions.Get(ctx, m.MSISDN)
+		if err != nil {
+			return change{}, err
+		}
+
+		sub, err := svc.subscriptions.Update(ctx, m)
+
+		return change{action: subscription.ActionUpdated, before: before, after: sub}, err
+	})
+}
+
+func (svc *Service) Activate(ctx context.Context, msisdn *string, at time.Time) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
 	}
 
-	op, err := svc.operators.Get(ctx, m.MSISDN)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %s", *m.MSISDN, err)
+	return svc.apply(ctx, *msisdn, func(ctx context.Context, now time.Time) (change, error) {
+
+		before, err := svc.subscriptions.Get(ctx, msisdn)
+		if err != nil {
+			return change{}, err
+		}
+
+		sub, err := svc.subscriptions.Activate(ctx, msisdn, at)
+
+		return change{action: subscription.ActionActivated, before: before, after: sub}, err
+	})
+}
+
+func (svc *Service) TogglePaused(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
 	}
 
-	m.Operator = op
+	return svc.apply(ctx, *msisdn, func(ctx context.Context, now time.Time) (change, error) {
 
-	if err := m.UpdateStatus(nil); err != nil {
-		return nil, err
+		before, err := svc.subscriptions.Get(ctx, msisdn)
+		if err != nil {
+			return change{}, err
+		}
+
+		sub, err := svc.subscriptions.TogglePaused(ctx, msisdn, expected)
+		if err != nil {
+			return change{}, err
+		}
+
+		action := subscription.ActionResumed
+		if *sub.Status == subscription.StatusPaused {
+			action = subscription.ActionPaused
+		}
+
+		return change{action: action, before: before, after: sub}, nil
+	})
+}
+
+// Pause subscription if it is activated
+func (svc *Service) Pause(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+	return svc.setPaused(ctx, msisdn, expected, subscription.EventPause)
+}
+
+// Resume subscription if it is paused
+func (svc *Service) Resume(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+	return svc.setPaused(ctx, msisdn, expected, subscription.EventResume)
+}
+
+// setPaused by firing event, EventPause or EventResume, checked under the lock of the subscription
+func (svc *Service) setPaused(ctx context.Context, msisdn *string, expected *int64, event subscription.Event) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
 	}
 
-	result, err := svc.mem.Create(ctx, m)
-	if err != nil {
-		return nil, err
+	return svc.apply(ctx, *msisdn, func(ctx context.Context, now time.Time) (change, error) {
+
+		before, err := svc.subscriptions.Get(ctx, msisdn)
+		if err != nil {
+			return change{}, err
+		}
+
+		if err := before.CheckVersion(expected); err != nil {
+			return change{}, err
+		}
+
+		if err := before.Can(event, now); err != nil {
+			return change{}, err
+		}
+
+		// toggled from the version checked, another instance changing it meanwhile is a conflict
+		sub, err := svc.subscriptions.TogglePaused(ctx, msisdn, before.Version)
+		if err != nil {
+			return change{}, err
+		}
+
+		action := subscription.ActionPaused
+		if event == subscription.EventResume {
+			action = subscription.ActionResumed
+		}
+
+		return change{action: action, before: before, after: sub}, nil
+	})
+}
+
+func (svc *Service) Cancel(ctx context.Context, msisdn *string, expected *int64) (*subscription.Model, error) {
+
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
 	}
 
-	return result, nil
+	return svc.apply(ctx, *msisdn, func(ctx context.Context, now time.Time) (change, error) {
+
+		before, err := svc.subscriptions.Get(ctx, msisdn)
+		if err != nil {
+			return change{}, err
+		}
+
+		sub, err := svc.subscriptions.Cancel(ctx, msisdn, expected)
+
+		return change{action: subscription.ActionCancelled, before: before, after: sub}, err
+	})
 }
 
-func (svc *Service) Update(ctx context.Context, m *subscription.Model) (*subscription.Model, error) {
+// RefreshOperator of subscription, verifying it against the source of operators and storing it with
+// the time it was checked. A changed operator, a ported number, is recorded in the history of the
+// subscription and published as subscription.OperatorChanged. A number the source does not know
+// has the time it was checked stored and returns operator.ErrNotFound.
+func (svc *Service) RefreshOperator(ctx context.Context, msisdn *string) (*subscription.Model, error) {
 
-	if m == nil {
-		return nil, errors.New("no subscription provided")
+	if msisdn == nil {
+		return nil, errors.New("no msisdn provided")
 	}
 
-	if err := m.ValidForUpdate(); err != nil {
-		return nil, fmt.Errorf("%s: %w", err.Error(), subscription.ErrNotValid)
+	recorder, ok := svc.subscriptions.(subscription.OperatorRecorder)
+	if !ok {
+		return nil, errors.New("subscription repository can not store operators")
+	}
+
+	// no need to ask the source about subscriptions that do not exist
+	if _, err := svc.subscriptions.Get(ctx, msisdn); err != nil {
+		return nil, err
+	}
+
+	// looked up before locking, so a slow source of operators does not hold up other changes
+	op, lookupErr := svc.source.Get(ctx, msisdn)
+	if lookupErr != nil && !errors.Is(lookupErr, operator.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %w", *msisdn, lookupErr)
 	}
 
-	sub, err := svc.mem.Update(ctx, m)
+	var changed bool
+
+	sub, err := svc.apply(ctx, *msisdn, func(ctx context.Context, now time.Time) (change, error) {
+
+		before, err := svc.subscriptions.Get(ctx, msisdn)
+		if err != nil {
+			return change{}, err
+		}
+
+		// a number the source does not know keeps its operator, only the time it was checked is
+		// recorded so it is not asked about again until it is stale
+		if lookupErr != nil {
+			op = before.Operator
+		}
+
+		after, err := recorder.SetOperator(ctx, msisdn, op, now)
+		if err != nil || *after.Version == *before.Version {
+			// only the time it was checked moved, nothing to record
+			return change{after: after}, err
+		}
+
+		// correcting an operator that was never verified is not a ported number
+		action := subscription.ActionUpdated
+		if before.Verified() {
+			action = subscription.ActionOperatorChanged
+		}
+
+		changed = true
+
+		// recorded as detected at the time the operator was checked, as stored with the subscription
+		return change{action: action, before: before, after: after}, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	// the cache would keep serving the old operator until it expires
+	if changed && svc.cache != nil {
+		svc.cache.Forget(*msisdn)
+	}
+
+	if lookupErr != nil {
+		return nil, fmt.Errorf("failed to get operator info for msisdn: %s, error: %w", *msisdn, lookupErr)
+	}
+
 	return sub, nil
 }
 
-func (svc *Service) TogglePaused(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+func (svc *Service) History(ctx context.Context, msisdn *string, cursor *string, limit int) (*subscription.HistoryPage, error) {
 
 	if msisdn == nil {
 		return nil, errors.New("no msisdn provided")
 	}
 
-	sub, err := svc.mem.TogglePaused(ctx, msisdn)
-	if err != nil {
+	if _, err := svc.subscriptions.Get(ctx, msisdn); err != nil {
 		return nil, err
 	}
 
-	return sub, nil
+	return svc.history.History(ctx, msisdn, cursor, limit)
 }
 
-func (svc *Service) Cancel(ctx context.Context, msisdn *string) (*subscription.Model, error) {
+// resolveOperator of msisdn, reporting if it was verified against the source of operators
+func (svc *Service) resolveOperator(ctx context.Context, msisdn *string) (*string, bool, error) {
 
-	if msisdn == nil {
-		return nil, errors.New("no msisdn provided")
+	if resolver, ok := svc.operators.(operator.Resolver); ok {
+		return resolver.Resolve(ctx, msisdn)
 	}
 
-	sub, err := svc.mem.Cancel(ctx, msisdn)
+	op, err := svc.operators.Get(ctx, msisdn)
+
+	return op, err == nil, err
+}
+
+// lock msisdn for changes, returning the function unlocking it
+func (svc *Service) lock(msisdn string) func() {
+	return stripe(&svc.locks, msisdn)
+}
+
+// stripe of locks msisdn hashes to locked, returning the function unlocking it
+func stripe(locks *[64]sync.Mutex, msisdn string) func() {
+
+	h := fnv.New32a()
+	h.Write([]byte(msisdn))
+	mu := &locks[h.Sum32()%uint32(len(locks))]
+
+	mu.Lock()
+
+	return mu.Unlock
+}
+
+// change made to a subscription, recorded as action unless it is empty
+type change struct {
+	action        subscription.Action
+	before, after *subscription.Model
+}
+
+// apply the change made by fn at now, recording it in the history in the same transaction so no
+// change goes without its entry. Its events are published once it is committed.
+func (svc *Service) apply(ctx context.Context, msisdn string, fn func(ctx context.Context, now time.Time) (change, error)) (*subscription.Model, error) {
+
+	unlock := svc.lock(msisdn)
+
+	now := time.Now().UTC()
+
+	var (
+		c      change
+		events []subscription.DomainEvent
+	)
+
+	err := svc.atomically(ctx, func(ctx context.Context) error {
+
+		var err error
+		if c, err = fn(ctx, now); err != nil || c.action == "" {
+			return err
+		}
+
+		if err := svc.record(ctx, c, now); err != nil {
+			return err
+		}
+
+		meta := subscription.EventMeta{
+			MSISDN:     *c.after.MSISDN,
+			OccurredAt: now,
+			Actor:      reqctx.Actor(ctx),
+			RequestID:  reqctx.RequestID(ctx),
+		}
+
+		events = newEvents(meta, c.action, c.before, c.after)
+
+		if svc.outbox == nil {
+			return nil
+		}
+
+		if err := svc.outbox.Enqueue(ctx, events...); err != nil {
+			return fmt.Errorf("failed to enqueue events of %s: %w", c.action, err)
+		}
+
+		return nil
+	})
+
 	if err != nil {
+		unlock()
 		return nil, err
 	}
 
-	return sub, nil
+	if c.action == "" {
+		unlock()
+		return c.after, nil
+	}
+
+	// handed over before unlocking, so events are published in the order of the changes while a
+	// slow subscriber does not hold up the next change
+	defer stripe(&svc.publishing, msisdn)()
+	unlock()
+
+	// the change is committed, a caller going away must not drop its events
+	svc.publish(context.WithoutCancel(ctx), events...)
+
+	return c.after, nil
+}
+
+// atomically runs fn in a transaction of the database if subscriptions are stored in one
+func (svc *Service) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+
+	if svc.db == nil {
+		return fn(ctx)
+	}
+
+	return svc.db.Atomically(ctx, fn)
+}
+
+// record change made at now in the history of the subscription
+func (svc *Service) record(ctx context.Context, c change, now time.Time) error {
+
+	entry := &subscription.HistoryEntry{
+		MSISDN:    *c.after.MSISDN,
+		Action:    c.action,
+		ToStatus:  c.after.Status,
+		Changes:   subscription.Diff(c.before, c.after),
+		Timestamp: now,
+		Actor:     reqctx.Actor(ctx),
+		RequestID: reqctx.RequestID(ctx),
+	}
+
+	if c.before != nil {
+		entry.FromStatus = c.before.Status
+	}
+
+	if err := svc.history.Append(ctx, entry); err != nil {
+		return fmt.Errorf("failed to record history for msisdn: %s, error: %w", entry.MSISDN, err)
+	}
+
+	slog.DebugContext(ctx, "subscription change recorded", "msisdn", entry.MSISDN, "action", c.action, "actor", entry.Actor)
+
+	return nil
+}
+
+// publish events, failing to do so does not undo the change they describe
+func (svc *Service) publish(ctx context.Context, events ...subscription.DomainEvent) {
+
+	if svc.events == nil {
+		return
+	}
+
+	for _, e := range events {
+		if err := svc.events.Publish(ctx, e); err != nil {
+			slog.ErrorContext(ctx, "failed to publish event", "event", e.Type(), "msisdn", e.Metadata().MSISDN, "error", err)
+		}
+	}
+}
+
+// newEvents describing the change made by action
+func newEvents(meta subscription.EventMeta, action subscription.Action, before, after *subscription.Model) []subscription.DomainEvent {
+
+	var events []subscription.DomainEvent
+
+	switch action {
+	case subscription.ActionCreated:
+		created := *after
+		events = append(events, &subscription.SubscriptionCreated{EventMeta: meta, Subscription: &created})
+	case subscription.ActionUpdated:
+		if before.ActivateAt != nil && after.ActivateAt != nil && !before.ActivateAt.Equal(*after.ActivateAt) {
+			events = append(events, &subscription.ActivationDateChanged{EventMeta: meta, From: *before.ActivateAt, To: *after.ActivateAt})
+		}
+		if *before.Status == subscription.StatusPending && *after.Status == subscription.StatusActivated {
+			events = append(events, &subscription.Activated{EventMeta: meta})
+		}
+	case subscription.ActionActivated:
+		events = append(events, &subscription.Activated{EventMeta: meta})
+	case subscription.ActionPaused:
+		events = append(events, &subscription.Paused{EventMeta: meta})
+	case subscription.ActionResumed:
+		events = append(events, &subscription.Resumed{EventMeta: meta})
+	case subscription.ActionCancelled:
+		events = append(events, &subscription.Cancelled{EventMeta: meta, FromStatus: *before.Status})
+	case subscription.ActionOperatorChanged:
+		events = append(events, &subscription.OperatorChanged{EventMeta: meta, From: before.Operator, To: after.Operator})
+	}
+
+	return events
 }
@@ -0,0 +1,594 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/operator"
+	"github.com/rgynn/subscription-api/pkg/operator/cache"
+	"github.com/rgynn/subscription-api/pkg/reqctx"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/subscription/eventbus"
+	"github.com/rgynn/subscription-api/pkg/subscription/repo/mem"
+	subsql "github.com/rgynn/subscription-api/pkg/subscription/repo/sql"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+	webhooksql "github.com/rgynn/subscription-api/pkg/webhook/repo/sql"
+)
+
+type stubOperators struct {
+	name string
+	err  error
+}
+
+func (repo *stubOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	if repo.err != nil {
+		return nil, repo.err
+	}
+	name := repo.name
+	return &name, nil
+}
+
+// fallbackOperators answering as a fallback does while the source of operators is unavailable
+type fallbackOperators struct {
+	stubOperators
+}
+
+func (repo *fallbackOperators) Resolve(ctx context.Context, msisdn *string) (*string, bool, error) {
+	name, err := repo.Get(ctx, msisdn)
+	return name, false, err
+}
+
+// blockingOperators answering once released, reporting each lookup started on started
+type blockingOperators struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (repo *blockingOperators) Get(ctx context.Context, msisdn *string) (*string, error) {
+	repo.started <- struct{}{}
+	<-repo.release
+	name := "Tele2 Sverige AB"
+	return &name, nil
+}
+
+type recorder struct {
+	events []subscription.EventType
+	sync.Mutex
+}
+
+func (r *recorder) handle(ctx context.Context, e subscription.DomainEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e.Type())
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	memrepo, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	historyrepo, err := mem.NewHistoryRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operators := &stubOperators{name: "Tele2 Sverige AB"}
+
+	return &Service{
+		subscriptions: memrepo,
+		history:       historyrepo,
+		operators:     operators,
+		source:        operators,
+		events:        eventbus.New(),
+	}
+}
+
+func TestHistory(t *testing.T) {
+
+	svc := newTestService(t)
+	events := &recorder{}
+	svc.events.Subscribe("test", events.handle)
+	ctx := reqctx.WithRequestID(reqctx.WithActor(context.Background(), "support"), "req-1")
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(-time.Hour)
+	subType := "PBX"
+
+	if _, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.TogglePaused(ctx, &msisdn, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := svc.Cancel(ctx, &msisdn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := svc.History(ctx, &msisdn, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []subscription.Action{subscription.ActionCreated, subscription.ActionPaused, subscription.ActionResumed}
+	if len(page.Entries) != len(expected) || page.NextCursor == nil {
+		t.Fatalf("expected %d entries and a next cursor, got: %d entries, cursor: %v", len(expected), len(page.Entries), page.NextCursor)
+	}
+
+	for i, entry := range page.Entries {
+		if entry.Action != expected[i] || entry.Actor != "support" || entry.RequestID != "req-1" {
+			t.Fatalf("unexpected entry %d: %+v", i, entry)
+		}
+	}
+
+	if *page.Entries[1].FromStatus != subscription.StatusActivated || *page.Entries[1].ToStatus != subscription.StatusPaused {
+		t.Fatalf("expected pause from activated to paused, got: %s to %s", *page.Entries[1].FromStatus, *page.Entries[1].ToStatus)
+	}
+
+	page, err = svc.History(ctx, &msisdn, page.NextCursor, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Entries) != 1 || page.Entries[0].Action != subscription.ActionCancelled || page.NextCursor != nil {
+		t.Fatalf("expected last page with cancel entry, got: %d entries", len(page.Entries))
+	}
+
+	svc.events.Close()
+
+	expectedEvents := []subscription.EventType{
+		subscription.EventSubscriptionCreated,
+		subscription.EventPaused,
+		subscription.EventResumed,
+		subscription.EventCancelled,
+	}
+
+	if fmt.Sprint(events.events) != fmt.Sprint(expectedEvents) {
+		t.Fatalf("expected events: %v, got: %v", expectedEvents, events.events)
+	}
+}
+
+// failingOutbox enqueuing to outbox and then failing, while fail is set
+type failingOutbox struct {
+	subscription.Outbox
+	fail bool
+}
+
+func (o *failingOutbox) Enqueue(ctx context.Context, events ...subscription.DomainEvent) error {
+	if err := o.Outbox.Enqueue(ctx, events...); err != nil || !o.fail {
+		return err
+	}
+	return errors.New("outbox failed")
+}
+
+func TestOutbox(t *testing.T) {
+
+	ctx := context.Background()
+
+	db, err := subsql.Open(ctx, subsql.SQLite, filepath.Join(t.TempDir(), "subscriptions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlrepo, err := subsql.NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	historyrepo, err := subsql.NewHistoryRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := webhooksql.NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// never started and without an event bus, deliveries are only enqueued with the changes
+	d := webhook.NewDispatcher(webhooks, webhook.DefaultPolicy, clock.New())
+	outbox := &failingOutbox{Outbox: d}
+
+	svc := NewService(sqlrepo, historyrepo, &stubOperators{name: "Tele2 Sverige AB"}, nil)
+	svc.db = db
+	svc.SetOutbox(outbox)
+
+	url, secret := "https://example.com/hook", "a-secret-of-sixteen-chars"
+	if _, err := d.Create(ctx, &webhook.Model{URL: &url, Secret: &secret, EventTypes: []subscription.EventType{subscription.EventPaused}}); err != nil {
+		t.Fatal(err)
+	}
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(-time.Hour)
+	subType := "PBX"
+
+	if _, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	outbox.fail = true
+
+	if _, err := svc.Pause(ctx, &msisdn, nil); err == nil {
+		t.Fatal("expected pause to fail with the outbox")
+	}
+
+	if sub, err := svc.Get(ctx, &msisdn); err != nil || *sub.Status != subscription.StatusActivated {
+		t.Fatalf("expected pause to be rolled back, got: %v, %v", sub, err)
+	}
+
+	if unfinished, err := webhooks.Unfinished(ctx); err != nil || len(unfinished) != 0 {
+		t.Fatalf("expected no deliveries of a rolled back change, got: %d, %v", len(unfinished), err)
+	}
+
+	outbox.fail = false
+
+	if _, err := svc.Pause(ctx, &msisdn, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	unfinished, err := webhooks.Unfinished(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(unfinished) != 1 || unfinished[0].EventType != subscription.EventPaused {
+		t.Fatalf("expected a pending delivery of the paused event, got: %+v", unfinished)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(-time.Hour)
+	subType := "PBX"
+
+	if _, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Resume(ctx, &msisdn, nil); !errors.Is(err, subscription.ErrInvalidTransition) {
+		t.Fatalf("expected resuming an activated subscription to be invalid, got: %v", err)
+	}
+
+	sub, err := svc.Pause(ctx, &msisdn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a retried pause leaves the subscription paused
+	if _, err := svc.Pause(ctx, &msisdn, nil); !errors.Is(err, subscription.ErrInvalidTransition) {
+		t.Fatalf("expected pausing a paused subscription to be invalid, got: %v", err)
+	}
+
+	if _, err := svc.Resume(ctx, &msisdn, sub.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Pause(ctx, &msisdn, sub.Version); !errors.Is(err, subscription.ErrVersionConflict) {
+		t.Fatalf("expected version conflict, got: %v", err)
+	}
+
+	if sub, err = svc.Get(ctx, &msisdn); err != nil || *sub.Status != subscription.StatusActivated {
+		t.Fatalf("expected subscription to be activated, got: %v, %v", sub, err)
+	}
+}
+
+func TestLookupOutsideLock(t *testing.T) {
+
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	if _, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	blocking := &blockingOperators{started: make(chan struct{}), release: make(chan struct{})}
+	svc.operators, svc.source = blocking, blocking
+
+	created, refreshed := make(chan error, 1), make(chan error, 1)
+
+	go func() {
+		_, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+		created <- err
+	}()
+
+	go func() {
+		_, err := svc.RefreshOperator(ctx, &msisdn)
+		refreshed <- err
+	}()
+
+	<-blocking.started
+	<-blocking.started
+
+	// lookups in progress do not hold up changes of the subscription
+	if _, err := svc.Update(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	close(blocking.release)
+
+	if err := <-created; !errors.Is(err, subscription.ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got: %v", err)
+	}
+
+	if err := <-refreshed; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishOutsideLock(t *testing.T) {
+
+	svc := newTestService(t)
+	svc.events = eventbus.NewWithSize(1, 0)
+
+	received, release := make(chan subscription.EventType, 3), make(chan struct{})
+	svc.events.Subscribe("slow", func(ctx context.Context, e subscription.DomainEvent) {
+		received <- e.Type()
+		<-release
+	})
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	if _, err := svc.Create(context.Background(), &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	<-received
+
+	// the update is committed and waits for the slow subscriber, its caller is gone meanwhile
+	ctx, cancel := context.WithCancel(context.Background())
+	updated := make(chan error, 1)
+	go func() {
+		later := activateAt.Add(time.Hour)
+		_, err := svc.Update(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &later, Type: &subType})
+		updated <- err
+	}()
+
+	for {
+		if sub, err := svc.Get(context.Background(), &msisdn); err == nil && *sub.Version == 2 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	cancel()
+
+	// changes publishing nothing are not held up by the slow subscriber
+	if _, err := svc.RefreshOperator(context.Background(), &msisdn); err != nil {
+		t.Fatal(err)
+	}
+
+	close(release)
+
+	if err := <-updated; err != nil {
+		t.Fatal(err)
+	}
+
+	svc.events.Close()
+
+	if e := <-received; e != subscription.EventActivationDateChanged {
+		t.Fatalf("expected the update to be published, got: %s", e)
+	}
+}
+
+func TestRefreshOperator(t *testing.T) {
+
+	svc := newTestService(t)
+	events := &recorder{}
+	svc.events.Subscribe("test", events.handle)
+	ctx := context.Background()
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	created, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.OperatorCheckedAt == nil || *created.Operator != "Tele2 Sverige AB" {
+		t.Fatalf("expected operator to be checked when created, got: %v at %v", created.Operator, created.OperatorCheckedAt)
+	}
+
+	// an unchanged operator only moves the time it was checked
+	refreshed, err := svc.RefreshOperator(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *refreshed.Version != *created.Version || refreshed.OperatorCheckedAt.Before(*created.OperatorCheckedAt) {
+		t.Fatalf("expected same version checked later, got: version %d at %s", *refreshed.Version, refreshed.OperatorCheckedAt)
+	}
+
+	svc.source = &stubOperators{name: "Telia Sverige AB"}
+
+	// reads are served from storage until the operator is refreshed
+	got, err := svc.Get(ctx, &msisdn)
+	if err != nil || *got.Operator != "Tele2 Sverige AB" {
+		t.Fatalf("expected stored operator, got: %v, %v", got, err)
+	}
+
+	refreshed, err = svc.RefreshOperator(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *refreshed.Operator != "Telia Sverige AB" || *refreshed.Version != *created.Version+1 {
+		t.Fatalf("expected changed operator in a new version, got: %s version %d", *refreshed.Operator, *refreshed.Version)
+	}
+
+	if refreshed.OperatorChangedAt == nil || !refreshed.OperatorChangedAt.Equal(*refreshed.OperatorCheckedAt) {
+		t.Fatalf("expected operator changed when checked at %s, got: %v", refreshed.OperatorCheckedAt, refreshed.OperatorChangedAt)
+	}
+
+	page, err := svc.History(ctx, &msisdn, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Entries) != 2 || page.Entries[1].Action != subscription.ActionOperatorChanged || !page.Entries[1].Timestamp.Equal(*refreshed.OperatorChangedAt) {
+		t.Fatalf("expected created and operator_changed entries, got: %+v", page.Entries)
+	}
+
+	change := page.Entries[1].Changes[0]
+	if change.Field != "operator" || *change.From != "Tele2 Sverige AB" || *change.To != "Telia Sverige AB" {
+		t.Fatalf("expected operator change from Tele2 to Telia, got: %+v", change)
+	}
+
+	for since, expected := range map[time.Duration]int{-time.Minute: 1, time.Minute: 0} {
+		at := refreshed.OperatorChangedAt.Add(since)
+		result, err := svc.List(ctx, &subscription.Query{OperatorChangedSince: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Subscriptions) != expected {
+			t.Fatalf("expected %d subscriptions with operator changed since %s, got: %d", expected, at, len(result.Subscriptions))
+		}
+	}
+
+	svc.events.Close()
+
+	expectedEvents := []subscription.EventType{subscription.EventSubscriptionCreated, subscription.EventOperatorChanged}
+	if fmt.Sprint(events.events) != fmt.Sprint(expectedEvents) {
+		t.Fatalf("expected events: %v, got: %v", expectedEvents, events.events)
+	}
+}
+
+func TestRefreshForgetsCachedOperator(t *testing.T) {
+
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	source := &stubOperators{name: "Tele2 Sverige AB"}
+	cached, err := cache.NewRepository(source, time.Hour, 0, clock.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.source, svc.cache, svc.operators = source, cached, cached
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	if _, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ported, the cache still answers with the operator looked up when created
+	source.name = "Telia Sverige AB"
+
+	if name, err := svc.Operators().Get(ctx, &msisdn); err != nil || *name != "Tele2 Sverige AB" {
+		t.Fatalf("expected cached operator, got: %v, %v", name, err)
+	}
+
+	if _, err := svc.RefreshOperator(ctx, &msisdn); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := svc.Operators().Get(ctx, &msisdn); err != nil || *name != "Telia Sverige AB" {
+		t.Fatalf("expected ported operator once refreshed, got: %v, %v", name, err)
+	}
+}
+
+func TestRefreshUnknownOperator(t *testing.T) {
+
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	created, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.source = &stubOperators{err: operator.ErrNotFound}
+
+	if _, err := svc.RefreshOperator(ctx, &msisdn); !errors.Is(err, operator.ErrNotFound) {
+		t.Fatalf("expected operator.ErrNotFound, got: %v", err)
+	}
+
+	// the check is recorded so the refresher does not ask again until it is stale
+	got, err := svc.Get(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *got.Operator != *created.Operator || *got.Version != *created.Version || !got.OperatorCheckedAt.After(*created.OperatorCheckedAt) {
+		t.Fatalf("expected only the time checked to move, got: %s version %d at %s", *got.Operator, *got.Version, got.OperatorCheckedAt)
+	}
+}
+
+func TestRefreshUnverifiedOperator(t *testing.T) {
+
+	svc := newTestService(t)
+	svc.operators = &fallbackOperators{stubOperators{name: "Telia Sverige AB"}}
+	events := &recorder{}
+	svc.events.Subscribe("test", events.handle)
+	ctx := context.Background()
+
+	msisdn := "8-6785500"
+	activateAt := time.Now().UTC().Add(time.Hour)
+	subType := "CELL"
+
+	created, err := svc.Create(ctx, &subscription.Model{MSISDN: &msisdn, ActivateAt: &activateAt, Type: &subType})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *created.Operator != "Telia Sverige AB" || created.OperatorCheckedAt != nil {
+		t.Fatalf("expected unverified operator from the fallback, got: %s checked at %v", *created.Operator, created.OperatorCheckedAt)
+	}
+
+	// the source corrects the operator of the fallback, which is not a ported number
+	refreshed, err := svc.RefreshOperator(ctx, &msisdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *refreshed.Operator != "Tele2 Sverige AB" || refreshed.OperatorCheckedAt == nil || refreshed.OperatorChangedAt != nil {
+		t.Fatalf("expected verified operator without a change, got: %s checked at %v changed at %v", *refreshed.Operator, refreshed.OperatorCheckedAt, refreshed.OperatorChangedAt)
+	}
+
+	page, err := svc.History(ctx, &msisdn, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Entries) != 2 || page.Entries[1].Action != subscription.ActionUpdated {
+		t.Fatalf("expected created and updated entries, got: %+v", page.Entries)
+	}
+
+	svc.events.Close()
+
+	expectedEvents := []subscription.EventType{subscription.EventSubscriptionCreated}
+	if fmt.Sprint(events.events) != fmt.Sprint(expectedEvents) {
+		t.Fatalf("expected events: %v, got: %v", expectedEvents, events.events)
+	}
+}
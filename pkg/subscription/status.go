@@ -0,0 +1,205 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition returned if a subscription cannot move to the requested status
+var ErrInvalidTransition = errors.New("invalid status transition for subscription")
+
+// Status of a subscription
+type Status string
+
+const (
+	// StatusPending for subscription
+	StatusPending Status = "pending"
+	// StatusActivated for subscription
+	StatusActivated Status = "activated"
+	// StatusPaused for subscription
+	StatusPaused Status = "paused"
+	// StatusCancelled for subscription
+	StatusCancelled Status = "cancelled"
+)
+
+// FinalStatuses can not be left once reached
+var FinalStatuses = []Status{StatusCancelled}
+
+// Final status can not be left once reached
+func (s Status) Final() bool {
+	for _, final := range FinalStatuses {
+		if s == final {
+			return true
+		}
+	}
+	return false
+}
+
+// Event that moves a subscription between statuses
+type Event string
+
+const (
+	// EventActivate a pending subscription once activate_at is reached
+	EventActivate Event = "activate"
+	// EventReschedule a pending subscription to a new activate_at
+	EventReschedule Event = "reschedule"
+	// EventPause an activated subscription
+	EventPause Event = "pause"
+	// EventResume a paused subscription
+	EventResume Event = "resume"
+	// EventCancel a subscription that is not already cancelled
+	EventCancel Event = "cancel"
+)
+
+// Guard decides if a transition is allowed for the subscription at the given time
+type Guard func(m *Model, now time.Time) error
+
+// Transition declared for the subscription state machine
+type Transition struct {
+	Event Event
+	From  Status
+	To    Status
+	Guard Guard
+}
+
+// Transitions is the complete table of allowed status changes,
+// anything not listed here is rejected with ErrInvalidTransition
+var Transitions = []Transition{
+	{Event: EventActivate, From: StatusPending, To: StatusActivated, Guard: activationDue},
+	{Event: EventReschedule, From: StatusPending, To: StatusPending},
+	{Event: EventPause, From: StatusActivated, To: StatusPaused},
+	{Event: EventResume, From: StatusPaused, To: StatusActivated},
+	{Event: EventCancel, From: StatusPending, To: StatusCancelled},
+	{Event: EventCancel, From: StatusActivated, To: StatusCancelled},
+	{Event: EventCancel, From: StatusPaused, To: StatusCancelled},
+}
+
+func activationDue(m *Model, now time.Time) error {
+
+	if m.ActivateAt == nil {
+		return errors.New("no activate_at provided")
+	}
+
+	if m.ActivateAt.After(now) {
+		return fmt.Errorf("activate_at %s not reached yet", m.ActivateAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func findTransition(from Status, event Event) (*Transition, bool) {
+	for i := range Transitions {
+		if Transitions[i].From == from && Transitions[i].Event == event {
+			return &Transitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Can reports if event is allowed for the subscription at the given time
+func (m *Model) Can(event Event, now time.Time) error {
+
+	if m == nil {
+		return errors.New("cannot transition a nil subscription")
+	}
+
+	if m.Status == nil {
+		return fmt.Errorf("cannot %s subscription without status: %w", event, ErrInvalidTransition)
+	}
+
+	t, ok := findTransition(*m.Status, event)
+	if !ok {
+		return fmt.Errorf("cannot %s subscription with status %s: %w", event, *m.Status, ErrInvalidTransition)
+	}
+
+	if t.Guard != nil {
+		if err := t.Guard(m, now); err != nil {
+			return fmt.Errorf("cannot %s subscription: %s: %w", event, err, ErrInvalidTransition)
+		}
+	}
+
+	return nil
+}
+
+// Fire event on the subscription, moving it to the status declared in Transitions
+func (m *Model) Fire(event Event, now time.Time) error {
+
+	if err := m.Can(event, now); err != nil {
+		return err
+	}
+
+	t, _ := findTransition(*m.Status, event)
+	to := t.To
+	m.Status = &to
+
+	return nil
+}
+
+// InitStatus of a new subscription, pending until activate_at is reached
+func (m *Model) InitStatus(now time.Time) error {
+
+	if m == nil {
+		return errors.New("cannot init status of nil subscription")
+	}
+
+	if m.ActivateAt == nil {
+		return errors.New("no activate_at provided")
+	}
+
+	status := StatusPending
+	m.Status = &status
+
+	if m.Can(EventActivate, now) == nil {
+		return m.Fire(EventActivate, now)
+	}
+
+	return nil
+}
+
+// Amend subscription with the updatable fields of u, rescheduling it if activate_at changed
+func (m *Model) Amend(u *Model, now time.Time) error {
+
+	if m == nil || u == nil {
+		return errors.New("cannot amend nil subscription")
+	}
+
+	if err := m.CheckVersion(u.Version); err != nil {
+		return err
+	}
+
+	if m.Status == nil {
+		return fmt.Errorf("cannot update subscription without status: %w", ErrInvalidTransition)
+	}
+
+	if m.Status.Final() {
+		return fmt.Errorf("cannot update subscription with status %s: %w", *m.Status, ErrInvalidTransition)
+	}
+
+	if u.ActivateAt != nil && (m.ActivateAt == nil || !m.ActivateAt.Equal(*u.ActivateAt)) {
+		if err := m.Can(EventReschedule, now); err != nil {
+			return err
+		}
+		m.ActivateAt = u.ActivateAt
+	}
+
+	if u.Type != nil {
+		m.Type = u.Type
+	}
+
+	if *m.Status == StatusPending && m.Can(EventActivate, now) == nil {
+		return m.Fire(EventActivate, now)
+	}
+
+	return nil
+}
+
+// TogglePaused fires either EventPause or EventResume depending on current status
+func (m *Model) TogglePaused(now time.Time) error {
+
+	if m != nil && m.Status != nil && *m.Status == StatusPaused {
+		return m.Fire(EventResume, now)
+	}
+
+	return m.Fire(EventPause, now)
+}
@@ -0,0 +1,78 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFire(t *testing.T) {
+
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		status     Status
+		activateAt time.Time
+		event      Event
+		expected   Status
+		invalid    bool
+	}{
+		{"activate due", StatusPending, past, EventActivate, StatusActivated, false},
+		{"activate not due", StatusPending, future, EventActivate, StatusPending, true},
+		{"pause activated", StatusActivated, past, EventPause, StatusPaused, false},
+		{"pause pending", StatusPending, future, EventPause, StatusPending, true},
+		{"resume paused", StatusPaused, past, EventResume, StatusActivated, false},
+		{"resume activated", StatusActivated, past, EventResume, StatusActivated, true},
+		{"cancel paused", StatusPaused, past, EventCancel, StatusCancelled, false},
+		{"pause cancelled", StatusCancelled, past, EventPause, StatusCancelled, true},
+		{"resume cancelled", StatusCancelled, past, EventResume, StatusCancelled, true},
+		{"cancel cancelled", StatusCancelled, past, EventCancel, StatusCancelled, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			status := tt.status
+			activateAt := tt.activateAt
+			m := &Model{ActivateAt: &activateAt, Status: &status}
+
+			err := m.Fire(tt.event, now)
+			if tt.invalid != errors.Is(err, ErrInvalidTransition) {
+				t.Fatalf("expected invalid transition: %t, got error: %v", tt.invalid, err)
+			}
+
+			if *m.Status != tt.expected {
+				t.Fatalf("expected status to be: %s, got: %s", tt.expected, *m.Status)
+			}
+		})
+	}
+}
+
+func TestAmend(t *testing.T) {
+
+	now := time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	status := StatusActivated
+	m := &Model{ActivateAt: &future, Status: &status}
+
+	if err := m.Amend(&Model{ActivateAt: &later}, now); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected rescheduling activated subscription to fail, got: %v", err)
+	}
+
+	if err := m.InitStatus(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Amend(&Model{ActivateAt: &later}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if *m.Status != StatusPending || !m.ActivateAt.Equal(later) {
+		t.Fatalf("expected pending subscription activating at: %s, got: %s at %s", later, *m.Status, m.ActivateAt)
+	}
+}
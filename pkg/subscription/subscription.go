@@ -3,7 +3,10 @@ package subscription
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/rgynn/subscription-api/pkg/validate"
 )
 
 // ErrAlreadyExists returned if a subscription already exists for the provided msisdn
@@ -15,25 +18,53 @@ var ErrNotFound = errors.New("subscription not found for the provided msisdn")
 // ErrNotValid returned if provided subscription not valid
 var ErrNotValid = errors.New("provided subscription not valid")
 
-var (
-	// StatusPending for subscription
-	StatusPending = "pending"
-	// StatusActivated for subscription
-	StatusActivated = "activated"
-	// StatusPaused for subscription
-	StatusPaused = "paused"
-	// StatusCancelled for subscription
-	StatusCancelled = "cancelled"
-)
+// ErrVersionConflict returned if a subscription was changed since the version the caller expected
+var ErrVersionConflict = errors.New("subscription was changed since the expected version")
 
 // Repository interface for subscription
 type Repository interface {
-	List(ctx context.Context) ([]*Model, error)
+	List(ctx context.Context, q *Query) (*Page, error)
 	Get(ctx context.Context, msisdn *string) (*Model, error)
 	Create(ctx context.Context, m *Model) (*Model, error)
 	Update(ctx context.Context, m *Model) (*Model, error)
-	TogglePaused(ctx context.Context, msisdn *string) (*Model, error)
-	Cancel(ctx context.Context, msisdn *string) (*Model, error)
+	// Activate returns ErrInvalidTransition if the subscription is not pending or its activate_at is
+	// not reached by now, the time of the caller as the clocks of instances may differ
+	Activate(ctx context.Context, msisdn *string, now time.Time) (*Model, error)
+	// TogglePaused and Cancel return ErrVersionConflict if expected is provided and not the version stored
+	TogglePaused(ctx context.Context, msisdn *string, expected *int64) (*Model, error)
+	Cancel(ctx context.Context, msisdn *string, expected *int64) (*Model, error)
+}
+
+// Count of subscriptions with a status and type
+type Count struct {
+	Status Status
+	Type   string
+	N      int
+}
+
+// Counter of subscriptions by status and type, implemented by repositories that can count
+// without listing every subscription
+type Counter interface {
+	Count(ctx context.Context) ([]Count, error)
+}
+
+// OperatorRecorder stores the operator of a subscription once it has been verified, implemented by
+// repositories of subscriptions
+type OperatorRecorder interface {
+	SetOperator(ctx context.Context, msisdn *string, operator *string, checkedAt time.Time) (*Model, error)
+}
+
+// OperatorRefresher verifies the operator stored with a subscription against its source
+type OperatorRefresher interface {
+	RefreshOperator(ctx context.Context, msisdn *string) (*Model, error)
+}
+
+// Pauser pauses and resumes subscriptions explicitly, so a retried pause does not resume a
+// subscription like toggling it would
+type Pauser interface {
+	// Pause and Resume return ErrInvalidTransition if the subscription is not activated or paused
+	Pause(ctx context.Context, msisdn *string, expected *int64) (*Model, error)
+	Resume(ctx context.Context, msisdn *string, expected *int64) (*Model, error)
 }
 
 // Model of a subscription
@@ -41,113 +72,134 @@ type Model struct {
 	MSISDN     *string    `json:"msisdn"`
 	ActivateAt *time.Time `json:"activate_at"`
 	Type       *string    `json:"type"`
-	Status     *string    `json:"status"`
+	Status     *Status    `json:"status"`
 	Operator   *string    `json:"operator,omitempty"`
+	// OperatorCheckedAt is when the operator was last verified
+	OperatorCheckedAt *time.Time `json:"operator_checked_at,omitempty"`
+	// OperatorChangedAt is when the operator was last found to have changed, e.g. the number was ported
+	OperatorChangedAt *time.Time `json:"operator_changed_at,omitempty"`
+	// Version is incremented on every change, when updating it is the version the change is based on
+	Version *int64 `json:"version,omitempty"`
 }
 
-// ValidForSave?
-func (m *Model) ValidForSave() error {
+// CheckVersion returns ErrVersionConflict if expected is provided and not the version of m
+func (m *Model) CheckVersion(expected *int64) error {
 
-	if m == nil {
-		return errors.New("cannot validate a nil subscription")
+	if expected == nil {
+		return nil
 	}
 
-	if m.MSISDN == nil {
-		return errors.New("no msisdn provided")
+	if m.Version == nil || *m.Version != *expected {
+		return fmt.Errorf("expected version %d: %w", *expected, ErrVersionConflict)
 	}
 
-	if m.ActivateAt == nil {
-		return errors.New("no activate_at provided")
-	}
+	return nil
+}
 
-	if m.Type == nil {
-		return errors.New("no type provided")
-	} else {
-		switch *m.Type {
-		case "PBX", "CELL":
-			break
-		default:
-			return errors.New("type needs to be either PBX or CELL")
-		}
-	}
+// NextVersion increments the version of m after a change, starting at 1
+func (m *Model) NextVersion() {
 
-	if m.Status != nil {
-		return errors.New("cannot provided status when creating new subscription")
+	var v int64 = 1
+	if m.Version != nil {
+		v = *m.Version + 1
 	}
 
-	if m.Operator != nil {
-		return errors.New("field operator is read only")
+	m.Version = &v
+}
+
+// SetOperator verified at checkedAt, reporting if it differs from the one stored before. Replacing
+// a verified operator is recorded as a change of operator at checkedAt, replacing one that was
+// never verified is a correction.
+func (m *Model) SetOperator(operator *string, checkedAt time.Time) bool {
+
+	changed := (m.Operator == nil) != (operator == nil) || m.Operator != nil && *m.Operator != *operator
+
+	if changed && m.Verified() {
+		m.OperatorChangedAt = &checkedAt
 	}
 
-	return nil
+	m.Operator = operator
+	m.OperatorCheckedAt = &checkedAt
+
+	return changed
 }
 
-// ValidForSave?
-func (m *Model) ValidForUpdate() error {
+// Verified reports if the operator stored was verified against its source, an operator from a
+// fallback is not until it is refreshed
+func (m *Model) Verified() bool {
+	return m.Operator != nil && m.OperatorCheckedAt != nil
+}
+
+// ValidForSave returns a validate.Error listing every field not valid for creating a subscription
+func (m *Model) ValidForSave() error {
 
 	if m == nil {
-		return errors.New("cannot validate a nil subscription")
+		return fmt.Errorf("no subscription provided: %w", ErrNotValid)
 	}
 
-	if m.MSISDN == nil {
-		return errors.New("no msisdn provided")
-	}
+	v := m.validate()
 
-	if m.ActivateAt == nil {
-		return errors.New("no activate_at provided")
+	if m.Status != nil {
+		v.Add("status", "is read only, cannot be provided when creating a subscription")
 	}
 
-	if m.Type == nil {
-		return errors.New("no type provided")
-	} else {
-		switch *m.Type {
-		case "PBX", "CELL":
-			break
-		default:
-			return errors.New("type needs to be either PBX or CELL")
-		}
+	if m.Version != nil {
+		v.Add("version", "is read only, cannot be provided when creating a subscription")
 	}
 
-	if m.Status != nil {
-		return errors.New("cannot provided status when updating subscription")
+	return v.Err()
+}
+
+// ValidForUpdate returns a validate.Error listing every field not valid for updating a subscription
+func (m *Model) ValidForUpdate() error {
+
+	if m == nil {
+		return fmt.Errorf("no subscription provided: %w", ErrNotValid)
 	}
 
-	if m.Operator != nil {
-		return errors.New("field operator is read only")
+	v := m.validate()
+
+	if m.Status != nil {
+		v.Add("status", "is read only, change it with toggle_paused or cancel")
 	}
 
-	return nil
+	return v.Err()
 }
 
-func (m *Model) IsActive() bool {
-	return m != nil && m.Status != nil && *m.Status == "activated"
-}
+// validate fields common to creating and updating
+func (m *Model) validate() *validate.Error {
 
-func (m *Model) UpdateStatus(status *string) error {
+	v := validate.New(ErrNotValid)
 
-	if m == nil {
-		return errors.New("cannot update status of nil subscription")
+	if m.MSISDN == nil || *m.MSISDN == "" {
+		v.Add("msisdn", "is required")
 	}
 
 	if m.ActivateAt == nil {
-		return errors.New("no activate_at provided")
+		v.Add("activate_at", "is required")
 	}
 
-	if status != nil {
-		m.Status = status
-		return nil
+	if m.Type == nil {
+		v.Add("type", "is required")
+	} else if *m.Type != "PBX" && *m.Type != "CELL" {
+		v.Add("type", "needs to be either PBX or CELL")
 	}
 
-	now := time.Now().UTC()
+	if m.Operator != nil {
+		v.Add("operator", "is read only")
+	}
 
-	if m.ActivateAt.After(now) {
-		m.Status = &StatusPending
-		return nil
+	if m.OperatorCheckedAt != nil {
+		v.Add("operator_checked_at", "is read only")
 	}
 
-	if m.ActivateAt.Before(now) {
-		m.Status = &StatusActivated
+	if m.OperatorChangedAt != nil {
+		v.Add("operator_changed_at", "is read only")
 	}
 
-	return nil
+	return v
+}
+
+func (m *Model) IsActive() bool {
+	return m != nil && m.Status != nil && *m.Status == StatusActivated
 }
@@ -0,0 +1,52 @@
+package validate
+
+import (
+	"strings"
+)
+
+// Violation of a validation rule by a field
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error listing every violation found when validating, it wraps the sentinel
+// error of the package it was created by so errors.Is keeps working
+type Error struct {
+	Violations []Violation
+	kind       error
+}
+
+// New validation error wrapping kind
+func New(kind error) *Error {
+	return &Error{kind: kind}
+}
+
+// Add violation of field
+func (e *Error) Add(field, message string) {
+	e.Violations = append(e.Violations, Violation{Field: field, Message: message})
+}
+
+// Err returns e if any violation was added, nil otherwise
+func (e *Error) Err() error {
+
+	if len(e.Violations) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Field+": "+v.Message)
+	}
+
+	return e.kind.Error() + ": " + strings.Join(messages, ", ")
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,443 @@
+package webhook
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/resilience"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+)
+
+// Policy for delivering events to webhooks
+type Policy struct {
+	// MaxAttempts before a delivery is dead
+	MaxAttempts int
+	Backoff     resilience.Backoff
+	Timeout     time.Duration
+	// Concurrency of attempts in flight
+	Concurrency int
+	// Poll interval deliveries enqueued by other instances are picked up at, 0 disables polling
+	Poll time.Duration
+}
+
+// DefaultPolicy for delivering events to webhooks
+var DefaultPolicy = Policy{
+	MaxAttempts: 8,
+	Backoff:     resilience.Backoff{Base: time.Second, Max: time.Hour},
+	Timeout:     5 * time.Second,
+	Concurrency: 4,
+	Poll:        10 * time.Second,
+}
+
+type due struct {
+	id string
+	at time.Time
+}
+
+type dueQueue []due
+
+func (q dueQueue) Len() int            { return len(q) }
+func (q dueQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q dueQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *dueQueue) Push(x interface{}) { *q = append(*q, x.(due)) }
+func (q *dueQueue) Pop() interface{} {
+	old := *q
+	d := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return d
+}
+
+// Dispatcher delivers events to registered webhooks at least once, draining the deliveries
+// enqueued with the changes they describe from the repository. It wraps a Repository so
+// webhooks created through it get an id and secret.
+type Dispatcher struct {
+	Repository
+	policy Policy
+	client *http.Client
+	clock  clock.Clock
+	queue  dueQueue
+	// tracked deliveries queued or in flight, so draining does not queue them again
+	tracked map[string]struct{}
+	// stale is set once deliveries may have been enqueued since the repository was drained
+	stale  bool
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+	sync.Mutex
+}
+
+// NewDispatcher delivering events to the webhooks of repo according to policy
+func NewDispatcher(repo Repository, policy Policy, clk clock.Clock) *Dispatcher {
+
+	if policy.Concurrency < 1 {
+		policy.Concurrency = 1
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	return &Dispatcher{
+		Repository: repo,
+		policy:     policy,
+		client:     &http.Client{Timeout: policy.Timeout},
+		clock:      clk,
+		tracked:    map[string]struct{}{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+func (d *Dispatcher) Create(ctx context.Context, m *Model) (*Model, error) {
+
+	if err := m.ValidForSave(); err != nil {
+		return nil, err
+	}
+
+	id := NewID()
+	now := d.clock.Now()
+
+	m.ID = &id
+	m.CreatedAt = &now
+
+	if m.Secret == nil {
+		secret := NewID()
+		m.Secret = &secret
+	}
+
+	return d.Repository.Create(ctx, m)
+}
+
+// Start dispatcher after draining the repository of unfinished deliveries, it runs until ctx
+// is done or it is stopped
+func (d *Dispatcher) Start(ctx context.Context) error {
+
+	if err := d.drain(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	d.Lock()
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	d.Unlock()
+
+	go d.run(ctx)
+
+	return nil
+}
+
+// drain the repository of unfinished deliveries not tracked yet
+func (d *Dispatcher) drain(ctx context.Context) error {
+
+	unfinished, err := d.Repository.Unfinished(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan unfinished webhook deliveries: %w", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	for _, delivery := range unfinished {
+
+		if _, ok := d.tracked[delivery.ID]; ok {
+			continue
+		}
+
+		at := d.clock.Now()
+		if delivery.NextAttemptAt != nil {
+			at = *delivery.NextAttemptAt
+		}
+
+		d.tracked[delivery.ID] = struct{}{}
+		heap.Push(&d.queue, due{id: delivery.ID, at: at})
+	}
+
+	return nil
+}
+
+// Stop dispatcher, waiting for attempts in flight to finish
+func (d *Dispatcher) Stop() {
+
+	d.Lock()
+	cancel, done := d.cancel, d.done
+	d.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// Enqueue a delivery of events to every webhook wanting them, meant to be called in the
+// transaction of the change they describe so they are delivered once it is committed
+func (d *Dispatcher) Enqueue(ctx context.Context, events ...subscription.DomainEvent) error {
+
+	webhooks, err := d.Repository.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks for events: %w", err)
+	}
+
+	for _, e := range events {
+
+		envelope := Envelope{
+			ID:         NewID(),
+			Type:       e.Type(),
+			OccurredAt: e.Metadata().OccurredAt,
+			Data:       e,
+		}
+
+		payload, err := json.Marshal(envelope)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event for webhooks: %w", err)
+		}
+
+		for _, m := range webhooks {
+
+			if !m.Wants(e.Type()) {
+				continue
+			}
+
+			delivery := &Delivery{
+				ID:        NewID(),
+				WebhookID: *m.ID,
+				EventID:   envelope.ID,
+				EventType: e.Type(),
+				Payload:   payload,
+				Status:    DeliveryPending,
+				Attempts:  []Attempt{},
+				CreatedAt: d.clock.Now(),
+			}
+
+			if err := d.Repository.CreateDelivery(ctx, delivery); err != nil {
+				return fmt.Errorf("failed to create webhook delivery for %s: %w", *m.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Handle event published on the event bus once its change is committed, by draining the
+// deliveries enqueued with it
+func (d *Dispatcher) Handle(ctx context.Context, e subscription.DomainEvent) {
+	d.Lock()
+	d.stale = true
+	d.Unlock()
+	d.notify()
+}
+
+// schedule another attempt of tracked delivery id at
+func (d *Dispatcher) schedule(id string, at time.Time) {
+
+	d.Lock()
+	heap.Push(&d.queue, due{id: id, at: at})
+	d.Unlock()
+
+	d.notify()
+}
+
+// untrack delivery id once it is finished, or gone
+func (d *Dispatcher) untrack(id string) {
+	d.Lock()
+	delete(d.tracked, id)
+	d.Unlock()
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) next() (time.Time, bool) {
+
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.queue) == 0 {
+		return time.Time{}, false
+	}
+
+	return d.queue[0].at, true
+}
+
+func (d *Dispatcher) popDue(now time.Time) []string {
+
+	d.Lock()
+	defer d.Unlock()
+
+	var ids []string
+	for len(d.queue) > 0 && !d.queue[0].at.After(now) {
+		ids = append(ids, heap.Pop(&d.queue).(due).id)
+	}
+
+	return ids
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+
+	var inflight sync.WaitGroup
+	sem := make(chan struct{}, d.policy.Concurrency)
+
+	defer close(d.done)
+	defer inflight.Wait()
+
+	poll := d.poll()
+
+	for {
+		var timer <-chan time.Time
+		if at, ok := d.next(); ok {
+			timer = d.clock.After(at.Sub(d.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer:
+		case <-poll:
+			poll = d.poll()
+			d.Lock()
+			d.stale = true
+			d.Unlock()
+		}
+
+		d.Lock()
+		stale := d.stale
+		d.stale = false
+		d.Unlock()
+
+		if stale {
+			if err := d.drain(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to drain webhook deliveries", "error", err)
+			}
+		}
+
+		for _, id := range d.popDue(d.clock.Now()) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			inflight.Add(1)
+			go func(id string) {
+				defer func() { <-sem; inflight.Done() }()
+				// let attempts already sent finish when stopping, the client timeout bounds them
+				d.attempt(context.WithoutCancel(ctx), id)
+			}(id)
+		}
+	}
+}
+
+// poll returns when the repository is to be drained again, never if polling is disabled
+func (d *Dispatcher) poll() <-chan time.Time {
+	if d.policy.Poll <= 0 {
+		return nil
+	}
+	return d.clock.After(d.policy.Poll)
+}
+
+// attempt delivery with id, scheduling a retry or marking it dead if it fails
+func (d *Dispatcher) attempt(ctx context.Context, id string) {
+
+	retrying := false
+	defer func() {
+		if !retrying {
+			d.untrack(id)
+		}
+	}()
+
+	delivery, err := d.Repository.GetDelivery(ctx, &id)
+	if err != nil {
+		// webhook deleted along with its deliveries
+		return
+	}
+
+	if delivery.Status != DeliveryPending && delivery.Status != DeliveryRetrying {
+		return
+	}
+
+	m, err := d.Repository.Get(ctx, &delivery.WebhookID)
+	if err != nil {
+		return
+	}
+
+	attempt := d.send(ctx, m, delivery)
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = DeliverySucceeded
+	case len(delivery.Attempts) >= d.policy.MaxAttempts:
+		delivery.Status = DeliveryDead
+	default:
+		next := d.clock.Now().Add(d.policy.Backoff.Delay(len(delivery.Attempts) - 1))
+		delivery.Status = DeliveryRetrying
+		delivery.NextAttemptAt = &next
+	}
+
+	// the attempt was made, record it even if we are shutting down
+	if err := d.Repository.UpdateDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		slog.ErrorContext(ctx, "failed to update webhook delivery", "delivery", delivery.ID, "error", err)
+		return
+	}
+
+	if delivery.NextAttemptAt != nil {
+		retrying = true
+		d.schedule(delivery.ID, *delivery.NextAttemptAt)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, m *Model, delivery *Delivery) Attempt {
+
+	start := d.clock.Now()
+	attempt := Attempt{At: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *m.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	timestamp := start.Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, *m.ID)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(*m.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	attempt.Duration = d.clock.Now().Sub(start)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("expected 2xx response, got: %d", resp.StatusCode)
+	}
+
+	return attempt
+}
@@ -0,0 +1,184 @@
+package webhook_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/clock"
+	"github.com/rgynn/subscription-api/pkg/resilience"
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+	"github.com/rgynn/subscription-api/pkg/webhook/repo/mem"
+)
+
+// receiver fails the first failures requests and verifies signatures using secret
+func receiver(t *testing.T, secret string, failures int64, hits *int64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+
+		if !webhook.Verify(secret, timestamp, body, r.Header.Get(webhook.HeaderSignature)) {
+			t.Errorf("invalid signature for delivery %s", r.Header.Get(webhook.HeaderDelivery))
+		}
+
+		if atomic.AddInt64(hits, 1) <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func deliver(t *testing.T, failures int64, maxAttempts int) (*webhook.DeliveryPage, int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+	secret := "a-secret-of-sixteen-chars"
+
+	var hits int64
+	srv := receiver(t, secret, failures, &hits)
+
+	repo, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := webhook.NewDispatcher(repo, webhook.Policy{
+		MaxAttempts: maxAttempts,
+		Backoff:     resilience.Backoff{Base: time.Second, Max: time.Second},
+		Timeout:     time.Second,
+	}, clk)
+
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	m, err := d.Create(ctx, &webhook.Model{
+		URL:        &srv.URL,
+		Secret:     &secret,
+		EventTypes: []subscription.EventType{subscription.EventPaused},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := subscription.EventMeta{MSISDN: "8-6785500", OccurredAt: clk.Now()}
+	resumed, paused := &subscription.Resumed{EventMeta: meta}, &subscription.Paused{EventMeta: meta}
+
+	if err := d.Enqueue(ctx, resumed, paused); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Handle(ctx, resumed)
+	d.Handle(ctx, paused)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := d.Deliveries(ctx, m.ID, nil, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page.Deliveries) != 1 {
+			t.Fatalf("expected 1 delivery for the paused event, got: %d", len(page.Deliveries))
+		}
+
+		if status := page.Deliveries[0].Status; status == webhook.DeliverySucceeded || status == webhook.DeliveryDead {
+			return page, atomic.LoadInt64(&hits)
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery not finished, status: %s", page.Deliveries[0].Status)
+		}
+
+		clk.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeliveryRetried(t *testing.T) {
+
+	page, hits := deliver(t, 2, 5)
+	delivery := page.Deliveries[0]
+
+	if delivery.Status != webhook.DeliverySucceeded || len(delivery.Attempts) != 3 || hits != 3 {
+		t.Fatalf("expected delivery to succeed on 3rd attempt, got: %s after %d attempts", delivery.Status, len(delivery.Attempts))
+	}
+
+	if delivery.Attempts[0].StatusCode != http.StatusInternalServerError || delivery.EventType != subscription.EventPaused {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveryDead(t *testing.T) {
+
+	page, hits := deliver(t, 10, 2)
+	delivery := page.Deliveries[0]
+
+	if delivery.Status != webhook.DeliveryDead || len(delivery.Attempts) != 2 || hits != 2 {
+		t.Fatalf("expected delivery to be dead after 2 attempts, got: %s after %d attempts", delivery.Status, len(delivery.Attempts))
+	}
+}
+
+func TestDeliveryPolled(t *testing.T) {
+
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2021, 5, 21, 0, 0, 0, 0, time.UTC))
+	secret := "a-secret-of-sixteen-chars"
+
+	var hits int64
+	srv := receiver(t, secret, 0, &hits)
+
+	repo, err := mem.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &webhook.Model{ID: new(string), URL: &srv.URL, Secret: &secret, EventTypes: []subscription.EventType{subscription.EventPaused}}
+	*m.ID = webhook.NewID()
+	if _, err := repo.Create(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	d := webhook.NewDispatcher(repo, webhook.Policy{
+		MaxAttempts: 1,
+		Timeout:     time.Second,
+		Poll:        time.Minute,
+	}, clk)
+
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	// enqueued by another instance, so never handled by this one
+	other := webhook.NewDispatcher(repo, webhook.DefaultPolicy, clk)
+	if err := other.Enqueue(ctx, &subscription.Paused{EventMeta: subscription.EventMeta{MSISDN: "8-6785500", OccurredAt: clk.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&hits) == 0 {
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected delivery enqueued elsewhere to be polled")
+		}
+
+		clk.Advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+}
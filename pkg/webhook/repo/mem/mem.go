@@ -0,0 +1,225 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/rgynn/subscription-api/pkg/webhook"
+)
+
+// Repository for in memory webhooks and deliveries
+type Repository struct {
+	webhooks   map[string]*webhook.Model
+	deliveries map[string]*webhook.Delivery
+	byWebhook  map[string][]string
+	sync.Mutex
+}
+
+func NewRepository() (webhook.Repository, error) {
+	return &Repository{
+		webhooks:   map[string]*webhook.Model{},
+		deliveries: map[string]*webhook.Delivery{},
+		byWebhook:  map[string][]string{},
+	}, nil
+}
+
+func (repo *Repository) List(ctx context.Context) ([]*webhook.Model, error) {
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	result := make([]*webhook.Model, 0, len(repo.webhooks))
+
+	for _, m := range repo.webhooks {
+		result = append(result, copyModel(m))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(*result[j].CreatedAt) })
+
+	return result, nil
+}
+
+func (repo *Repository) Get(ctx context.Context, id *string) (*webhook.Model, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	m, ok := repo.webhooks[*id]
+	if !ok {
+		return nil, webhook.ErrNotFound
+	}
+
+	return copyModel(m), nil
+}
+
+func (repo *Repository) Create(ctx context.Context, m *webhook.Model) (*webhook.Model, error) {
+
+	if m == nil || m.ID == nil {
+		return nil, errors.New("no webhook with id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	repo.webhooks[*m.ID] = copyModel(m)
+
+	return m, nil
+}
+
+func (repo *Repository) Delete(ctx context.Context, id *string) error {
+
+	if id == nil {
+		return errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if _, ok := repo.webhooks[*id]; !ok {
+		return webhook.ErrNotFound
+	}
+
+	for _, deliveryID := range repo.byWebhook[*id] {
+		delete(repo.deliveries, deliveryID)
+	}
+
+	delete(repo.byWebhook, *id)
+	delete(repo.webhooks, *id)
+
+	return nil
+}
+
+func (repo *Repository) CreateDelivery(ctx context.Context, d *webhook.Delivery) error {
+
+	if d == nil {
+		return errors.New("no delivery provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if _, ok := repo.webhooks[d.WebhookID]; !ok {
+		return webhook.ErrNotFound
+	}
+
+	repo.deliveries[d.ID] = copyDelivery(d)
+	repo.byWebhook[d.WebhookID] = append(repo.byWebhook[d.WebhookID], d.ID)
+
+	return nil
+}
+
+func (repo *Repository) UpdateDelivery(ctx context.Context, d *webhook.Delivery) error {
+
+	if d == nil {
+		return errors.New("no delivery provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if _, ok := repo.deliveries[d.ID]; !ok {
+		return webhook.ErrNotFound
+	}
+
+	repo.deliveries[d.ID] = copyDelivery(d)
+
+	return nil
+}
+
+func (repo *Repository) GetDelivery(ctx context.Context, id *string) (*webhook.Delivery, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	d, ok := repo.deliveries[*id]
+	if !ok {
+		return nil, webhook.ErrNotFound
+	}
+
+	return copyDelivery(d), nil
+}
+
+func (repo *Repository) Deliveries(ctx context.Context, webhookID *string, cursor *string, limit int) (*webhook.DeliveryPage, error) {
+
+	if webhookID == nil {
+		return nil, errors.New("no webhook id provided")
+	}
+
+	if limit <= 0 {
+		return nil, errors.New("limit needs to be positive")
+	}
+
+	var offset int
+	if cursor != nil {
+		n, err := strconv.Atoi(*cursor)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid cursor %q: %w", *cursor, webhook.ErrNotValid)
+		}
+		offset = n
+	}
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	if _, ok := repo.webhooks[*webhookID]; !ok {
+		return nil, webhook.ErrNotFound
+	}
+
+	ids := repo.byWebhook[*webhookID]
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+
+	page := &webhook.DeliveryPage{Deliveries: []*webhook.Delivery{}}
+
+	for i := offset; i < len(ids); i++ {
+		if len(page.Deliveries) == limit {
+			next := strconv.Itoa(i)
+			page.NextCursor = &next
+			break
+		}
+		page.Deliveries = append(page.Deliveries, copyDelivery(repo.deliveries[ids[i]]))
+	}
+
+	return page, nil
+}
+
+func (repo *Repository) Unfinished(ctx context.Context) ([]*webhook.Delivery, error) {
+
+	repo.Lock()
+	defer repo.Unlock()
+
+	var result []*webhook.Delivery
+
+	for _, d := range repo.deliveries {
+		if d.Status == webhook.DeliveryPending || d.Status == webhook.DeliveryRetrying {
+			result = append(result, copyDelivery(d))
+		}
+	}
+
+	return result, nil
+}
+
+func copyModel(m *webhook.Model) *webhook.Model {
+	result := *m
+	result.EventTypes = append(result.EventTypes[:0:0], m.EventTypes...)
+	return &result
+}
+
+func copyDelivery(d *webhook.Delivery) *webhook.Delivery {
+	result := *d
+	result.Attempts = append(result.Attempts[:0:0], d.Attempts...)
+	return &result
+}
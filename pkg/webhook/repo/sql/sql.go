@@ -0,0 +1,367 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	subsql "github.com/rgynn/subscription-api/pkg/subscription/repo/sql"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+)
+
+const (
+	webhookColumns  = `id, url, secret, event_types, created_at`
+	deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at`
+)
+
+// Repository for webhooks and deliveries stored in the sql database of the subscriptions, so
+// unfinished deliveries survive a restart
+type Repository struct {
+	db *subsql.DB
+}
+
+func NewRepository(db *subsql.DB) (webhook.Repository, error) {
+
+	if db == nil {
+		return nil, errors.New("no db provided")
+	}
+
+	return &Repository{db: db}, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row scanner) (*webhook.Model, error) {
+
+	var (
+		m          webhook.Model
+		id, url    string
+		secret     dbsql.NullString
+		eventTypes string
+		createdAt  time.Time
+	)
+
+	if err := row.Scan(&id, &url, &secret, &eventTypes, &createdAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(eventTypes), &m.EventTypes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook event types: %w", err)
+	}
+
+	createdAt = createdAt.UTC()
+	m.ID, m.URL, m.CreatedAt = &id, &url, &createdAt
+
+	if secret.Valid {
+		m.Secret = &secret.String
+	}
+
+	return &m, nil
+}
+
+func scanDelivery(row scanner) (*webhook.Delivery, error) {
+
+	var (
+		d                 webhook.Delivery
+		eventType, status string
+		payload, attempts string
+		nextAttemptAt     dbsql.NullTime
+	)
+
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &eventType, &payload, &status, &attempts, &nextAttemptAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(attempts), &d.Attempts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook delivery attempts: %w", err)
+	}
+
+	d.EventType = subscription.EventType(eventType)
+	d.Status = webhook.DeliveryStatus(status)
+	d.Payload = json.RawMessage(payload)
+	d.CreatedAt = d.CreatedAt.UTC()
+
+	if nextAttemptAt.Valid {
+		at := nextAttemptAt.Time.UTC()
+		d.NextAttemptAt = &at
+	}
+
+	return &d, nil
+}
+
+func (repo *Repository) List(ctx context.Context) ([]*webhook.Model, error) {
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	result := []*webhook.Model{}
+
+	for rows.Next() {
+		m, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *Repository) Get(ctx context.Context, id *string) (*webhook.Model, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	row := repo.db.Querier(ctx).QueryRowContext(ctx, repo.db.Rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`), *id)
+
+	m, err := scanWebhook(row)
+	switch {
+	case errors.Is(err, dbsql.ErrNoRows):
+		return nil, webhook.ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return m, nil
+}
+
+func (repo *Repository) Create(ctx context.Context, m *webhook.Model) (*webhook.Model, error) {
+
+	if m == nil || m.ID == nil || m.URL == nil || m.CreatedAt == nil {
+		return nil, errors.New("no webhook with id, url and created at provided")
+	}
+
+	eventTypes := m.EventTypes
+	if eventTypes == nil {
+		eventTypes = []subscription.EventType{}
+	}
+
+	encoded, err := json.Marshal(eventTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook event types: %w", err)
+	}
+
+	_, err = repo.db.Querier(ctx).ExecContext(ctx, repo.db.Rebind(`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?)`),
+		*m.ID, *m.URL, m.Secret, string(encoded), m.CreatedAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	return m, nil
+}
+
+func (repo *Repository) Delete(ctx context.Context, id *string) error {
+
+	if id == nil {
+		return errors.New("no id provided")
+	}
+
+	// sqlite does not enforce foreign keys by default, so the deliveries are deleted explicitly
+	return repo.db.Atomically(ctx, func(ctx context.Context) error {
+
+		q := repo.db.Querier(ctx)
+
+		if _, err := q.ExecContext(ctx, repo.db.Rebind(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`), *id); err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+
+		result, err := q.ExecContext(ctx, repo.db.Rebind(`DELETE FROM webhooks WHERE id = ?`), *id)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return webhook.ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (repo *Repository) CreateDelivery(ctx context.Context, d *webhook.Delivery) error {
+
+	if d == nil {
+		return errors.New("no delivery provided")
+	}
+
+	return repo.db.Atomically(ctx, func(ctx context.Context) error {
+
+		if _, err := repo.Get(ctx, &d.WebhookID); err != nil {
+			return err
+		}
+
+		attempts, err := json.Marshal(attemptsOf(d))
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook delivery attempts: %w", err)
+		}
+
+		_, err = repo.db.Querier(ctx).ExecContext(ctx, repo.db.Rebind(`INSERT INTO webhook_deliveries (`+deliveryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			d.ID, d.WebhookID, d.EventID, string(d.EventType), string(d.Payload), string(d.Status), string(attempts), utc(d.NextAttemptAt), d.CreatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to insert webhook delivery: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *Repository) UpdateDelivery(ctx context.Context, d *webhook.Delivery) error {
+
+	if d == nil {
+		return errors.New("no delivery provided")
+	}
+
+	attempts, err := json.Marshal(attemptsOf(d))
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery attempts: %w", err)
+	}
+
+	result, err := repo.db.Querier(ctx).ExecContext(ctx, repo.db.Rebind(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ? WHERE id = ?`),
+		string(d.Status), string(attempts), utc(d.NextAttemptAt), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return webhook.ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo *Repository) GetDelivery(ctx context.Context, id *string) (*webhook.Delivery, error) {
+
+	if id == nil {
+		return nil, errors.New("no id provided")
+	}
+
+	row := repo.db.Querier(ctx).QueryRowContext(ctx, repo.db.Rebind(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`), *id)
+
+	d, err := scanDelivery(row)
+	switch {
+	case errors.Is(err, dbsql.ErrNoRows):
+		return nil, webhook.ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	return d, nil
+}
+
+// Deliveries of webhook in the order they were created, the cursor is the sequence of the last
+// delivery of the previous page
+func (repo *Repository) Deliveries(ctx context.Context, webhookID *string, cursor *string, limit int) (*webhook.DeliveryPage, error) {
+
+	if webhookID == nil {
+		return nil, errors.New("no webhook id provided")
+	}
+
+	if limit <= 0 {
+		return nil, errors.New("limit needs to be positive")
+	}
+
+	var after int64
+	if cursor != nil {
+		seq, err := strconv.ParseInt(*cursor, 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid cursor %q: %w", *cursor, webhook.ErrNotValid)
+		}
+		after = seq
+	}
+
+	if _, err := repo.Get(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, repo.db.Rebind(`SELECT sequence, `+deliveryColumns+`
+		FROM webhook_deliveries WHERE webhook_id = ? AND sequence > ? ORDER BY sequence LIMIT ?`), *webhookID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	page := &webhook.DeliveryPage{Deliveries: []*webhook.Delivery{}}
+
+	var last int64
+
+	for rows.Next() {
+
+		if len(page.Deliveries) == limit {
+			next := strconv.FormatInt(last, 10)
+			page.NextCursor = &next
+			break
+		}
+
+		var seq int64
+		d, err := scanDelivery(sequenced{&seq, rows})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		last = seq
+		page.Deliveries = append(page.Deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return page, nil
+}
+
+func (repo *Repository) Unfinished(ctx context.Context) ([]*webhook.Delivery, error) {
+
+	rows, err := repo.db.Querier(ctx).QueryContext(ctx, repo.db.Rebind(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE status IN (?, ?) ORDER BY sequence`),
+		string(webhook.DeliveryPending), string(webhook.DeliveryRetrying))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*webhook.Delivery
+
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+// sequenced scans the sequence of a delivery ahead of its columns
+type sequenced struct {
+	seq *int64
+	row scanner
+}
+
+func (s sequenced) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.seq}, dest...)...)
+}
+
+func attemptsOf(d *webhook.Delivery) []webhook.Attempt {
+	if d.Attempts == nil {
+		return []webhook.Attempt{}
+	}
+	return d.Attempts
+}
+
+func utc(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
@@ -0,0 +1,139 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	subsql "github.com/rgynn/subscription-api/pkg/subscription/repo/sql"
+	"github.com/rgynn/subscription-api/pkg/webhook"
+)
+
+func TestRepository(t *testing.T) {
+
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "subscriptions.db")
+
+	db, err := subsql.Open(ctx, subsql.SQLite, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, url, secret := webhook.NewID(), "https://example.com/hook", "0123456789abcdef"
+	createdAt := time.Now().UTC().Truncate(time.Second)
+
+	if _, err := repo.Create(ctx, &webhook.Model{ID: &id, URL: &url, Secret: &secret, EventTypes: []subscription.EventType{subscription.EventCancelled}, CreatedAt: &createdAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := repo.Get(ctx, &id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *m.URL != url || *m.Secret != secret || !m.CreatedAt.Equal(createdAt) || !m.Wants(subscription.EventCancelled) || m.Wants(subscription.EventPaused) {
+		t.Fatalf("unexpected webhook: %+v", m)
+	}
+
+	var deliveries []*webhook.Delivery
+
+	for i := 0; i < 3; i++ {
+		d := &webhook.Delivery{
+			ID:        webhook.NewID(),
+			WebhookID: id,
+			EventID:   webhook.NewID(),
+			EventType: subscription.EventCancelled,
+			Payload:   json.RawMessage(`{"msisdn":"8-6785500"}`),
+			Status:    webhook.DeliveryPending,
+			CreatedAt: createdAt,
+		}
+		if err := repo.CreateDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	unknown := "unknown"
+	if err := repo.CreateDelivery(ctx, &webhook.Delivery{ID: webhook.NewID(), WebhookID: unknown}); !errors.Is(err, webhook.ErrNotFound) {
+		t.Fatalf("expected delivery to an unknown webhook to be not found, got: %v", err)
+	}
+
+	next := createdAt.Add(time.Minute)
+	retrying := deliveries[1]
+	retrying.Status = webhook.DeliveryRetrying
+	retrying.Attempts = []webhook.Attempt{{At: createdAt, StatusCode: 503, Error: "unexpected status 503", Duration: time.Second}}
+	retrying.NextAttemptAt = &next
+
+	deliveries[2].Status = webhook.DeliverySucceeded
+
+	for _, d := range deliveries[1:] {
+		if err := repo.UpdateDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unfinished deliveries are still there once the database is opened again
+	db.Close()
+
+	db, err = subsql.Open(ctx, subsql.SQLite, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if repo, err = NewRepository(db); err != nil {
+		t.Fatal(err)
+	}
+
+	unfinished, err := repo.Unfinished(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(unfinished) != 2 || unfinished[0].ID != deliveries[0].ID || unfinished[1].ID != retrying.ID {
+		t.Fatalf("expected the pending and retrying delivery to be unfinished, got: %d", len(unfinished))
+	}
+
+	if got := unfinished[1]; !got.NextAttemptAt.Equal(next) || len(got.Attempts) != 1 || got.Attempts[0].StatusCode != 503 || string(got.Payload) != string(retrying.Payload) {
+		t.Fatalf("unexpected retrying delivery: %+v", got)
+	}
+
+	page, err := repo.Deliveries(ctx, &id, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Deliveries) != 2 || page.NextCursor == nil || page.Deliveries[0].ID != deliveries[0].ID {
+		t.Fatalf("unexpected first page: %d deliveries, cursor: %v", len(page.Deliveries), page.NextCursor)
+	}
+
+	page, err = repo.Deliveries(ctx, &id, page.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Deliveries) != 1 || page.NextCursor != nil || page.Deliveries[0].Status != webhook.DeliverySucceeded {
+		t.Fatalf("unexpected last page: %d deliveries, cursor: %v", len(page.Deliveries), page.NextCursor)
+	}
+
+	if err := repo.Delete(ctx, &id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetDelivery(ctx, &deliveries[0].ID); !errors.Is(err, webhook.ErrNotFound) {
+		t.Fatalf("expected deliveries to be deleted with their webhook, got: %v", err)
+	}
+
+	if err := repo.Delete(ctx, &id); !errors.Is(err, webhook.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+}
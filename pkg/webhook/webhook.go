@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rgynn/subscription-api/pkg/subscription"
+	"github.com/rgynn/subscription-api/pkg/validate"
+)
+
+// ErrNotFound returned if a webhook not found for the provided id
+var ErrNotFound = errors.New("webhook not found for the provided id")
+
+// ErrNotValid returned if provided webhook not valid
+var ErrNotValid = errors.New("provided webhook not valid")
+
+const (
+	// HeaderID of the webhook a delivery is made for
+	HeaderID = "X-Webhook-ID"
+	// HeaderDelivery id, the same for every attempt of a delivery
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderEvent type delivered
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderTimestamp of the attempt in unix seconds, part of the signed content
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature of the attempt as sha256=<hex hmac-sha256 of "<timestamp>.<body>" using the webhook secret>
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// DeliveryStatus of a delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending has not been attempted yet
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryRetrying failed at least once and will be attempted again
+	DeliveryRetrying DeliveryStatus = "retrying"
+	// DeliverySucceeded got a 2xx response
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead failed too many times and will not be attempted again
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Repository interface for webhooks and their deliveries
+type Repository interface {
+	List(ctx context.Context) ([]*Model, error)
+	Get(ctx context.Context, id *string) (*Model, error)
+	Create(ctx context.Context, m *Model) (*Model, error)
+	Delete(ctx context.Context, id *string) error
+	CreateDelivery(ctx context.Context, d *Delivery) error
+	UpdateDelivery(ctx context.Context, d *Delivery) error
+	GetDelivery(ctx context.Context, id *string) (*Delivery, error)
+	Deliveries(ctx context.Context, webhookID *string, cursor *string, limit int) (*DeliveryPage, error)
+	// Unfinished deliveries that are pending or retrying
+	Unfinished(ctx context.Context) ([]*Delivery, error)
+}
+
+// Model of a webhook target
+type Model struct {
+	ID         *string                  `json:"id"`
+	URL        *string                  `json:"url"`
+	Secret     *string                  `json:"secret,omitempty"`
+	EventTypes []subscription.EventType `json:"event_types"`
+	CreatedAt  *time.Time               `json:"created_at"`
+}
+
+// ValidForSave returns a validate.Error listing every field not valid for registering a webhook
+func (m *Model) ValidForSave() error {
+
+	if m == nil {
+		return fmt.Errorf("no webhook provided: %w", ErrNotValid)
+	}
+
+	v := validate.New(ErrNotValid)
+
+	if m.ID != nil {
+		v.Add("id", "is read only")
+	}
+
+	if m.CreatedAt != nil {
+		v.Add("created_at", "is read only")
+	}
+
+	if m.URL == nil {
+		v.Add("url", "is required")
+	} else if u, err := url.Parse(*m.URL); err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		v.Add("url", "needs to be an absolute http or https url")
+	}
+
+	if m.Secret != nil && len(*m.Secret) < 16 {
+		v.Add("secret", "needs to be at least 16 characters")
+	}
+
+	for i, t := range m.EventTypes {
+		if !knownEventType(t) {
+			v.Add(fmt.Sprintf("event_types[%d]", i), fmt.Sprintf("unknown event type: %s", t))
+		}
+	}
+
+	return v.Err()
+}
+
+func knownEventType(t subscription.EventType) bool {
+	for _, known := range subscription.EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports if the webhook is registered for events of type t
+func (m *Model) Wants(t subscription.EventType) bool {
+
+	if len(m.EventTypes) == 0 {
+		return true
+	}
+
+	for _, want := range m.EventTypes {
+		if want == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redacted copy of the webhook without its secret
+func (m *Model) Redacted() *Model {
+	result := *m
+	result.Secret = nil
+	return &result
+}
+
+// Attempt to deliver an event
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Delivery of an event to a webhook
+type Delivery struct {
+	ID            string                 `json:"id"`
+	WebhookID     string                 `json:"webhook_id"`
+	EventID       string                 `json:"event_id"`
+	EventType     subscription.EventType `json:"event_type"`
+	Payload       json.RawMessage        `json:"payload"`
+	Status        DeliveryStatus         `json:"status"`
+	Attempts      []Attempt              `json:"attempts"`
+	NextAttemptAt *time.Time             `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+// DeliveryPage of deliveries, NextCursor is set if there are more deliveries
+type DeliveryPage struct {
+	Deliveries []*Delivery `json:"deliveries"`
+	NextCursor *string     `json:"next_cursor,omitempty"`
+}
+
+// Envelope posted to webhooks
+type Envelope struct {
+	ID         string                   `json:"id"`
+	Type       subscription.EventType   `json:"type"`
+	OccurredAt time.Time                `json:"occurred_at"`
+	Data       subscription.DomainEvent `json:"data"`
+}
+
+// Sign body sent at timestamp with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify signature of body sent at timestamp, for use by receivers
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewID generates a random id for webhooks, deliveries and events
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %s", err))
+	}
+	return hex.EncodeToString(b)
+}